@@ -0,0 +1,133 @@
+package cyber
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// HTTPError is an error carrying the response status, error code and any
+// headers that Abort writes, such as WWW-Authenticate for a 401.
+type HTTPError struct {
+	Status  int
+	Code    string
+	Message string
+	Header  http.Header
+}
+
+func NewHTTPError(status int, code, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// WithHeader adds a response header to the error and returns it.
+func (e *HTTPError) WithHeader(key, value string) *HTTPError {
+	if e.Header == nil {
+		e.Header = make(http.Header)
+	}
+	e.Header.Add(key, value)
+	return e
+}
+
+// Unauthorized returns a 401 error with one WWW-Authenticate header per
+// challenge, e.g. Challenge("Bearer", map[string]string{"realm": "api"}).
+func Unauthorized(challenges ...string) *HTTPError {
+	err := NewHTTPError(http.StatusUnauthorized, "unauthorized", "Unauthorized")
+	for _, challenge := range challenges {
+		err.WithHeader("WWW-Authenticate", challenge)
+	}
+	return err
+}
+
+// ProxyAuthRequired returns a 407 error with one Proxy-Authenticate header
+// per challenge.
+func ProxyAuthRequired(challenges ...string) *HTTPError {
+	err := NewHTTPError(http.StatusProxyAuthRequired, "proxy_auth_required", "Proxy Authentication Required")
+	for _, challenge := range challenges {
+		err.WithHeader("Proxy-Authenticate", challenge)
+	}
+	return err
+}
+
+// UpgradeRequired returns a 426 error asking the client to switch to one of
+// protocols, e.g. "TLS/1.2" or "h2c".
+func UpgradeRequired(protocols ...string) *HTTPError {
+	return NewHTTPError(http.StatusUpgradeRequired, "upgrade_required", "Upgrade Required").
+		WithHeader("Upgrade", strings.Join(protocols, ", ")).
+		WithHeader("Connection", "Upgrade")
+}
+
+// Challenge formats an authentication challenge with quoted parameters in a
+// stable order.
+func Challenge(scheme string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(params[key])
+		parts = append(parts, key+`="`+value+`"`)
+	}
+	if len(parts) == 0 {
+		return scheme
+	}
+	return scheme + " " + strings.Join(parts, ", ")
+}
+
+// OnError sets the handler that formats responses written by Abort. The
+// error's headers are already set when it is called.
+func (app *App) OnError(handler func(w http.ResponseWriter, r *http.Request, err *HTTPError)) {
+	app.errorHandler = handler
+}
+
+// Abort writes err as the response and marks the request as handled so the
+// caller can return without calling the next handler. Errors that are not
+// an *HTTPError become a 500. Only the first Abort of a request writes;
+// later ones are ignored, and Aborted reports whether the request has been
+// aborted.
+func Abort(w http.ResponseWriter, r *http.Request, err error) {
+	state := stateFromRequest(r)
+	if state != nil {
+		state.mu.Lock()
+		aborted := state.aborted
+		state.aborted = true
+		state.mu.Unlock()
+		if aborted {
+			log.Printf("Abort ignored, response already aborted: %v", err)
+			return
+		}
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		log.Printf("Handler error: %v", err)
+		httpErr = NewHTTPError(http.StatusInternalServerError, "internal_error", "Internal Server Error")
+	}
+	for key, values := range httpErr.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	if state != nil && state.app != nil && state.app.errorHandler != nil {
+		state.app.errorHandler(w, r, httpErr)
+		return
+	}
+	Error(w, r, httpErr.Status, httpErr.Code, httpErr.Message)
+}
+
+// Aborted reports whether Abort has been called for the request.
+func Aborted(r *http.Request) bool {
+	state := stateFromRequest(r)
+	if state == nil {
+		return false
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.aborted
+}
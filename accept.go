@@ -0,0 +1,155 @@
+package cyber
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type acceptSpec struct {
+	value string
+	q     float64
+}
+
+// parseAccept 解析 Accept 类请求头及其q值
+func parseAccept(header string) []acceptSpec {
+	var specs []acceptSpec
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		spec := acceptSpec{q: 1}
+		fields := strings.Split(part, ";")
+		spec.value = strings.ToLower(strings.TrimSpace(fields[0]))
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+				spec.q = q
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// negotiate returns the offer with the highest q value according to header.
+// match reports how specifically a spec matches an offer (0 for no match);
+// the most specific matching spec decides an offer's q value.
+func negotiate(header string, offers []string, match func(spec, offer string) int) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+	specs := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		lower := strings.ToLower(offer)
+		q, specificity := 0.0, 0
+		for _, spec := range specs {
+			if s := match(spec.value, lower); s > specificity {
+				q, specificity = spec.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+func matchMediaType(spec, offer string) int {
+	spec, _, _ = strings.Cut(spec, ";")
+	offer, _, _ = strings.Cut(offer, ";")
+	if spec == offer {
+		return 3
+	}
+	specType, specSub, _ := strings.Cut(spec, "/")
+	offerType, _, _ := strings.Cut(offer, "/")
+	if specSub == "*" && specType == offerType {
+		return 2
+	}
+	if spec == "*/*" {
+		return 1
+	}
+	return 0
+}
+
+func matchLanguage(spec, offer string) int {
+	if spec == offer {
+		return 3
+	}
+	if strings.HasPrefix(offer, spec+"-") {
+		return 2
+	}
+	if spec == "*" {
+		return 1
+	}
+	return 0
+}
+
+func matchToken(spec, offer string) int {
+	if spec == offer {
+		return 2
+	}
+	if spec == "*" {
+		return 1
+	}
+	return 0
+}
+
+// Accepts returns the best of the offered media types for the request's
+// Accept header, or "" if none is acceptable.
+func Accepts(r *http.Request, offers ...string) string {
+	return negotiate(r.Header.Get("Accept"), offers, matchMediaType)
+}
+
+// AcceptsLanguages returns the best of the offered language tags for the
+// request's Accept-Language header, or "" if none is acceptable.
+func AcceptsLanguages(r *http.Request, offers ...string) string {
+	return negotiate(r.Header.Get("Accept-Language"), offers, matchLanguage)
+}
+
+// AcceptsEncodings returns the best of the offered content codings for the
+// request's Accept-Encoding header, or "" if none is acceptable. Without
+// the header only "identity" is acceptable: many clients that don't send
+// it can't decode compressed bodies.
+func AcceptsEncodings(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept-Encoding")
+	if strings.TrimSpace(header) == "" {
+		for _, offer := range offers {
+			if strings.EqualFold(offer, "identity") {
+				return offer
+			}
+		}
+		return ""
+	}
+	return negotiate(header, offers, matchToken)
+}
+
+// AcceptsGzip reports whether the request's Accept-Encoding header lists
+// gzip with a non-zero q value. A wildcard doesn't count, so responses are
+// only compressed for clients that asked for gzip by name.
+func AcceptsGzip(r *http.Request) bool {
+	for _, spec := range parseAccept(r.Header.Get("Accept-Encoding")) {
+		if spec.value == "gzip" || spec.value == "x-gzip" {
+			return spec.q > 0
+		}
+	}
+	return false
+}
+
+// ContentType returns the request's media type without parameters.
+func ContentType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
@@ -0,0 +1,64 @@
+package cyber
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WrapHandler adapts an http.Handler for use with App.Handle and friends.
+// Path parameters stay available through r.PathValue because the request is
+// passed through unchanged.
+func WrapHandler(h http.Handler) HandlerFunc {
+	return h.ServeHTTP
+}
+
+// WrapHandlerFunc adapts an http.HandlerFunc. Since HandlerFunc is an alias
+// this is the identity; it exists for symmetry with WrapHandler.
+func WrapHandlerFunc(h http.HandlerFunc) HandlerFunc {
+	return h
+}
+
+// ToHTTPHandler exposes a cyber handler as an http.Handler.
+func ToHTTPHandler(h HandlerFunc) http.Handler {
+	return h
+}
+
+// WrapMiddleware adapts standard func(http.Handler) http.Handler middleware
+// so it can be passed to App.Use.
+func WrapMiddleware(m func(http.Handler) http.Handler) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return m(next).ServeHTTP
+	}
+}
+
+// UseHandler wraps the app's whole handler with m. Unlike Use, m also sees
+// requests that match no route, such as scanners probing /wp-login.php,
+// which the ServeMux answers with 404 without running any middleware. Call
+// it after replacing Server.Handler, if at all, and before serving.
+func (app *App) UseHandler(m func(http.Handler) http.Handler) {
+	next := app.Handler()
+	app.Server.Handler = wrappedHandler{Handler: m(next), next: next}
+}
+
+// wrappedHandler 是UseHandler包装后的Handler
+type wrappedHandler struct {
+	http.Handler
+	next http.Handler
+}
+
+func (h wrappedHandler) unwrap() http.Handler {
+	return h.next
+}
+
+// Mount serves h for every request under prefix, with the prefix stripped
+// from the path, after running the app's middlewares.
+func (app *App) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix("/"+strings.Trim(prefix, "/"), "/")
+	route, finalHandler := app.addRoute("", prefix+"/", http.StripPrefix(prefix, h).ServeHTTP, nil)
+	app.mux.HandleFunc(prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		r, cancel := app.prepareRequest(r, route)
+		defer cancel()
+		finalHandler(w, r)
+	})
+	app.debugf("Route mounted: %s/", prefix)
+}
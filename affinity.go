@@ -0,0 +1,200 @@
+package cyber
+
+import (
+	"hash/fnv"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type AffinityConfig struct {
+	// Cookie名称，默认 SERVERID；负载均衡器（HAProxy cookie、Traefik sticky、nginx sticky）需配置为读取同名Cookie
+	CookieName string
+	// 当前实例的标识，即负载均衡器中后端的名称，默认使用主机名
+	Backend string
+	Path    string
+	Domain  string
+	// Cookie有效期，0表示会话Cookie
+	MaxAge   time.Duration
+	Secure   bool
+	SameSite http.SameSite
+}
+
+const defaultAffinityCookie = "SERVERID"
+
+var defaultAffinityConfig = AffinityConfig{
+	CookieName: defaultAffinityCookie,
+	Path:       "/",
+	SameSite:   http.SameSiteLaxMode,
+}
+
+func (config AffinityConfig) withDefaults() AffinityConfig {
+	if config.CookieName == "" {
+		config.CookieName = defaultAffinityConfig.CookieName
+	}
+	if config.Path == "" {
+		config.Path = defaultAffinityConfig.Path
+	}
+	if config.SameSite == 0 {
+		config.SameSite = defaultAffinityConfig.SameSite
+	}
+	if config.Backend == "" {
+		config.Backend, _ = os.Hostname()
+	}
+	return config
+}
+
+// Affinity returns the backend named by the request's affinity cookie.
+func Affinity(r *http.Request, config AffinityConfig) (string, bool) {
+	config = config.withDefaults()
+	cookie, err := r.Cookie(config.CookieName)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// SetAffinity pins the client to config.Backend with an affinity cookie.
+func SetAffinity(w http.ResponseWriter, config AffinityConfig) {
+	config = config.withDefaults()
+	cookie := &http.Cookie{
+		Name:     config.CookieName,
+		Value:    config.Backend,
+		Path:     config.Path,
+		Domain:   config.Domain,
+		Secure:   config.Secure,
+		HttpOnly: true,
+		SameSite: config.SameSite,
+	}
+	if config.MaxAge > 0 {
+		cookie.MaxAge = int(config.MaxAge / time.Second)
+	}
+	http.SetCookie(w, cookie)
+}
+
+func StickySessionMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return StickySession(defaultAffinityConfig)(next)
+}
+
+// StickySession sets the affinity cookie when the client has none or it
+// names another backend, e.g. after the load balancer failed over, so the
+// following requests stay on this instance and its in-memory sessions.
+func StickySession(config AffinityConfig) Middleware {
+	config = config.withDefaults()
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if backend, ok := Affinity(r, config); !ok || backend != config.Backend {
+				SetAffinity(w, config)
+			}
+			next(w, r)
+		}
+	}
+}
+
+// 每个后端在哈希环上的默认虚拟节点数
+const defaultHashReplicas = 128
+
+// HashRing maps keys such as user IDs to backends by consistent hashing, so
+// adding or removing a backend only moves the keys of its neighbours. It
+// hashes with FNV-1a, so every instance computes the same mapping. In a
+// proxy, pick the upstream with Get(userID) and relay it with ProxyBody.
+type HashRing struct {
+	mu       sync.RWMutex
+	replicas int
+	hashes   []uint64
+	owners   map[uint64]string
+	backends map[string]struct{}
+}
+
+// NewHashRing creates a ring placing each backend at replicas points;
+// replicas <= 0 uses 128.
+func NewHashRing(replicas int, backends ...string) *HashRing {
+	if replicas <= 0 {
+		replicas = defaultHashReplicas
+	}
+	ring := &HashRing{replicas: replicas, owners: make(map[uint64]string), backends: make(map[string]struct{})}
+	ring.Add(backends...)
+	return ring
+}
+
+// Add places backends on the ring.
+func (ring *HashRing) Add(backends ...string) {
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+	for _, backend := range backends {
+		if _, ok := ring.backends[backend]; ok {
+			continue
+		}
+		ring.backends[backend] = struct{}{}
+		for i := 0; i < ring.replicas; i++ {
+			h := ringHash(strconv.Itoa(i) + "#" + backend)
+			// 哈希冲突时保留先加入的后端
+			if _, ok := ring.owners[h]; !ok {
+				ring.owners[h] = backend
+				ring.hashes = append(ring.hashes, h)
+			}
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+}
+
+// Remove takes backends off the ring.
+func (ring *HashRing) Remove(backends ...string) {
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+	for _, backend := range backends {
+		delete(ring.backends, backend)
+	}
+	hashes := ring.hashes[:0]
+	for _, h := range ring.hashes {
+		if _, ok := ring.backends[ring.owners[h]]; ok {
+			hashes = append(hashes, h)
+		} else {
+			delete(ring.owners, h)
+		}
+	}
+	ring.hashes = hashes
+}
+
+// Get returns the backend owning key, or "" if the ring is empty.
+func (ring *HashRing) Get(key string) string {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	if len(ring.hashes) == 0 {
+		return ""
+	}
+	h := ringHash(key)
+	i := sort.Search(len(ring.hashes), func(i int) bool { return ring.hashes[i] >= h })
+	if i == len(ring.hashes) {
+		i = 0
+	}
+	return ring.owners[ring.hashes[i]]
+}
+
+// Backends returns the backends on the ring, sorted.
+func (ring *HashRing) Backends() []string {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	backends := make([]string, 0, len(ring.backends))
+	for backend := range ring.backends {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+func ringHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// FNV对相近的短键分布不均，再混合一次使虚拟节点均匀散布
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
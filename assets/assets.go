@@ -0,0 +1,198 @@
+// Package assets serves static files under fingerprinted URLs, such as
+// /assets/app.3f2a9c1b7d4e.js for app.js, so browsers can cache them
+// forever and still fetch the new version after every deploy.
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// 静态文件所在的文件系统，例如embed.FS或os.DirFS("public")
+	FS fs.FS
+	// URL前缀，为空时使用/assets；Manifest需要挂载在同一前缀下
+	Prefix string
+	// 构建工具生成的清单文件在FS中的路径，内容为{"app.js": "app.abc123.js"}；
+	// 为空时启动时计算每个文件内容的哈希
+	Manifest string
+}
+
+// 带指纹的文件一年内不会变化
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// 指纹长度，十六进制字符数
+const hashLen = 12
+
+// Manifest maps asset names to fingerprinted URLs and serves the files.
+type Manifest struct {
+	prefix string
+	fsys   fs.FS
+	// urls 从文件名映射到带指纹的文件名
+	urls map[string]string
+	// files 从带指纹的文件名映射到FS中的文件
+	files map[string]string
+}
+
+// New builds the manifest from config.Manifest, or by hashing every file
+// in config.FS when it's empty.
+func New(config Config) (*Manifest, error) {
+	if config.FS == nil {
+		return nil, fmt.Errorf("assets: FS is required")
+	}
+	prefix := strings.TrimSuffix("/"+strings.Trim(config.Prefix, "/"), "/")
+	if prefix == "" {
+		prefix = "/assets"
+	}
+	m := &Manifest{prefix: prefix, fsys: config.FS, urls: map[string]string{}, files: map[string]string{}}
+	if config.Manifest != "" {
+		if err := m.load(config.Manifest); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	err := fs.WalkDir(config.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		sum, err := hashFile(config.FS, name)
+		if err != nil {
+			return err
+		}
+		hashed := fingerprint(name, sum)
+		m.urls[name] = hashed
+		m.files[hashed] = name
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("assets: %w", err)
+	}
+	return m, nil
+}
+
+// Must is like New but panics on error.
+func Must(config Config) *Manifest {
+	m, err := New(config)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m *Manifest) load(name string) error {
+	data, err := fs.ReadFile(m.fsys, name)
+	if err != nil {
+		return fmt.Errorf("assets: %w", err)
+	}
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("assets: invalid manifest %s: %w", name, err)
+	}
+	for logical, hashed := range entries {
+		logical, hashed = strings.TrimPrefix(logical, "/"), strings.TrimPrefix(hashed, "/")
+		m.urls[logical] = hashed
+		m.files[hashed] = hashed
+	}
+	return nil
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:hashLen], nil
+}
+
+// fingerprint 在扩展名前插入哈希：css/site.min.css -> css/site.min.<hash>.css
+func fingerprint(name, sum string) string {
+	ext := path.Ext(name)
+	if ext == "" || ext == path.Base(name) {
+		return name + "." + sum
+	}
+	return strings.TrimSuffix(name, ext) + "." + sum + ext
+}
+
+// Prefix returns the URL prefix to mount the manifest at.
+func (m *Manifest) Prefix() string {
+	return m.prefix
+}
+
+// URL returns the fingerprinted URL of the named asset, e.g. "app.js" ->
+// "/assets/app.3f2a9c1b7d4e.js". Unknown names get a plain URL that's
+// served without long-term caching.
+func (m *Manifest) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if hashed, ok := m.urls[name]; ok {
+		return m.prefix + "/" + hashed
+	}
+	return m.prefix + "/" + name
+}
+
+// FuncMap returns the "asset" template function for App.LoadTemplates, so
+// templates can write <script src="{{asset "app.js"}}"></script>.
+func (m *Manifest) FuncMap() template.FuncMap {
+	return template.FuncMap{"asset": m.URL}
+}
+
+// ServeHTTP serves the assets; mount it with app.Mount(m.Prefix(), m).
+// Fingerprinted URLs are cached as immutable, plain names must be
+// revalidated on every use.
+func (m *Manifest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	file, fingerprinted := m.files[name]
+	if !fingerprinted {
+		file = name
+	}
+	f, err := m.fsys.Open(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	modTime := info.ModTime()
+	if fingerprinted {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+		w.Header().Set("ETag", `"`+name+`"`)
+		// 带指纹的文件以ETag为准，避免不同机器上的修改时间不一致
+		modTime = time.Time{}
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, modTime, content)
+}
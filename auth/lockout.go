@@ -0,0 +1,447 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrLocked = errors.New("auth: account locked")
+
+type EventType string
+
+const (
+	EventLoginFailed  EventType = "login_failed"
+	EventLoginSuccess EventType = "login_success"
+	EventLocked       EventType = "account_locked"
+	EventUnlocked     EventType = "account_unlocked"
+	EventThrottled    EventType = "login_throttled"
+)
+
+// Event 用于安全监控的登录事件
+type Event struct {
+	Type     EventType
+	Username string
+	IP       string
+	Failures int
+	Time     time.Time
+}
+
+// Attempt 记录某个 username+IP 的失败登录状态
+type Attempt struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+	UnlockToken string
+}
+
+type LockoutStore interface {
+	Get(key string) (Attempt, bool)
+	Set(key string, attempt Attempt)
+	Delete(key string)
+}
+
+// LockoutUpdater is implemented by stores that can update an attempt
+// atomically, e.g. one shared by several instances. Without it Guard
+// serializes its own updates, which only covers a single process.
+type LockoutUpdater interface {
+	Update(key string, fn func(attempt Attempt, ok bool) Attempt) Attempt
+}
+
+// MemoryLockoutStore keeps attempts in process. An attempt is forgotten
+// ttl after its last failure or the end of its lock, whichever is later,
+// so the map doesn't grow with every username and IP ever tried.
+type MemoryLockoutStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	attempts map[string]Attempt
+	// sweepAt 是下次清理过期记录时的记录数
+	sweepAt int
+}
+
+const (
+	// 未指定ttl时记录保留的时间
+	defaultLockoutTTL = 24 * time.Hour
+	// 记录数达到该值后才开始清理
+	minLockoutSweep = 64
+)
+
+// NewMemoryLockoutStore creates a store that forgets attempts after ttl; a
+// ttl of 0 or less uses the default of 24 hours.
+func NewMemoryLockoutStore(ttl time.Duration) *MemoryLockoutStore {
+	if ttl <= 0 {
+		ttl = defaultLockoutTTL
+	}
+	return &MemoryLockoutStore{ttl: ttl, attempts: make(map[string]Attempt), sweepAt: minLockoutSweep}
+}
+
+func (s *MemoryLockoutStore) expired(attempt Attempt, now time.Time) bool {
+	last := attempt.LastFailure
+	if attempt.LockedUntil.After(last) {
+		last = attempt.LockedUntil
+	}
+	return now.After(last.Add(s.ttl))
+}
+
+func (s *MemoryLockoutStore) Get(key string) (Attempt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(key)
+}
+
+func (s *MemoryLockoutStore) get(key string) (Attempt, bool) {
+	attempt, ok := s.attempts[key]
+	if ok && s.expired(attempt, time.Now()) {
+		delete(s.attempts, key)
+		return Attempt{}, false
+	}
+	return attempt, ok
+}
+
+func (s *MemoryLockoutStore) Set(key string, attempt Attempt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, attempt)
+}
+
+// set 写入记录，记录数达到阈值时清理过期记录，清理的开销均摊到每次写入
+func (s *MemoryLockoutStore) set(key string, attempt Attempt) {
+	s.attempts[key] = attempt
+	if len(s.attempts) < s.sweepAt {
+		return
+	}
+	now := time.Now()
+	for key, attempt := range s.attempts {
+		if s.expired(attempt, now) {
+			delete(s.attempts, key)
+		}
+	}
+	s.sweepAt = max(2*len(s.attempts), minLockoutSweep)
+}
+
+func (s *MemoryLockoutStore) Update(key string, fn func(attempt Attempt, ok bool) Attempt) Attempt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.get(key)
+	attempt = fn(attempt, ok)
+	s.set(key, attempt)
+	return attempt
+}
+
+func (s *MemoryLockoutStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+}
+
+type GuardConfig struct {
+	// 连续失败达到MaxFailures次后锁定
+	MaxFailures int
+	// 每次失败后的等待时间从BaseDelay开始按指数增长，最多MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// 锁定持续时间
+	LockDuration time.Duration
+	Store        LockoutStore
+	OnEvent      func(Event)
+	// OnLockout 在账户被锁定时调用，用于把解锁令牌发送给用户，例如通过邮件；
+	// 令牌不会出现在Event中，以免写入监控日志
+	OnLockout func(username, ip, token string)
+}
+
+const (
+	defaultMaxFailures  = 5
+	defaultBaseDelay    = 1 * time.Second
+	defaultMaxDelay     = 30 * time.Second
+	defaultLockDuration = 15 * time.Minute
+)
+
+// Guard protects login endpoints against brute-force attacks.
+type Guard struct {
+	config GuardConfig
+	// mu 在存储不支持原子更新时串行化失败计数
+	mu sync.Mutex
+}
+
+func NewGuard(config *GuardConfig) *Guard {
+	if config == nil {
+		config = &GuardConfig{}
+	}
+	c := *config
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = defaultMaxFailures
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = defaultBaseDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = defaultMaxDelay
+	}
+	if c.LockDuration <= 0 {
+		c.LockDuration = defaultLockDuration
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryLockoutStore(0)
+	}
+	return &Guard{config: c}
+}
+
+func guardKey(username, ip string) string {
+	return username + "|" + ip
+}
+
+// Check reports how long the caller must wait before the next attempt, or
+// ErrLocked if the account is locked for this IP. Check and Fail are
+// separate calls, so concurrent attempts can all pass Check before any
+// failure is recorded; login handlers should use Begin instead.
+func (g *Guard) Check(username, ip string) (time.Duration, error) {
+	attempt, ok := g.config.Store.Get(guardKey(username, ip))
+	if !ok {
+		return 0, nil
+	}
+	now := time.Now()
+	if now.Before(attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now), ErrLocked
+	}
+	if wait := attempt.LastFailure.Add(g.delay(attempt.Failures)).Sub(now); wait > 0 {
+		g.emit(EventThrottled, username, ip, attempt.Failures)
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt. When the account becomes locked it returns
+// the unlock token that can be delivered to the user out of band.
+func (g *Guard) Fail(username, ip string) string {
+	return g.fail(username, ip, true)
+}
+
+// fail 记录失败；count为false时失败已由Begin预先计入，只判断是否锁定
+func (g *Guard) fail(username, ip string, count bool) string {
+	now := time.Now()
+	locked := false
+	attempt := g.update(guardKey(username, ip), func(attempt Attempt, _ bool) Attempt {
+		if count {
+			attempt = resetExpiredLock(attempt, now)
+			attempt.Failures++
+			attempt.LastFailure = now
+		}
+		locked = false
+		if attempt.Failures >= g.config.MaxFailures && attempt.LockedUntil.IsZero() {
+			attempt.LockedUntil = now.Add(g.config.LockDuration)
+			attempt.UnlockToken = newUnlockToken()
+			locked = true
+		}
+		return attempt
+	})
+	g.emit(EventLoginFailed, username, ip, attempt.Failures)
+	if locked {
+		g.emit(EventLocked, username, ip, attempt.Failures)
+		if g.config.OnLockout != nil {
+			g.config.OnLockout(username, ip, attempt.UnlockToken)
+		}
+	}
+	return attempt.UnlockToken
+}
+
+// resetExpiredLock 在锁定到期后重新开始计数
+func resetExpiredLock(attempt Attempt, now time.Time) Attempt {
+	if !attempt.LockedUntil.IsZero() && now.After(attempt.LockedUntil) {
+		return Attempt{}
+	}
+	return attempt
+}
+
+// Login is an attempt admitted by Begin. Finish it with exactly one of
+// Succeed, Fail or Cancel.
+type Login struct {
+	guard    *Guard
+	username string
+	ip       string
+}
+
+// Begin atomically checks username and ip like Check and, when an attempt
+// is allowed, counts it as a failure in advance. Concurrent attempts see
+// the pending failure and are throttled, so they can't race past
+// MaxFailures while the first is still verifying the password. It returns
+// the wait and ErrLocked like Check, with a nil Login when the attempt is
+// refused.
+func (g *Guard) Begin(username, ip string) (*Login, time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	var err error
+	attempt := g.update(guardKey(username, ip), func(attempt Attempt, _ bool) Attempt {
+		wait, err = 0, nil
+		attempt = resetExpiredLock(attempt, now)
+		if now.Before(attempt.LockedUntil) {
+			wait, err = attempt.LockedUntil.Sub(now), ErrLocked
+			return attempt
+		}
+		if w := attempt.LastFailure.Add(g.delay(attempt.Failures)).Sub(now); w > 0 {
+			wait = w
+			return attempt
+		}
+		attempt.Failures++
+		attempt.LastFailure = now
+		return attempt
+	})
+	if err != nil {
+		return nil, wait, err
+	}
+	if wait > 0 {
+		g.emit(EventThrottled, username, ip, attempt.Failures)
+		return nil, wait, nil
+	}
+	return &Login{guard: g, username: username, ip: ip}, 0, nil
+}
+
+// Succeed clears the failures of the username and IP.
+func (l *Login) Succeed() {
+	l.guard.Succeed(l.username, l.ip)
+}
+
+// Fail keeps the failure counted by Begin and locks the account once it
+// reaches MaxFailures, returning the unlock token like Guard.Fail.
+func (l *Login) Fail() string {
+	return l.guard.fail(l.username, l.ip, false)
+}
+
+// Cancel takes back the failure counted by Begin, for attempts that ended
+// without checking the credentials, e.g. a malformed request.
+func (l *Login) Cancel() {
+	l.guard.update(guardKey(l.username, l.ip), func(attempt Attempt, _ bool) Attempt {
+		if attempt.Failures > 0 {
+			attempt.Failures--
+		}
+		return attempt
+	})
+}
+
+// update 原子地更新尝试记录，fn可能被存储重试，不能有副作用
+func (g *Guard) update(key string, fn func(attempt Attempt, ok bool) Attempt) Attempt {
+	if updater, ok := g.config.Store.(LockoutUpdater); ok {
+		return updater.Update(key, fn)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	attempt, ok := g.config.Store.Get(key)
+	attempt = fn(attempt, ok)
+	g.config.Store.Set(key, attempt)
+	return attempt
+}
+
+func (g *Guard) Succeed(username, ip string) {
+	g.config.Store.Delete(guardKey(username, ip))
+	g.emit(EventLoginSuccess, username, ip, 0)
+}
+
+// Unlock clears the lock if token matches the one issued by Fail.
+func (g *Guard) Unlock(username, ip, token string) bool {
+	key := guardKey(username, ip)
+	attempt, ok := g.config.Store.Get(key)
+	if !ok || attempt.UnlockToken == "" ||
+		subtle.ConstantTimeCompare([]byte(attempt.UnlockToken), []byte(token)) != 1 {
+		return false
+	}
+	g.config.Store.Delete(key)
+	g.emit(EventUnlocked, username, ip, attempt.Failures)
+	return true
+}
+
+// Protect wraps a login handler. Requests are rejected with 423 while locked
+// and 429 while throttled; a 401/403 response from next counts as a failure
+// and a 2xx response as a success, any other response as no attempt. The
+// attempt is admitted with Begin, so concurrent requests are throttled
+// while one is in progress. The unlock token of an account locked here is
+// passed to GuardConfig.OnLockout.
+func (g *Guard) Protect(usernameFunc func(r *http.Request) string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			username := usernameFunc(r)
+			ip := clientIP(r)
+			login, wait, err := g.Begin(username, ip)
+			if err != nil {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				http.Error(w, "Account Locked", http.StatusLocked)
+				return
+			}
+			if wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			sw := &statusWriter{ResponseWriter: w}
+			next(sw, r)
+			switch {
+			case sw.status == http.StatusUnauthorized || sw.status == http.StatusForbidden:
+				login.Fail()
+			case sw.status >= 200 && sw.status < 300:
+				login.Succeed()
+			default:
+				login.Cancel()
+			}
+		}
+	}
+}
+
+func (g *Guard) delay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := g.config.BaseDelay
+	for i := 1; i < failures && delay < g.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > g.config.MaxDelay {
+		delay = g.config.MaxDelay
+	}
+	return delay
+}
+
+func (g *Guard) emit(eventType EventType, username, ip string, failures int) {
+	if g.config.OnEvent == nil {
+		return
+	}
+	g.config.OnEvent(Event{Type: eventType, Username: username, IP: ip, Failures: failures, Time: time.Now()})
+}
+
+func newUnlockToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.status == 0 {
+		sw.status = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidHash         = errors.New("auth: invalid password hash")
+	ErrUnsupportedHash     = errors.New("auth: unsupported password hash algorithm")
+	ErrPasswordTooShort    = errors.New("auth: password is too short")
+	ErrPasswordTooLong     = errors.New("auth: password is too long")
+	ErrPasswordTooWeak     = errors.New("auth: password does not meet the character requirements")
+	ErrPasswordBlacklisted = errors.New("auth: password is too common")
+)
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the OWASP recommendation for argon2id.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// HashPassword hashes password with argon2id and DefaultArgon2Params,
+// returning a PHC-formatted string.
+func HashPassword(password string) (string, error) {
+	return HashPasswordArgon2(password, DefaultArgon2Params)
+}
+
+func HashPasswordArgon2(password string, p Argon2Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func HashPasswordBcrypt(password string, cost int) (string, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches an argon2id or bcrypt hash.
+func CheckPassword(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return checkArgon2(password, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, ErrUnsupportedHash
+}
+
+// 校验时接受的argon2参数上限，防止构造的哈希耗尽内存或CPU；
+// 远高于DefaultArgon2Params，调高参数重新哈希的旧密码仍然可以校验
+const (
+	maxArgon2Memory     = 1024 * 1024
+	maxArgon2Iterations = 32
+	maxArgon2Threads    = 16
+	// 密钥过短时几乎任何密码都能碰撞，长度为0时任何密码都匹配
+	minArgon2KeyLength  = 16
+	maxArgon2KeyLength  = 1024
+	minArgon2SaltLength = 8
+)
+
+func checkArgon2(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrInvalidHash
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return false, ErrInvalidHash
+	}
+	if p.Parallelism < 1 || p.Parallelism > maxArgon2Threads ||
+		p.Iterations < 1 || p.Iterations > maxArgon2Iterations ||
+		p.Memory < 8*uint32(p.Parallelism) || p.Memory > maxArgon2Memory {
+		return false, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) < minArgon2SaltLength {
+		return false, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < minArgon2KeyLength || len(key) > maxArgon2KeyLength {
+		return false, ErrInvalidHash
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// ConstantTimeEqual compares two secrets without leaking timing information.
+func ConstantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// GenerateToken returns n random bytes encoded as URL-safe base64.
+func GenerateToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// 禁止使用的常见密码，不区分大小写
+	Blacklist []string
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:    8,
+	MaxLength:    128,
+	RequireLower: true,
+	RequireDigit: true,
+	Blacklist:    []string{"password", "12345678", "123456789", "qwertyui", "11111111", "iloveyou", "admin123"},
+}
+
+func (p PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return ErrPasswordTooShort
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return ErrPasswordTooLong
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if (p.RequireUpper && !upper) || (p.RequireLower && !lower) ||
+		(p.RequireDigit && !digit) || (p.RequireSymbol && !symbol) {
+		return ErrPasswordTooWeak
+	}
+	for _, common := range p.Blacklist {
+		if strings.EqualFold(password, common) {
+			return ErrPasswordBlacklisted
+		}
+	}
+	return nil
+}
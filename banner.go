@@ -0,0 +1,128 @@
+package cyber
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+)
+
+var methodColors = map[string]string{
+	http.MethodGet:    "\033[34m",
+	http.MethodPost:   "\033[32m",
+	http.MethodPut:    "\033[33m",
+	http.MethodPatch:  "\033[36m",
+	http.MethodDelete: "\033[31m",
+}
+
+const (
+	colorReset  = "\033[0m"
+	colorYellow = "\033[33m"
+	colorBold   = "\033[01m"
+)
+
+func (app *App) addGroup(rg *RouteGroup) *RouteGroup {
+	app.routes.mu.Lock()
+	defer app.routes.mu.Unlock()
+	app.routes.groups = append(app.routes.groups, rg)
+	return rg
+}
+
+// printBanner 调试模式启动时打印路由表和配置警告
+func (app *App) printBanner(w io.Writer) {
+	fmt.Fprintf(w, "[cyber] debug mode, listening on %s; don't enable debug mode in production\n", app.Addr())
+	routes := app.Routes()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	// 表头与方法列使用等长的颜色码，保证tabwriter按可见宽度对齐
+	fmt.Fprintf(tw, "[cyber] %s%-7s%s\tPATTERN\tMIDDLEWARES\tNAME\n", colorBold, "METHOD", colorReset)
+	for _, route := range routes {
+		method := methodOrAny(route.Method)
+		color, ok := methodColors[method]
+		if !ok {
+			color = "\033[37m"
+		}
+		fmt.Fprintf(tw, "[cyber] %s%-7s%s\t%s\t%d\t%s\n", color, method, colorReset, route.Pattern, route.MiddlewareCount(), route.Name)
+	}
+	tw.Flush()
+	for _, warning := range app.RouteWarnings() {
+		fmt.Fprintf(w, "[cyber] %sWARNING%s %s\n", colorYellow, colorReset, warning)
+	}
+}
+
+// RouteWarnings reports likely routing misconfiguration: routes registered
+// before app middlewares were added (which therefore skip them), routes
+// taking over part of a mounted or subtree route, groups without routes,
+// and routes unreachable because Server.Handler replaces the app's mux.
+func (app *App) RouteWarnings() []string {
+	routes := app.Routes()
+	var warnings []string
+	var missed []string
+	app.mu.RLock()
+	appMiddlewares := len(app.Middlewares)
+	app.mu.RUnlock()
+	for _, route := range routes {
+		if route.appMiddlewares < appMiddlewares {
+			missed = append(missed, strings.TrimSpace(route.Method+" "+route.Pattern))
+		}
+	}
+	if len(missed) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d route(s) registered before app.Use added middlewares and skip them: %s",
+			len(missed), strings.Join(missed, ", ")))
+	}
+	for _, subtree := range routes {
+		// 根路径 "/" 本来就是兜底路由，不提示
+		if subtree.Pattern == "/" || !strings.HasSuffix(subtree.Pattern, "/") {
+			continue
+		}
+		for _, route := range routes {
+			if route.Pattern != subtree.Pattern && strings.HasPrefix(route.Pattern, subtree.Pattern) {
+				warnings = append(warnings, fmt.Sprintf("%s %s shadows part of %s",
+					methodOrAny(route.Method), route.Pattern, subtree.Pattern))
+			}
+		}
+	}
+	app.routes.mu.RLock()
+	groups := append([]*RouteGroup(nil), app.routes.groups...)
+	app.routes.mu.RUnlock()
+	app.mu.RLock()
+	for _, rg := range groups {
+		if rg.routeCount == 0 {
+			warnings = append(warnings, fmt.Sprintf("group %s has no routes", rg.joinPattern("")))
+		}
+	}
+	app.mu.RUnlock()
+	if !app.servesMux(app.Server.Handler) && len(routes) > 0 {
+		warnings = append(warnings, "Server.Handler is set, so routes registered on the app's mux are not served")
+	}
+	return warnings
+}
+
+// muxWrapper 由包装了其他Handler的框架内部Handler实现，例如HTTP/3的Alt-Svc
+type muxWrapper interface {
+	unwrap() http.Handler
+}
+
+// servesMux 判断Server.Handler是否仍然分发到注册路由的mux
+func (app *App) servesMux(handler http.Handler) bool {
+	for {
+		if handler == nil {
+			return app.mux == http.DefaultServeMux
+		}
+		if handler == http.Handler(app.mux) {
+			return true
+		}
+		wrapper, ok := handler.(muxWrapper)
+		if !ok {
+			return false
+		}
+		handler = wrapper.unwrap()
+	}
+}
+
+func methodOrAny(method string) string {
+	if method == "" {
+		return "*"
+	}
+	return method
+}
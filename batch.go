@@ -0,0 +1,216 @@
+package cyber
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+type BatchConfig struct {
+	// 单次批量请求允许的最大子请求数
+	MaxRequests int
+	// 从外层请求复制到子请求的请求头，用于共享认证信息
+	InheritHeaders []string
+}
+
+var defaultBatchConfig = BatchConfig{
+	MaxRequests:    20,
+	InheritHeaders: []string{"Authorization", "Cookie", "Accept-Language", "X-Request-Id"},
+}
+
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchRecorder 收集子请求的响应
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (br *batchRecorder) Header() http.Header { return br.header }
+
+func (br *batchRecorder) WriteHeader(statusCode int) {
+	if br.status == 0 {
+		br.status = statusCode
+	}
+}
+
+func (br *batchRecorder) Write(b []byte) (int, error) {
+	if br.status == 0 {
+		br.status = http.StatusOK
+	}
+	return br.body.Write(b)
+}
+
+// batchSubRequestKey 标记批量请求中的子请求
+type batchSubRequestKey struct{}
+
+// BatchHandler returns a handler that accepts a JSON array of sub-requests
+// (or a multipart/mixed body of application/http parts), dispatches each one
+// through the app's router and returns the responses in order. A multipart
+// batch is answered part by part: each response is written and flushed as
+// soon as its sub-request completes. A JSON batch is answered with a single
+// JSON document, which may be wrapped in the app's envelope, so its responses
+// are collected and written together. Sub-requests can't be batches
+// themselves; they are answered with 400.
+func (app *App) BatchHandler(config *BatchConfig) http.HandlerFunc {
+	if config == nil {
+		config = &defaultBatchConfig
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 嵌套的批量请求会成倍放大单个请求的工作量
+		if r.Context().Value(batchSubRequestKey{}) != nil {
+			Error(w, r, http.StatusBadRequest, "nested_batch", "batch requests can't be nested")
+			return
+		}
+		mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "multipart/mixed" {
+			app.serveMultipartBatch(w, r, params["boundary"], config)
+			return
+		}
+		var requests []BatchRequest
+		if err := BindJSON(r, &requests); err != nil {
+			Error(w, r, http.StatusBadRequest, "invalid_batch", "body must be a JSON array of requests")
+			return
+		}
+		if config.MaxRequests > 0 && len(requests) > config.MaxRequests {
+			Error(w, r, http.StatusRequestEntityTooLarge, "batch_too_large", fmt.Sprintf("at most %d requests per batch", config.MaxRequests))
+			return
+		}
+		responses := make([]BatchResponse, 0, len(requests))
+		for _, item := range requests {
+			sub, err := http.NewRequestWithContext(r.Context(), strings.ToUpper(item.Method), item.Path, bytes.NewReader(item.Body))
+			if err != nil || !strings.HasPrefix(item.Path, "/") {
+				responses = append(responses, BatchResponse{Status: http.StatusBadRequest})
+				continue
+			}
+			for key, value := range item.Headers {
+				sub.Header.Set(key, value)
+			}
+			if len(item.Body) > 0 && sub.Header.Get("Content-Type") == "" {
+				sub.Header.Set("Content-Type", "application/json")
+			}
+			rec := app.dispatch(r, sub, config)
+			resp := BatchResponse{Status: rec.status, Headers: make(map[string]string, len(rec.header))}
+			for key := range rec.header {
+				resp.Headers[key] = rec.header.Get(key)
+			}
+			if body := rec.body.Bytes(); json.Valid(body) {
+				resp.Body = body
+			} else if len(body) > 0 {
+				resp.Body, _ = jsonCodec.Marshal(string(body))
+			}
+			responses = append(responses, resp)
+		}
+		Success(w, r, http.StatusOK, responses)
+	}
+}
+
+// batchPart 是已读取的multipart子请求
+type batchPart struct {
+	contentID string
+	raw       []byte
+}
+
+// serveMultipartBatch 先读取全部part，整个批量请求无效或过大时以400/413拒绝；
+// 之后逐个分发子请求，每个响应完成后立即作为一个part写出
+func (app *App) serveMultipartBatch(w http.ResponseWriter, r *http.Request, boundary string, config *BatchConfig) {
+	if boundary == "" {
+		http.Error(w, "Missing multipart boundary", http.StatusBadRequest)
+		return
+	}
+	reader := multipart.NewReader(r.Body, boundary)
+	var parts []batchPart
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Invalid multipart batch", http.StatusBadRequest)
+			return
+		}
+		if config.MaxRequests > 0 && len(parts) >= config.MaxRequests {
+			http.Error(w, "Batch Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		raw, err := io.ReadAll(part)
+		if err != nil {
+			http.Error(w, "Invalid multipart batch", http.StatusBadRequest)
+			return
+		}
+		parts = append(parts, batchPart{contentID: part.Header.Get("Content-ID"), raw: raw})
+	}
+	writer := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	for _, part := range parts {
+		partHeader := textproto.MIMEHeader{"Content-Type": {"application/http"}}
+		if part.contentID != "" {
+			partHeader.Set("Content-ID", part.contentID)
+		}
+		// 客户端读到下一个part的边界才知道上一个part已经结束，所以在分发前写出边界并flush
+		pw, _ := writer.CreatePart(partHeader)
+		rc.Flush()
+		sub, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(part.raw)))
+		var rec *batchRecorder
+		if err != nil {
+			rec = &batchRecorder{header: make(http.Header), status: http.StatusBadRequest}
+		} else {
+			sub = sub.WithContext(r.Context())
+			sub.RequestURI = ""
+			rec = app.dispatch(r, sub, config)
+		}
+		resp := &http.Response{
+			StatusCode:    rec.status,
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        rec.header,
+			ContentLength: int64(rec.body.Len()),
+			Body:          io.NopCloser(&rec.body),
+		}
+		resp.Write(pw)
+	}
+	writer.Close()
+}
+
+// dispatch 通过应用的路由处理单个子请求，并继承外层请求的认证相关请求头
+func (app *App) dispatch(outer, sub *http.Request, config *BatchConfig) *batchRecorder {
+	for _, key := range config.InheritHeaders {
+		if sub.Header.Get(key) == "" && outer.Header.Get(key) != "" {
+			sub.Header.Set(key, outer.Header.Get(key))
+		}
+	}
+	// 子请求不沿用外层请求的状态：放入nil，路由分发时为子请求创建新的状态（Memo、计时等）
+	ctx := context.WithValue(sub.Context(), requestStateKey{}, (*requestState)(nil))
+	sub = sub.WithContext(context.WithValue(ctx, batchSubRequestKey{}, true))
+	sub.RemoteAddr = outer.RemoteAddr
+	sub.Host = outer.Host
+	sub.TLS = outer.TLS
+	rec := &batchRecorder{header: make(http.Header)}
+	app.Handler().ServeHTTP(rec, sub)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec
+}
@@ -0,0 +1,326 @@
+// Package benchmarks measures the framework's hot paths next to plain
+// net/http and encoding/json baselines. It runs outside go test through
+// testing.Benchmark, so cmd/cyberbench can gate CI on regressions.
+package benchmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/cache"
+	"github.com/suonanjiexi/cyber/metrics"
+)
+
+// Benchmark is a named benchmark. Baseline names the plain net/http
+// counterpart it is reported next to.
+type Benchmark struct {
+	Name     string
+	Baseline string
+	Fn       func(b *testing.B)
+}
+
+// Result is the outcome of one benchmark.
+type Result struct {
+	Name        string  `json:"name"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+}
+
+// 基准测试的路由注册在该前缀下，避免与其他路由冲突
+const prefix = "/__bench"
+
+type payload struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Tags  []string `json:"tags"`
+}
+
+var samplePayload = payload{ID: 42, Name: "cyber", Email: "cyber@example.com", Tags: []string{"a", "b", "c"}}
+
+var sampleJSON, _ = json.Marshal(samplePayload)
+
+// discardWriter 丢弃响应，避免记录响应本身的开销
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func newDiscardWriter() *discardWriter {
+	return &discardWriter{header: make(http.Header)}
+}
+
+var routePaths = []string{"/users", "/users/{id}", "/users/{id}/orders", "/orders/{id}", "/items/{id}/reviews/{review}", "/static/{path...}"}
+
+func noop(w http.ResponseWriter, r *http.Request) {}
+
+func noopMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { next(w, r) }
+}
+
+// compose 套用n层空中间件，与App注册路由时组合中间件的方式相同
+func compose(handler http.HandlerFunc, n int) http.HandlerFunc {
+	for i := 0; i < n; i++ {
+		handler = noopMiddleware(handler)
+	}
+	return handler
+}
+
+// All returns the suite. It registers routes on the default mux under
+// /__bench, so call it once per process.
+func All() []Benchmark {
+	app := cyber.NewApp(nil)
+	app.SetMode(cyber.ReleaseMode)
+	mux := http.NewServeMux()
+	for _, p := range routePaths {
+		app.Get(prefix+p, noop)
+		mux.HandleFunc("GET "+prefix+p, noop)
+	}
+	// 路径参数由ServeMux保存在请求内部的切片中，读取时不分配map
+	app.Get(prefix+"/params/{id}/reviews/{review}", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.PathValue("id")
+		_ = r.PathValue("review")
+	})
+	chained := app.Group(prefix + "/chain")
+	for i := 0; i < 5; i++ {
+		chained.Use(noopMiddleware)
+	}
+	chained.Get("/{id}", noop)
+
+	routeReq, _ := http.NewRequest(http.MethodGet, prefix+"/items/7/reviews/9", nil)
+	paramReq, _ := http.NewRequest(http.MethodGet, prefix+"/params/7/reviews/9", nil)
+	chainReq, _ := http.NewRequest(http.MethodGet, prefix+"/chain/7", nil)
+	handler := app.Handler()
+
+	return []Benchmark{
+		{Name: "Routing/nethttp", Fn: serve(mux, routeReq)},
+		{Name: "Routing/cyber", Baseline: "Routing/nethttp", Fn: serve(handler, routeReq)},
+		{Name: "PathParams/cyber", Baseline: "Routing/cyber", Fn: serve(handler, paramReq)},
+		{Name: "Middleware5/cyber", Baseline: "Routing/cyber", Fn: serve(handler, chainReq)},
+		{Name: "Chain5/rebuilt", Fn: func(b *testing.B) {
+			w := newDiscardWriter()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				compose(noop, 5)(w, routeReq)
+			}
+		}},
+		{Name: "Chain5/precompiled", Baseline: "Chain5/rebuilt", Fn: func(b *testing.B) {
+			w := newDiscardWriter()
+			h := compose(noop, 5)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h(w, routeReq)
+			}
+		}},
+		{Name: "Metrics/mutex", Fn: func(b *testing.B) {
+			// 与metrics.RecordRequest更新相同的计数器，但由一把全局锁保护
+			var mu sync.Mutex
+			var requests, durations, maxNanos uint64
+			var classes [5]uint64
+			var buckets [14]uint64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					d := uint64(time.Millisecond)
+					mu.Lock()
+					requests++
+					classes[http.StatusOK/100-1]++
+					durations += d
+					if d > maxNanos {
+						maxNanos = d
+					}
+					buckets[0]++
+					mu.Unlock()
+				}
+			})
+		}},
+		{Name: "Metrics/sharded", Baseline: "Metrics/mutex", Fn: func(b *testing.B) {
+			m := metrics.New(metrics.Config{})
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					m.RecordRequest(http.StatusOK, time.Millisecond)
+				}
+			})
+		}},
+		{Name: "Cache/single-lock", Fn: mixedCache(newLockedStore(), 10)},
+		{Name: "Cache/sharded", Baseline: "Cache/single-lock", Fn: mixedCache(cache.NewMemoryStore(), 10)},
+		{Name: "Cache/sharded-write-heavy", Baseline: "Cache/sharded", Fn: mixedCache(cache.NewMemoryStore(), 50)},
+		{Name: "JSON/encoding_json", Fn: func(b *testing.B) {
+			w := newDiscardWriter()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(samplePayload)
+			}
+		}},
+		{Name: "JSON/cyber", Baseline: "JSON/encoding_json", Fn: func(b *testing.B) {
+			w := newDiscardWriter()
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cyber.Success(w, r, http.StatusOK, samplePayload)
+			}
+		}},
+		{Name: "Bind/encoding_json", Fn: func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var p payload
+				json.NewDecoder(bytes.NewReader(sampleJSON)).Decode(&p)
+			}
+		}},
+		{Name: "Bind/cyber", Baseline: "Bind/encoding_json", Fn: func(b *testing.B) {
+			r, _ := http.NewRequest(http.MethodPost, "/", nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var p payload
+				r.Body = readerBody{bytes.NewReader(sampleJSON)}
+				cyber.BindJSON(r, &p)
+			}
+		}},
+	}
+}
+
+var cacheKeys = func() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "GET example.com/items/" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+// mixedCache 并发读写store，writePercent是写操作所占的百分比
+func mixedCache(store cache.Store, writePercent int) func(b *testing.B) {
+	return func(b *testing.B) {
+		entry := &cache.Entry{Status: http.StatusOK, Body: sampleJSON}
+		for _, key := range cacheKeys {
+			store.Set(key, entry, time.Hour)
+		}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			i := rand.IntN(len(cacheKeys))
+			for pb.Next() {
+				i++
+				key := cacheKeys[i%len(cacheKeys)]
+				if i%100 < writePercent {
+					store.Set(key, entry, time.Hour)
+				} else {
+					store.Get(key)
+				}
+			}
+		})
+	}
+}
+
+// lockedStore 是分片之前的MemoryStore：一把读写锁保护整个map
+type lockedStore struct {
+	mu    sync.RWMutex
+	items map[string]lockedItem
+}
+
+type lockedItem struct {
+	entry   *cache.Entry
+	expires time.Time
+}
+
+func newLockedStore() *lockedStore {
+	return &lockedStore{items: make(map[string]lockedItem)}
+}
+
+func (s *lockedStore) Get(key string) (*cache.Entry, bool) {
+	s.mu.RLock()
+	item, ok := s.items[key]
+	s.mu.RUnlock()
+	if !ok || time.Now().After(item.expires) {
+		return nil, false
+	}
+	return item.entry, true
+}
+
+func (s *lockedStore) Set(key string, entry *cache.Entry, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = lockedItem{entry: entry, expires: time.Now().Add(ttl)}
+}
+
+func (s *lockedStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+}
+
+type readerBody struct {
+	*bytes.Reader
+}
+
+func (readerBody) Close() error { return nil }
+
+func serve(handler http.Handler, req *http.Request) func(b *testing.B) {
+	return func(b *testing.B) {
+		w := newDiscardWriter()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			handler.ServeHTTP(w, req)
+		}
+	}
+}
+
+// Run executes the benchmarks whose name contains filter (all when empty).
+func Run(suite []Benchmark, filter string) []Result {
+	var results []Result
+	for _, bm := range suite {
+		if filter != "" && !strings.Contains(bm.Name, filter) {
+			continue
+		}
+		r := testing.Benchmark(bm.Fn)
+		results = append(results, Result{
+			Name:        bm.Name,
+			NsPerOp:     float64(r.T.Nanoseconds()) / float64(r.N),
+			AllocsPerOp: r.AllocsPerOp(),
+			BytesPerOp:  r.AllocedBytesPerOp(),
+		})
+	}
+	return results
+}
+
+// Regression is a benchmark that got slower than allowed.
+type Regression struct {
+	Name     string
+	Baseline float64
+	Current  float64
+	// 变慢的百分比
+	Percent float64
+}
+
+// Compare returns the benchmarks in current that are more than maxPercent
+// slower than in baseline. Benchmarks missing from baseline are ignored.
+func Compare(baseline, current []Result, maxPercent float64) []Regression {
+	previous := make(map[string]Result, len(baseline))
+	for _, r := range baseline {
+		previous[r.Name] = r
+	}
+	var regressions []Regression
+	for _, r := range current {
+		old, ok := previous[r.Name]
+		if !ok || old.NsPerOp <= 0 {
+			continue
+		}
+		percent := (r.NsPerOp - old.NsPerOp) / old.NsPerOp * 100
+		if percent > maxPercent {
+			regressions = append(regressions, Regression{Name: r.Name, Baseline: old.NsPerOp, Current: r.NsPerOp, Percent: percent})
+		}
+	}
+	return regressions
+}
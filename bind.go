@@ -0,0 +1,417 @@
+package cyber
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BindError reports a value that couldn't be bound to a struct field.
+type BindError struct {
+	// Source 是值的来源，例如 uri、header
+	Source string
+	// Name 是标签中的名称，例如路径参数名
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("cyber: %s %q: %v", e.Source, e.Name, e.Err)
+	}
+	return fmt.Sprintf("cyber: %s %q: invalid value %q: %v", e.Source, e.Name, e.Value, e.Err)
+}
+
+func (e *BindError) Unwrap() error { return e.Err }
+
+// ErrRequired is wrapped by BindError when a field tagged
+// binding:"required" has no value.
+var ErrRequired = errors.New("required")
+
+// BindURI sets the fields of the struct v points to from path parameters,
+// named by their uri tag, e.g. `uri:"id"` for the pattern /users/{id}.
+// Strings, integers, floats, booleans, time.Duration, time.Time (RFC 3339),
+// encoding.TextUnmarshaler and pointers to them are converted. A field
+// tagged binding:"required" must have a non-empty value. v is validated
+// when it implements Validator.
+func BindURI(r *http.Request, v interface{}) error {
+	if err := bindTagged(v, "uri", pathValues(r)); err != nil {
+		return err
+	}
+	return validate(v)
+}
+
+// BindHeader sets the fields of the struct v points to from request
+// headers, named by their header tag, e.g. `header:"X-Tenant-ID"`. Values
+// are converted as in BindURI; a slice field receives every value of a
+// repeated header. v is validated when it implements Validator.
+func BindHeader(r *http.Request, v interface{}) error {
+	if err := bindTagged(v, "header", r.Header.Values); err != nil {
+		return err
+	}
+	return validate(v)
+}
+
+// GetHeader returns the first value of the request header key converted to
+// T as in BindURI, e.g. GetHeader[int](r, "X-Page-Size"). A missing header
+// returns a BindError wrapping ErrRequired.
+func GetHeader[T any](r *http.Request, key string) (T, error) {
+	var value T
+	raw := r.Header.Values(key)
+	if len(raw) == 0 || raw[0] == "" {
+		return value, &BindError{Source: "header", Name: key, Err: ErrRequired}
+	}
+	if err := setValue(reflect.ValueOf(&value).Elem(), raw[0]); err != nil {
+		return value, &BindError{Source: "header", Name: key, Value: raw[0], Err: err}
+	}
+	return value, nil
+}
+
+func pathValues(r *http.Request) func(name string) []string {
+	return func(name string) []string {
+		if value := r.PathValue(name); value != "" {
+			return []string{value}
+		}
+		return nil
+	}
+}
+
+// hasTagged 判断v是否指向带有tag标签字段的结构体
+func hasTagged(v interface{}, tag string) bool {
+	typ := reflect.TypeOf(v)
+	if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	return len(boundFields(typ.Elem(), tag)) > 0
+}
+
+func validate(v interface{}) error {
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// Fields records which fields of a bound struct were present in the
+// request, telling an absent field from one sent with its zero value, e.g.
+// for PATCH-style partial updates. Embed it in the request type:
+//
+//	type UpdateUser struct {
+//		cyber.Fields
+//		Name  string `json:"name"`
+//		Limit int    `header:"X-Limit" default:"10"`
+//	}
+//
+// and check req.Has("Name") after binding. Names are Go field names.
+// Fields filled from a default tag are not present. Pointer fields are an
+// alternative: binding leaves them nil when the value is absent.
+type Fields struct {
+	present map[string]bool
+}
+
+// Has reports whether the field was present in the request.
+func (f Fields) Has(name string) bool {
+	return f.present[name]
+}
+
+// Provided returns the names of the fields present in the request, sorted.
+func (f Fields) Provided() []string {
+	names := make([]string, 0, len(f.present))
+	for name := range f.present {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *Fields) markPresent(name string) {
+	if f.present == nil {
+		f.present = make(map[string]bool)
+	}
+	f.present[name] = true
+}
+
+// presenceRecorder 由内嵌了Fields的结构体指针实现
+type presenceRecorder interface {
+	markPresent(name string)
+}
+
+// boundField 是带有绑定标签的字段
+type boundField struct {
+	index []int
+	// field 是Go字段名，name 是标签中的名称
+	field    string
+	name     string
+	required bool
+	// def 是default标签的值，hasDefault 区分空字符串默认值和没有默认值
+	def        string
+	hasDefault bool
+}
+
+type boundFieldsKey struct {
+	typ reflect.Type
+	tag string
+}
+
+// 每个类型和标签解析一次字段
+var boundFieldsCache sync.Map
+
+func boundFields(typ reflect.Type, tag string) []boundField {
+	key := boundFieldsKey{typ, tag}
+	if fields, ok := boundFieldsCache.Load(key); ok {
+		return fields.([]boundField)
+	}
+	var fields []boundField
+	collectFields(typ, tag, nil, &fields)
+	boundFieldsCache.Store(key, fields)
+	return fields
+}
+
+func collectFields(typ reflect.Type, tag string, index []int, fields *[]boundField) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+		name := field.Tag.Get(tag)
+		if name == "" || name == "-" {
+			// 没有标签的内嵌结构体，绑定其中的字段
+			if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+				collectFields(field.Type, tag, fieldIndex, fields)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		required := false
+		for _, option := range strings.Split(field.Tag.Get("binding"), ",") {
+			if strings.TrimSpace(option) == "required" {
+				required = true
+			}
+		}
+		def, hasDefault := field.Tag.Lookup("default")
+		*fields = append(*fields, boundField{index: fieldIndex, field: field.Name, name: name, required: required, def: def, hasDefault: hasDefault})
+	}
+}
+
+// bindTagged 把lookup返回的值绑定到v中带有tag标签的字段
+func bindTagged(v interface{}, tag string, lookup func(name string) []string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cyber: bind %s: expected a pointer to a struct, got %T", tag, v)
+	}
+	recorder, _ := v.(presenceRecorder)
+	rv = rv.Elem()
+	for _, field := range boundFields(rv.Type(), tag) {
+		values := lookup(field.name)
+		if len(values) == 0 || values[0] == "" {
+			switch {
+			case field.required:
+				return &BindError{Source: tag, Name: field.name, Err: ErrRequired}
+			case field.hasDefault:
+				if err := setField(rv.FieldByIndex(field.index), []string{field.def}); err != nil {
+					return &BindError{Source: tag, Name: field.name, Value: field.def, Err: fmt.Errorf("default: %w", err)}
+				}
+			}
+			continue
+		}
+		if err := setField(rv.FieldByIndex(field.index), values); err != nil {
+			return &BindError{Source: tag, Name: field.name, Value: values[0], Err: err}
+		}
+		if recorder != nil {
+			recorder.markPresent(field.field)
+		}
+	}
+	return nil
+}
+
+// bodyFieldsKey 是请求体字段缓存的键，与boundFieldsKey区分
+type bodyFieldsKey struct {
+	typ reflect.Type
+}
+
+// bodyFields 返回由请求体绑定的字段：带default标签但没有uri和header标签的字段
+func bodyFields(typ reflect.Type) []boundField {
+	if fields, ok := boundFieldsCache.Load(bodyFieldsKey{typ}); ok {
+		return fields.([]boundField)
+	}
+	var fields []boundField
+	for _, field := range boundFields(typ, "default") {
+		f := typ.FieldByIndex(field.index)
+		if f.Tag.Get("uri") == "" && f.Tag.Get("header") == "" {
+			fields = append(fields, field)
+		}
+	}
+	boundFieldsCache.Store(bodyFieldsKey{typ}, fields)
+	return fields
+}
+
+// applyDefaults 在解码请求体之前填入默认值，请求体中出现的字段会覆盖它们
+func applyDefaults(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	rv = rv.Elem()
+	for _, field := range bodyFields(rv.Type()) {
+		if err := setField(rv.FieldByIndex(field.index), []string{field.def}); err != nil {
+			return &BindError{Source: "body", Name: field.field, Value: field.def, Err: fmt.Errorf("default: %w", err)}
+		}
+	}
+	return nil
+}
+
+// jsonFieldNames 返回JSON键（小写）到Go字段名的映射，用于记录请求体中出现的字段
+func jsonFieldNames(typ reflect.Type) map[string]string {
+	if names, ok := jsonNamesCache.Load(typ); ok {
+		return names.(map[string]string)
+	}
+	names := make(map[string]string)
+	collectJSONNames(typ, names)
+	jsonNamesCache.Store(typ, names)
+	return names
+}
+
+var jsonNamesCache sync.Map
+
+func collectJSONNames(typ reflect.Type, names map[string]string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectJSONNames(field.Type, names)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		// encoding/json 匹配键时不区分大小写
+		key := strings.ToLower(name)
+		if _, ok := names[key]; !ok {
+			names[key] = field.Name
+		}
+	}
+}
+
+// markJSONPresent 记录JSON对象中出现的顶层键对应的字段
+func markJSONPresent(v interface{}, data []byte) {
+	recorder, ok := v.(presenceRecorder)
+	if !ok {
+		return
+	}
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) != nil {
+		return
+	}
+	names := jsonFieldNames(reflect.TypeOf(v).Elem())
+	for key := range object {
+		if field, ok := names[strings.ToLower(key)]; ok {
+			recorder.markPresent(field)
+		}
+	}
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setField 把字符串值转换为字段的类型，切片字段接收所有值
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	switch field.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// numError 去掉strconv错误中重复的函数名和输入值
+func numError(err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err
+	}
+	return err
+}
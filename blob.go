@@ -0,0 +1,89 @@
+package cyber
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// http.DetectContentType最多检查的字节数
+const sniffLen = 512
+
+// svgCSP 禁止SVG中的脚本和外部资源，直接打开SVG链接时不会执行其中的脚本
+const svgCSP = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+
+// Blob writes data with the given content type. An empty contentType is
+// sniffed from data, but types a browser would render as a page (HTML, XML,
+// SVG) are never guessed: such data is sent as application/octet-stream,
+// so user uploads can't be turned into scripts on the site's origin. The
+// response always carries X-Content-Type-Options: nosniff.
+func Blob(w http.ResponseWriter, r *http.Request, statusCode int, contentType string, data []byte) {
+	if contentType == "" {
+		contentType = sniffContentType(data)
+	}
+	setBlobHeaders(w.Header(), contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(statusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing blob response: %v", err)
+	}
+}
+
+// ImageFromReader streams an image from reader, e.g. a file or an object
+// storage download. size is the Content-Length, or -1 if unknown. An empty
+// contentType is sniffed from the first bytes; if they aren't a raster
+// image the content is sent as application/octet-stream. An error reading
+// those first bytes is returned with nothing written; later errors are
+// returned after the response has started.
+func ImageFromReader(w http.ResponseWriter, r *http.Request, statusCode int, contentType string, size int64, reader io.Reader) error {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	head = head[:n]
+	if contentType == "" {
+		contentType = sniffContentType(head)
+		if !strings.HasPrefix(contentType, "image/") {
+			contentType = "application/octet-stream"
+		}
+	}
+	setBlobHeaders(w.Header(), contentType)
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.WriteHeader(statusCode)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// sniffContentType 识别data的类型，会被浏览器当作页面渲染的类型一律视为二进制
+func sniffContentType(data []byte) string {
+	contentType := http.DetectContentType(data)
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch mediaType {
+	case "text/html", "text/xml", "application/xml", "image/svg+xml":
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+func setBlobHeaders(header http.Header, contentType string) {
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if mediaType, _, _ := strings.Cut(contentType, ";"); strings.TrimSpace(strings.ToLower(mediaType)) == "image/svg+xml" && header.Get("Content-Security-Policy") == "" {
+		header.Set("Content-Security-Policy", svgCSP)
+	}
+}
@@ -0,0 +1,91 @@
+package cyber
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// 路由Meta中控制BodyBytes的键
+const (
+	// MetaBody 为 "stream" 时BodyBytes不缓冲该路由的请求体，用于上传、流式接口等请求体很大或持续到达的路由
+	MetaBody = "body"
+	// MetaMaxBody 是BodyBytes缓冲的最大字节数，默认DefaultMaxBodyBytes
+	MetaMaxBody = "max_body"
+)
+
+// DefaultMaxBodyBytes is the largest body BodyBytes buffers unless the
+// route's MetaMaxBody says otherwise.
+const DefaultMaxBodyBytes = 10 << 20
+
+// ErrBodyStreamed is returned by BodyBytes on routes whose MetaBody is
+// "stream"; r.Body is left untouched for the handler to stream.
+var ErrBodyStreamed = errors.New("cyber: route streams its request body")
+
+// bufferedBody 是BodyBytes读取的请求体，每个请求只读取一次
+type bufferedBody struct {
+	data []byte
+	err  error
+}
+
+// BodyBytes reads the whole request body once and returns it, so
+// middlewares can inspect it, e.g. to verify a signature or to audit it,
+// without consuming it: r.Body is reset to the buffered bytes on every
+// call, so Bind and later BodyBytes calls read the full body again. A body
+// larger than the route's limit yields a 413 error for Abort, with r.Body
+// still delivering all of it.
+func BodyBytes(r *http.Request) ([]byte, error) {
+	state := stateFromRequest(r)
+	var route *Route
+	if state != nil {
+		state.mu.Lock()
+		buffered := state.body
+		route = state.route
+		state.mu.Unlock()
+		if buffered != nil {
+			if buffered.err == nil {
+				r.Body = io.NopCloser(bytes.NewReader(buffered.data))
+			}
+			return buffered.data, buffered.err
+		}
+		if route != nil && route.Meta[MetaBody] == "stream" {
+			return nil, ErrBodyStreamed
+		}
+	}
+	buffered := readBody(r, maxBodyBytes(route))
+	if state != nil {
+		state.mu.Lock()
+		state.body = buffered
+		state.mu.Unlock()
+	}
+	return buffered.data, buffered.err
+}
+
+func maxBodyBytes(route *Route) int64 {
+	if route != nil {
+		if n, err := strconv.ParseInt(route.Meta[MetaMaxBody], 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return DefaultMaxBodyBytes
+}
+
+func readBody(r *http.Request, limit int64) *bufferedBody {
+	if r.Body == nil || r.Body == http.NoBody {
+		return &bufferedBody{data: []byte{}}
+	}
+	original := r.Body
+	data, err := io.ReadAll(io.LimitReader(original, limit+1))
+	if err == nil && int64(len(data)) > limit {
+		err = NewHTTPError(http.StatusRequestEntityTooLarge, "body_too_large", "Request body is too large")
+	}
+	if err != nil {
+		// 已读取的部分与剩余部分拼接，流式读取的handler仍能读到完整的请求体
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(data), original), original}
+		return &bufferedBody{err: err}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return &bufferedBody{data: data}
+}
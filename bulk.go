@@ -0,0 +1,166 @@
+package cyber
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+type BulkConfig struct {
+	// 单次请求允许的最大条目数，默认1000
+	MaxItems int
+	// 同时处理的条目数，默认4；为1时按顺序处理
+	Concurrency int
+}
+
+var defaultBulkConfig = BulkConfig{
+	MaxItems:    1000,
+	Concurrency: 4,
+}
+
+// BulkResult is the outcome of one item of a bulk request. Index is the
+// item's position in the request array.
+type BulkResult[Resp any] struct {
+	Index  int            `json:"index"`
+	Status int            `json:"status"`
+	Data   *Resp          `json:"data,omitempty"`
+	Error  *ErrorResponse `json:"error,omitempty"`
+}
+
+// BulkResponse is the body written by Bulk, with one result per item in
+// request order.
+type BulkResponse[Resp any] struct {
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Results   []BulkResult[Resp] `json:"results"`
+}
+
+// Bulk adapts a function handling one item to a handler for bulk create,
+// update or delete endpoints. The body is a JSON array; each item is bound
+// and validated like a Typed request, and an invalid item fails alone
+// without fn being called for it. Valid items are processed by up to
+// Concurrency goroutines, so fn must be safe for concurrent use. The
+// response is 200 when every item succeeded and 207 Multi-Status with the
+// per-item results otherwise; errors are reported as with Abort.
+func Bulk[Req, Resp any](config BulkConfig, fn func(r *http.Request, item Req) (Resp, error)) http.HandlerFunc {
+	if config.MaxItems <= 0 {
+		config.MaxItems = defaultBulkConfig.MaxItems
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultBulkConfig.Concurrency
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var items []json.RawMessage
+		if err := BindJSON(r, &items); err != nil {
+			Error(w, r, http.StatusBadRequest, "invalid_body", "body must be a JSON array of items")
+			return
+		}
+		if len(items) == 0 {
+			Error(w, r, http.StatusBadRequest, "invalid_body", "no items")
+			return
+		}
+		if len(items) > config.MaxItems {
+			Error(w, r, http.StatusRequestEntityTooLarge, "bulk_too_large", fmt.Sprintf("at most %d items per request", config.MaxItems))
+			return
+		}
+		results := make([]BulkResult[Resp], len(items))
+		reqs := make([]Req, len(items))
+		var valid []int
+		for i, item := range items {
+			results[i].Index = i
+			if err := bindItem(item, &reqs[i]); err != nil {
+				results[i].Status, results[i].Error = http.StatusBadRequest, &ErrorResponse{Code: "invalid_item", Message: err.Error()}
+				continue
+			}
+			if v, ok := interface{}(&reqs[i]).(Validator); ok {
+				if err := v.Validate(); err != nil {
+					results[i].Status, results[i].Error = http.StatusUnprocessableEntity, &ErrorResponse{Code: "validation_failed", Message: err.Error()}
+					continue
+				}
+			}
+			valid = append(valid, i)
+		}
+		forEachLimit(len(valid), config.Concurrency, func(n int) {
+			i := valid[n]
+			if err := r.Context().Err(); err != nil {
+				// 请求被取消后不再处理剩余条目
+				results[i].Status, results[i].Error = http.StatusServiceUnavailable, &ErrorResponse{Code: "cancelled", Message: err.Error()}
+				return
+			}
+			// 条目在其他goroutine中处理，恢复中间件捕获不到这里的panic
+			defer func() {
+				if err := recover(); err != nil {
+					results[i].Status, results[i].Error = bulkError(fmt.Errorf("panic: %v\n%s", err, debug.Stack()))
+				}
+			}()
+			resp, err := fn(r, reqs[i])
+			if err != nil {
+				results[i].Status, results[i].Error = bulkError(err)
+				return
+			}
+			results[i].Status, results[i].Data = http.StatusOK, &resp
+		})
+		out := BulkResponse[Resp]{Results: results}
+		for _, result := range results {
+			if result.Error == nil {
+				out.Succeeded++
+			} else {
+				out.Failed++
+			}
+		}
+		status := http.StatusOK
+		if out.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+		Success(w, r, status, out)
+	}
+}
+
+// bindItem 像Typed一样绑定单个条目：默认值、字段出现情况
+func bindItem(data json.RawMessage, v interface{}) error {
+	if err := applyDefaults(v); err != nil {
+		return err
+	}
+	if err := jsonCodec.Unmarshal(data, v); err != nil {
+		return err
+	}
+	markJSONPresent(v, data)
+	return nil
+}
+
+// bulkError 把处理错误转换为条目结果，非HTTPError记录日志后作为500返回
+func bulkError(err error) (int, *ErrorResponse) {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		log.Printf("Bulk item error: %v", err)
+		return http.StatusInternalServerError, &ErrorResponse{Code: "internal_error", Message: "Internal Server Error"}
+	}
+	return httpErr.Status, &ErrorResponse{Code: httpErr.Code, Message: httpErr.Message}
+}
+
+// forEachLimit 用最多limit个goroutine对0到n-1调用fn，全部返回后结束
+func forEachLimit(n, limit int, fn func(i int)) {
+	if limit > n {
+		limit = n
+	}
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < limit; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suonanjiexi/cyber/pathmatch"
+)
+
+// Entry is a cached response.
+type Entry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Vary 是生成该条目时响应的Vary请求头名称，只存在于变体索引条目中
+	Vary []string
+}
+
+type Config struct {
+	Store Store
+	// 响应没有声明max-age/s-maxage时使用的缓存时间
+	TTL time.Duration
+	// 不缓存的路径，支持通配符、正则和方法限定，见pathmatch.Matcher
+	SkipPaths []string
+	// 缓存带Cookie的请求，默认不缓存；只应在响应不依赖Cookie或声明了 Vary: Cookie 时开启
+	AllowCookies bool
+}
+
+const defaultTTL = 1 * time.Minute
+
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// Middleware caches GET/HEAD responses as a shared cache would: responses
+// are keyed by the request headers named in their Vary header, and responses
+// with Set-Cookie, Vary: *, or Cache-Control private/no-store are not cached.
+// Requests with Authorization, or with Cookie unless AllowCookies is set,
+// bypass the cache. A handler that flushes, e.g. for server-sent events,
+// is streamed through and its response is not cached.
+func Middleware(config Config) func(http.HandlerFunc) http.HandlerFunc {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	skip := pathmatch.Must(config.SkipPaths...)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !cacheableRequest(r, skip, config.AllowCookies) {
+				next(w, r)
+				return
+			}
+			baseKey := BaseKey(r)
+			if entry, ok := lookup(config.Store, baseKey, r); ok {
+				writeEntry(w, r, entry, "HIT")
+				return
+			}
+			bw := &bufferWriter{ResponseWriter: w, header: make(http.Header)}
+			next(bw, r)
+			if bw.streaming {
+				return
+			}
+			status := bw.Status()
+			entry := &Entry{Status: status, Header: bw.header, Body: bw.body.Bytes()}
+			// HEAD请求没有响应体，不能用来填充与GET共享的缓存
+			if ttl, ok := cacheTTL(status, bw.header, config.TTL); ok && r.Method == http.MethodGet {
+				vary := varyHeaders(bw.header)
+				config.Store.Set(baseKey, &Entry{Vary: vary}, ttl)
+				config.Store.Set(variantKey(baseKey, vary, r), entry, ttl)
+			}
+			writeEntry(w, r, entry, "MISS")
+		}
+	}
+}
+
+// BaseKey returns the cache key of a request before Vary is applied. HEAD
+// requests share entries with GET.
+func BaseKey(r *http.Request) string {
+	return http.MethodGet + " " + r.Host + r.URL.RequestURI()
+}
+
+// Invalidate removes the cached variants index for the request, which makes
+// every variant unreachable.
+func Invalidate(store Store, r *http.Request) {
+	store.Delete(BaseKey(r))
+}
+
+func cacheableRequest(r *http.Request, skip *pathmatch.Matcher, allowCookies bool) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	// 共享缓存不缓存带认证信息的请求，Cookie通常也携带会话
+	if r.Header.Get("Authorization") != "" || (!allowCookies && r.Header.Get("Cookie") != "") {
+		return false
+	}
+	if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-store") {
+		return false
+	}
+	return !skip.Match(r)
+}
+
+func lookup(store Store, baseKey string, r *http.Request) (*Entry, bool) {
+	index, ok := store.Get(baseKey)
+	if !ok {
+		return nil, false
+	}
+	return store.Get(variantKey(baseKey, index.Vary, r))
+}
+
+func variantKey(baseKey string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(baseKey)
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// varyHeaders 返回规范化、排序后的Vary请求头名称
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cacheTTL 根据响应判断是否可以缓存以及缓存时间
+func cacheTTL(status int, header http.Header, defaultTTL time.Duration) (time.Duration, bool) {
+	if !cacheableStatus[status] || header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	for _, name := range varyHeaders(header) {
+		if name == "*" {
+			return 0, false
+		}
+	}
+	ttl := defaultTTL
+	maxAge, sMaxAge := -1, -1
+	for _, directive := range strings.Split(strings.ToLower(header.Get("Cache-Control")), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch key {
+		case "private", "no-store", "no-cache":
+			return 0, false
+		case "max-age":
+			maxAge, _ = strconv.Atoi(strings.Trim(value, `"`))
+		case "s-maxage":
+			sMaxAge, _ = strconv.Atoi(strings.Trim(value, `"`))
+		}
+	}
+	if sMaxAge >= 0 {
+		ttl = time.Duration(sMaxAge) * time.Second
+	} else if maxAge >= 0 {
+		ttl = time.Duration(maxAge) * time.Second
+	}
+	return ttl, ttl > 0
+}
+
+func writeEntry(w http.ResponseWriter, r *http.Request, entry *Entry, status string) {
+	header := w.Header()
+	for key, values := range entry.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Set("X-Cache", status)
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		w.Write(entry.Body)
+	}
+}
+
+// bufferWriter 缓存响应头和响应体，直到确定是否可以缓存
+type bufferWriter struct {
+	http.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+	// streaming 表示handler调用过Flush，响应直接透传且不缓存
+	streaming bool
+}
+
+func (bw *bufferWriter) Header() http.Header {
+	if bw.streaming {
+		return bw.ResponseWriter.Header()
+	}
+	return bw.header
+}
+
+func (bw *bufferWriter) WriteHeader(statusCode int) {
+	if bw.status == 0 {
+		bw.status = statusCode
+	}
+}
+
+func (bw *bufferWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	if bw.streaming {
+		return bw.ResponseWriter.Write(b)
+	}
+	return bw.body.Write(b)
+}
+
+// Flush 放弃缓存：写出已缓冲的响应，之后的写入直接透传
+func (bw *bufferWriter) Flush() {
+	if !bw.streaming {
+		bw.streaming = true
+		bw.status = bw.Status()
+		header := bw.ResponseWriter.Header()
+		for key, values := range bw.header {
+			header[key] = values
+		}
+		header.Set("X-Cache", "MISS")
+		bw.ResponseWriter.WriteHeader(bw.status)
+		bw.ResponseWriter.Write(bw.body.Bytes())
+		bw.body.Reset()
+	}
+	http.NewResponseController(bw.ResponseWriter).Flush()
+}
+
+func (bw *bufferWriter) Unwrap() http.ResponseWriter { return bw.ResponseWriter }
+
+func (bw *bufferWriter) Status() int {
+	if bw.status == 0 {
+		return http.StatusOK
+	}
+	return bw.status
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// Store holds cached responses. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry, ttl time.Duration)
+	Delete(key string)
+}
+
+type memoryItem struct {
+	entry   *Entry
+	expires time.Time
+}
+
+// 分片数，必须是2的幂
+const memoryShards = 64
+
+// memoryShard 是MemoryStore的一个分片，各分片独立加锁
+type memoryShard struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+	// sweepAt 是下次清理过期条目时的条目数
+	sweepAt int
+}
+
+// MemoryStore is an in-process Store. Keys are spread over independently
+// locked shards, so concurrent requests for different keys rarely contend.
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards [memoryShards]memoryShard
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{seed: maphash.MakeSeed()}
+	for i := range s.shards {
+		s.shards[i].items = make(map[string]memoryItem)
+		s.shards[i].sweepAt = minSweep
+	}
+	return s
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	return &s.shards[maphash.String(s.seed, key)&(memoryShards-1)]
+}
+
+// Get only takes a read lock. Expired entries are reported as misses and
+// left for Set to sweep, so Get never has to upgrade to a write lock.
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	item, ok := shard.items[key]
+	shard.mu.RUnlock()
+	if !ok || time.Now().After(item.expires) {
+		return nil, false
+	}
+	return item.entry, true
+}
+
+func (s *MemoryStore) Set(key string, entry *Entry, ttl time.Duration) {
+	shard := s.shard(key)
+	now := time.Now()
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.items[key] = memoryItem{entry: entry, expires: now.Add(ttl)}
+	if len(shard.items) >= shard.sweepAt {
+		shard.sweep(now)
+	}
+}
+
+func (s *MemoryStore) Delete(key string) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	delete(shard.items, key)
+}
+
+// 分片条目数达到该值后才开始清理
+const minSweep = 64
+
+// sweep 删除过期条目，并把下次清理的阈值设为剩余条目数的两倍，使清理的开销均摊到每次Set
+func (shard *memoryShard) sweep(now time.Time) {
+	for key, item := range shard.items {
+		if now.After(item.expires) {
+			delete(shard.items, key)
+		}
+	}
+	shard.sweepAt = 2 * len(shard.items)
+	if shard.sweepAt < minSweep {
+		shard.sweepAt = minSweep
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+type WarmConfig struct {
+	// 同时预热的请求数，默认4
+	Concurrency int
+	// 预热请求的Host（包括端口），应与线上请求一致，否则缓存键不同
+	Host string
+	// 预热请求附带的请求头，例如Accept-Encoding，使预热的变体与线上请求匹配
+	Header http.Header
+}
+
+const defaultWarmConcurrency = 4
+
+// Warmup is a cache warming run.
+type Warmup struct {
+	paths  []string
+	config WarmConfig
+	once   sync.Once
+	mu     sync.Mutex
+	done   int
+	failed []string
+	finish chan struct{}
+}
+
+// NewWarmup prepares warming paths; Start runs it.
+func NewWarmup(paths []string, config WarmConfig) *Warmup {
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultWarmConcurrency
+	}
+	return &Warmup{paths: paths, config: config, finish: make(chan struct{})}
+}
+
+// Warm requests each path with GET through handler, in process, so cache
+// middleware on those routes stores the responses before traffic arrives.
+// It returns immediately; use Wait or Check to follow progress.
+func Warm(ctx context.Context, handler http.Handler, paths []string, config WarmConfig) *Warmup {
+	wu := NewWarmup(paths, config)
+	wu.Start(ctx, handler)
+	return wu
+}
+
+// Start begins warming in the background; later calls do nothing.
+// Cancelling ctx stops it.
+func (wu *Warmup) Start(ctx context.Context, handler http.Handler) {
+	wu.once.Do(func() { go wu.run(ctx, handler) })
+}
+
+func (wu *Warmup) run(ctx context.Context, handler http.Handler) {
+	defer close(wu.finish)
+	sem := make(chan struct{}, wu.config.Concurrency)
+	var wg sync.WaitGroup
+	for _, path := range wu.paths {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			wu.record(path, warmPath(ctx, handler, path, wu.config))
+		}(path)
+	}
+	wg.Wait()
+	if len(wu.failed) > 0 {
+		log.Printf("Cache warmed %d/%d path(s), failed: %v", len(wu.paths)-len(wu.failed), len(wu.paths), wu.failed)
+	} else {
+		log.Printf("Cache warmed %d path(s)", len(wu.paths))
+	}
+}
+
+func warmPath(ctx context.Context, handler http.Handler, path string, config WarmConfig) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	if config.Host != "" {
+		r.Host = config.Host
+	}
+	for key, values := range config.Header {
+		r.Header[key] = values
+	}
+	r.RemoteAddr = "127.0.0.1:0"
+	w := &discardWriter{header: make(http.Header)}
+	handler.ServeHTTP(w, r)
+	if w.Status() >= http.StatusBadRequest {
+		return fmt.Errorf("status %d", w.Status())
+	}
+	return nil
+}
+
+func (wu *Warmup) record(path string, err error) {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	wu.done++
+	if err != nil {
+		wu.failed = append(wu.failed, path)
+	}
+}
+
+// Progress returns how many paths were requested and which failed.
+func (wu *Warmup) Progress() (done, total int, failed []string) {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	return wu.done, len(wu.paths), append([]string(nil), wu.failed...)
+}
+
+// Wait blocks until warming finished or ctx is done.
+func (wu *Warmup) Wait(ctx context.Context) error {
+	select {
+	case <-wu.finish:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Check reports an error until warming finished, for use as a readiness
+// check. Failed paths don't keep the app unready; they are logged.
+func (wu *Warmup) Check(ctx context.Context) error {
+	select {
+	case <-wu.finish:
+		return nil
+	default:
+		done, total, _ := wu.Progress()
+		return fmt.Errorf("cache: warming %d/%d", done, total)
+	}
+}
+
+// discardWriter 丢弃预热响应的响应体，只记录状态码
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header { return w.header }
+
+func (w *discardWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,42 @@
+// Command cyber scaffolds and inspects services built on the cyber framework.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: cyber <command> [arguments]
+
+commands:
+  new <module path>                 create a project skeleton
+  gen handler|middleware|model <Name> generate a file in the current project
+  routes <binary>                   print the route table of a built binary
+  openapi <binary>                  emit an OpenAPI 3 spec from the route table
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "new":
+		err = runNew(args)
+	case "gen":
+		err = runGen(args)
+	case "routes":
+		err = runRoutes(args)
+	case "openapi":
+		err = runOpenAPI(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cyber %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"text/tabwriter"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// loadRoutes 以DumpRoutesEnv运行已构建的程序，读取其导出的路由表
+func loadRoutes(args []string) (*cyber.RouteManifest, error) {
+	if len(args) != 1 {
+		return nil, errors.New("a binary path is required")
+	}
+	cmd := exec.Command(args[0])
+	cmd.Env = append(os.Environ(), cyber.DumpRoutesEnv+"=1")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %w", args[0], err)
+	}
+	var manifest cyber.RouteManifest
+	if err := json.Unmarshal(out, &manifest); err != nil {
+		return nil, fmt.Errorf("%s did not print a route table; does it call app.Run? %w", args[0], err)
+	}
+	return &manifest, nil
+}
+
+func runRoutes(args []string) error {
+	manifest, err := loadRoutes(args)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tNAME\tHANDLER")
+	for _, route := range manifest.Routes {
+		method := route.Method
+		if method == "" {
+			method = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", method, route.Pattern, route.Name, route.Handler)
+	}
+	return tw.Flush()
+}
+
+func runOpenAPI(args []string) error {
+	manifest, err := loadRoutes(args)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cyber.BuildOpenAPI(args[0], "0.0.0", manifest.Routes))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+var projectFiles = map[string]string{
+	"go.mod": `module {{.Module}}
+
+go 1.22
+`,
+	"main.go": `package main
+
+import (
+	"log"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/middleware"
+
+	"{{.Module}}/routers"
+)
+
+func main() {
+	app := cyber.NewApp(nil)
+	app.Use(middleware.Recovery)
+	app.Use(middleware.Logger)
+	routers.Register(app)
+	if err := app.Run(); err != nil {
+		log.Printf("Server error: %v", err)
+	}
+}
+`,
+	"routers/routers.go": `package routers
+
+import (
+	"net/http"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+func Register(app *cyber.App) {
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		cyber.Success(w, r, http.StatusOK, "Hello, World!")
+	})
+}
+`,
+}
+
+var genTemplates = map[string]struct {
+	dir  string
+	text string
+}{
+	"handler": {"routers", `package routers
+
+import (
+	"net/http"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+func {{.Name}}(w http.ResponseWriter, r *http.Request) {
+	cyber.Success(w, r, http.StatusOK, nil)
+}
+`},
+	"middleware": {"middleware", `package middleware
+
+import (
+	"net/http"
+)
+
+type {{.Name}}Config struct {
+}
+
+var default{{.Name}}Config = {{.Name}}Config{}
+
+func {{.Name}}Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return {{.Name}}(default{{.Name}}Config)(next)
+}
+
+func {{.Name}}(config {{.Name}}Config) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r)
+		}
+	}
+}
+`},
+	"model": {"model", `package model
+
+type {{.Name}} struct {
+	ID int64 ` + "`json:\"id\"`" + `
+}
+`},
+}
+
+func runNew(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: cyber new <module path>")
+	}
+	module := args[0]
+	dir := path.Base(module)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	for name, text := range projectFiles {
+		if err := writeTemplate(filepath.Join(dir, name), text, map[string]string{"Module": module}); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("created %s, run: cd %s && go mod tidy && go run .\n", dir, dir)
+	return nil
+}
+
+func runGen(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: cyber gen handler|middleware|model <Name>")
+	}
+	gen, ok := genTemplates[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown generator %q", args[0])
+	}
+	name := args[1]
+	if name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		return fmt.Errorf("name %q must be an exported Go identifier", name)
+	}
+	file := filepath.Join(gen.dir, snakeCase(name)+".go")
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("%s already exists", file)
+	}
+	if err := writeTemplate(file, gen.text, map[string]string{"Name": name}); err != nil {
+		return err
+	}
+	fmt.Println("created", file)
+	return nil
+}
+
+func writeTemplate(file, text string, data interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return template.Must(template.New(file).Parse(text)).Execute(f, data)
+}
+
+// snakeCase 把 UserProfile 转换为 user_profile 作为文件名
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+// Command cyberbench runs the framework benchmarks and fails when any of
+// them regressed against a stored baseline:
+//
+//	cyberbench -update -baseline bench.json   # record a baseline
+//	cyberbench -baseline bench.json -max 10   # fail on >10% slowdowns
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/suonanjiexi/cyber/benchmarks"
+)
+
+func main() {
+	baselinePath := flag.String("baseline", "", "baseline results file")
+	update := flag.Bool("update", false, "write the results to the baseline file")
+	maxPercent := flag.Float64("max", 10, "allowed slowdown in percent")
+	filter := flag.String("run", "", "only run benchmarks whose name contains this")
+	flag.Parse()
+	// 基准测试期间不输出路由注册等日志
+	log.SetOutput(devNull{})
+
+	suite := benchmarks.All()
+	results := benchmarks.Run(suite, *filter)
+	printResults(suite, results)
+
+	if *baselinePath == "" {
+		return
+	}
+	if *update {
+		data, _ := json.MarshalIndent(results, "", "  ")
+		if err := os.WriteFile(*baselinePath, append(data, '\n'), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	data, err := os.ReadFile(*baselinePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var baseline []benchmarks.Result
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	regressions := benchmarks.Compare(baseline, results, *maxPercent)
+	for _, r := range regressions {
+		fmt.Printf("REGRESSION %s: %.0f ns/op -> %.0f ns/op (+%.1f%%)\n", r.Name, r.Baseline, r.Current, r.Percent)
+	}
+	if len(regressions) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printResults(suite []benchmarks.Benchmark, results []benchmarks.Result) {
+	byName := make(map[string]benchmarks.Result, len(results))
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "benchmark\tns/op\tallocs/op\tB/op\tvs baseline\t")
+	for _, bm := range suite {
+		r, ok := byName[bm.Name]
+		if !ok {
+			continue
+		}
+		ratio := ""
+		if base, ok := byName[bm.Baseline]; ok && base.NsPerOp > 0 {
+			ratio = fmt.Sprintf("%.2fx %s", r.NsPerOp/base.NsPerOp, bm.Baseline)
+		}
+		fmt.Fprintf(tw, "%s\t%.0f\t%d\t%d\t%s\t\n", bm.Name, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp, ratio)
+	}
+	tw.Flush()
+}
+
+type devNull struct{}
+
+func (devNull) Write(p []byte) (int, error) { return len(p), nil }
@@ -0,0 +1,71 @@
+package cyber
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// JSONEncoder writes JSON values to a stream.
+type JSONEncoder interface {
+	Encode(v interface{}) error
+}
+
+// JSONDecoder reads JSON values from a stream.
+type JSONDecoder interface {
+	Decode(v interface{}) error
+}
+
+// JSONCodec is the JSON implementation used by BindJSON, Success and Error.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) JSONEncoder
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// StdJSONCodec uses encoding/json.
+type StdJSONCodec struct{}
+
+func (StdJSONCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (StdJSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (StdJSONCodec) NewEncoder(w io.Writer) JSONEncoder         { return json.NewEncoder(w) }
+func (StdJSONCodec) NewDecoder(r io.Reader) JSONDecoder         { return json.NewDecoder(r) }
+
+var jsonCodec JSONCodec = StdJSONCodec{}
+
+// SetJSONCodec replaces the JSON implementation. It is not safe to call
+// while the server is handling requests; call it before App.Run.
+func SetJSONCodec(codec JSONCodec) {
+	if codec == nil {
+		codec = StdJSONCodec{}
+	}
+	jsonCodec = codec
+}
+
+// GetJSONCodec returns the JSON implementation in use.
+func GetJSONCodec() JSONCodec {
+	return jsonCodec
+}
+
+// BindJSON decodes the request body into v with the configured codec.
+// Fields of a struct with a default tag, e.g. `default:"10"`, keep that
+// value when the body omits them. When v embeds Fields, the keys present
+// in the body are recorded.
+func BindJSON(r *http.Request, v interface{}) error {
+	if err := applyDefaults(v); err != nil {
+		return err
+	}
+	if _, ok := v.(presenceRecorder); !ok {
+		return jsonCodec.NewDecoder(r.Body).Decode(v)
+	}
+	var data json.RawMessage
+	if err := jsonCodec.NewDecoder(r.Body).Decode(&data); err != nil {
+		return err
+	}
+	if err := jsonCodec.Unmarshal(data, v); err != nil {
+		return err
+	}
+	markJSONPresent(v, data)
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build go_json
+
+package cyber
+
+import (
+	"io"
+
+	gojson "github.com/goccy/go-json"
+)
+
+// GoJSONCodec uses goccy/go-json. Build with -tags go_json.
+type GoJSONCodec struct{}
+
+func (GoJSONCodec) Marshal(v interface{}) ([]byte, error)      { return gojson.Marshal(v) }
+func (GoJSONCodec) Unmarshal(data []byte, v interface{}) error { return gojson.Unmarshal(data, v) }
+func (GoJSONCodec) NewEncoder(w io.Writer) JSONEncoder         { return gojson.NewEncoder(w) }
+func (GoJSONCodec) NewDecoder(r io.Reader) JSONDecoder         { return gojson.NewDecoder(r) }
@@ -0,0 +1,22 @@
+//go:build jsoniter
+
+package cyber
+
+import (
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSONIterCodec uses json-iterator in its encoding/json compatible mode.
+// Build with -tags jsoniter.
+type JSONIterCodec struct{}
+
+var jsoniterAPI = jsoniter.ConfigCompatibleWithStandardLibrary
+
+func (JSONIterCodec) Marshal(v interface{}) ([]byte, error) { return jsoniterAPI.Marshal(v) }
+func (JSONIterCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniterAPI.Unmarshal(data, v)
+}
+func (JSONIterCodec) NewEncoder(w io.Writer) JSONEncoder { return jsoniterAPI.NewEncoder(w) }
+func (JSONIterCodec) NewDecoder(r io.Reader) JSONDecoder { return jsoniterAPI.NewDecoder(r) }
@@ -0,0 +1,20 @@
+//go:build sonic
+
+package cyber
+
+import (
+	"io"
+
+	"github.com/bytedance/sonic"
+)
+
+// SonicCodec uses sonic in its encoding/json compatible mode. Build with
+// -tags sonic.
+type SonicCodec struct{}
+
+var sonicAPI = sonic.ConfigStd
+
+func (SonicCodec) Marshal(v interface{}) ([]byte, error)      { return sonicAPI.Marshal(v) }
+func (SonicCodec) Unmarshal(data []byte, v interface{}) error { return sonicAPI.Unmarshal(data, v) }
+func (SonicCodec) NewEncoder(w io.Writer) JSONEncoder         { return sonicAPI.NewEncoder(w) }
+func (SonicCodec) NewDecoder(r io.Reader) JSONDecoder         { return sonicAPI.NewDecoder(r) }
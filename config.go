@@ -1,17 +1,79 @@
 package cyber
 
 import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
 type AppConfig struct {
-	ServerPort   string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	// 监听的主机，支持IPv6字面量，例如 ::1；为空时监听所有地址
+	Host string
+	// 端口号，也可以是完整地址，例如 127.0.0.1:8080、[::1]:0；为空时使用PORT环境变量
+	ServerPort string
+	// 各项超时为0时使用默认值，负数表示不限制
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	// MaxHeaderBytes 为0或负数时使用默认值1MB
+	MaxHeaderBytes int
+	// DisableKeepAlives 关闭HTTP keep-alive，每个请求结束后关闭连接
+	DisableKeepAlives bool
+	// TCPKeepAlive 是TCP keep-alive探测的间隔，0使用Go的默认值（15秒），负数关闭探测
+	TCPKeepAlive time.Duration
+	// DisableNoDelay 关闭TCP_NODELAY（Go默认开启），允许内核合并小包
+	DisableNoDelay bool
+	// Linger 设置SO_LINGER：0保持系统默认，正数表示关闭时最多等待未发送数据的时间，
+	// 负数表示关闭时直接丢弃未发送的数据并发送RST
+	Linger time.Duration
+	// Mux 是注册路由的ServeMux，为nil时使用http.DefaultServeMux；
+	// 使用独立的mux可以在同一进程中运行多个应用，例如测试中
+	Mux *http.ServeMux
+}
+
+// tcpOptions 是应用到每个已接受TCP连接上的选项
+type tcpOptions struct {
+	keepAlive time.Duration
+	noDelay   bool
+	linger    time.Duration
+}
+
+func (o tcpOptions) isDefault() bool {
+	return o.keepAlive == 0 && o.noDelay && o.linger == 0
 }
 
 const (
 	defaultServerPort   = "8080"
 	defaultReadTimeout  = 1 * time.Minute
 	defaultWriteTimeout = 1 * time.Minute
+	// 限制读取请求头的时间，防止slowloris类的慢速攻击
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultMaxHeaderBytes    = 1 << 20
 )
+
+// orDefault 返回timeout，为0时返回def；负数保留，由http.Server视为不限制
+func orDefault(timeout, def time.Duration) time.Duration {
+	if timeout == 0 {
+		return def
+	}
+	return timeout
+}
+
+// serverAddr 根据Host和ServerPort得到监听地址，ServerPort是完整地址时直接使用
+func serverAddr(config *AppConfig) string {
+	port := config.ServerPort
+	if port == "" {
+		port = os.Getenv("PORT")
+	}
+	if port == "" {
+		port = defaultServerPort
+	}
+	if strings.Contains(port, ":") {
+		return port
+	}
+	return net.JoinHostPort(config.Host, port)
+}
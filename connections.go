@@ -0,0 +1,117 @@
+package cyber
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ConnectionStats counts the server's open connections by state.
+type ConnectionStats struct {
+	// New 是已建立但还没有读到请求的连接
+	New int `json:"new"`
+	// Active 是正在处理请求的连接，关闭时需要等待它们结束
+	Active int `json:"active"`
+	// Idle 是等待下一个keep-alive请求的连接
+	Idle int `json:"idle"`
+	// Hijacked 是被接管（例如WebSocket）的连接累计数，这些连接不再由服务器跟踪
+	Hijacked int `json:"hijacked"`
+}
+
+// connTracker 通过http.Server.ConnState记录每个连接当前的状态
+type connTracker struct {
+	mu       sync.Mutex
+	conns    map[net.Conn]http.ConnState
+	stats    ConnectionStats
+	hookOnce sync.Once
+}
+
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		t.conns = make(map[net.Conn]http.ConnState)
+	}
+	if previous, ok := t.conns[conn]; ok {
+		t.count(previous, -1)
+	}
+	switch state {
+	case http.StateNew, http.StateActive, http.StateIdle:
+		t.conns[conn] = state
+		t.count(state, 1)
+	case http.StateHijacked:
+		delete(t.conns, conn)
+		t.stats.Hijacked++
+	default:
+		delete(t.conns, conn)
+	}
+}
+
+func (t *connTracker) count(state http.ConnState, delta int) {
+	switch state {
+	case http.StateNew:
+		t.stats.New += delta
+	case http.StateActive:
+		t.stats.Active += delta
+	case http.StateIdle:
+		t.stats.Idle += delta
+	}
+}
+
+// trackConnections 在Serve前挂上连接状态回调，保留用户已设置的ConnState
+func (app *App) trackConnections() {
+	app.conns.hookOnce.Do(func() {
+		previous := app.Server.ConnState
+		app.Server.ConnState = func(conn net.Conn, state http.ConnState) {
+			app.conns.track(conn, state)
+			if previous != nil {
+				previous(conn, state)
+			}
+		}
+	})
+}
+
+// Connections returns the current connection counts by state.
+func (app *App) Connections() ConnectionStats {
+	app.conns.mu.Lock()
+	defer app.conns.mu.Unlock()
+	return app.conns.stats
+}
+
+// ActiveConnections returns the number of connections serving a request,
+// which are the ones Shutdown waits for.
+func (app *App) ActiveConnections() int {
+	return app.Connections().Active
+}
+
+// 关闭期间输出排空进度的间隔
+const drainLogInterval = time.Second
+
+// shutdownServer 关闭服务器，并定期记录仍在处理请求的连接数，便于调整关闭超时
+func (app *App) shutdownServer(ctx context.Context) error {
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(drainLogInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				log.Printf("Draining connections: %d active after %s", app.ActiveConnections(), time.Since(start).Round(time.Millisecond))
+			}
+		}
+	}()
+	err := app.Server.Shutdown(ctx)
+	close(done)
+	if err != nil {
+		log.Printf("Shutdown stopped with %d active connections after %s: %v", app.ActiveConnections(), time.Since(start).Round(time.Millisecond), err)
+	} else {
+		log.Printf("Connections drained in %s", time.Since(start).Round(time.Millisecond))
+	}
+	return err
+}
@@ -0,0 +1,26 @@
+package cyber
+
+import (
+	"github.com/suonanjiexi/cyber/queue"
+)
+
+// Consumers returns the app's message consumers. They subscribe when the
+// app starts and drain when it shuts down, after the HTTP server.
+func (app *App) Consumers() *queue.Consumers {
+	app.consumersOnce.Do(func() {
+		app.OnStart(app.consumers.Start)
+		app.OnStop(app.consumers.Shutdown)
+	})
+	return &app.consumers
+}
+
+// SetBroker sets the broker that Consume subscribes with.
+func (app *App) SetBroker(broker queue.Broker) {
+	app.Consumers().SetBroker(broker)
+}
+
+// Consume handles messages of topic with handler once the app runs, e.g.
+// app.Consume("orders.created", handler).Concurrency(4).
+func (app *App) Consume(topic string, handler queue.Handler) *queue.Subscription {
+	return app.Consumers().Consume(topic, handler)
+}
@@ -0,0 +1,101 @@
+package cyber
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// requestState 保存单个请求生命周期内的框架状态，由App在分发请求时放入context
+type requestState struct {
+	app     *App
+	mu      sync.Mutex
+	memo    map[string]*memoEntry
+	timings []Timing
+	// aborted 表示已经通过Abort写出错误响应
+	aborted bool
+	// variant 是Split为请求选择的变体
+	variant string
+	// route 是匹配请求的路由
+	route *Route
+	// goroutines 是调试模式下通过GoExpect启动的goroutine
+	goroutines []*requestGoroutine
+	// middlewareSpans 是ObserveMiddlewares计时中正在执行的中间件，由外到内
+	middlewareSpans []*middlewareSpan
+	// body 是BodyBytes缓冲的请求体
+	body *bufferedBody
+	// csrf 是CSRF中间件为请求确定的密钥
+	csrf *csrfSecret
+	// flash 是请求读取和新增的闪现消息
+	flash *flashState
+}
+
+type memoEntry struct {
+	mu    sync.Mutex
+	done  bool
+	value interface{}
+}
+
+type requestStateKey struct{}
+
+// withRequestState 返回带有请求状态的context。已有状态时（例如Mount挂载的另一个App）只更新匹配的路由；
+// 批量请求的子请求放入的是nil，会得到新的状态
+func withRequestState(ctx context.Context, app *App, route *Route) context.Context {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok && state != nil {
+		state.mu.Lock()
+		state.route = route
+		state.mu.Unlock()
+		return ctx
+	}
+	return context.WithValue(ctx, requestStateKey{}, &requestState{app: app, route: route})
+}
+
+// CurrentRoute returns the route that matched the request, or nil outside
+// of an App-dispatched request. Middlewares use it to read route metadata.
+func CurrentRoute(r *http.Request) *Route {
+	state := stateFromRequest(r)
+	if state == nil {
+		return nil
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.route
+}
+
+func stateFromRequest(r *http.Request) *requestState {
+	state, _ := r.Context().Value(requestStateKey{}).(*requestState)
+	return state
+}
+
+// Memo returns the value cached under key for the current request, calling
+// fn to compute it on first use. Errors are not cached, so a failed lookup is
+// retried by the next caller. Outside of an App-dispatched request fn is
+// simply called every time.
+func Memo(r *http.Request, key string, fn func() (interface{}, error)) (interface{}, error) {
+	state := stateFromRequest(r)
+	if state == nil {
+		return fn()
+	}
+	state.mu.Lock()
+	if state.memo == nil {
+		state.memo = make(map[string]*memoEntry)
+	}
+	entry, ok := state.memo[key]
+	if !ok {
+		entry = &memoEntry{}
+		state.memo[key] = entry
+	}
+	state.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.done {
+		return entry.value, nil
+	}
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	entry.value, entry.done = value, true
+	return value, nil
+}
@@ -0,0 +1,209 @@
+package cyber
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/suonanjiexi/cyber/pathmatch"
+	"github.com/suonanjiexi/cyber/securecookie"
+)
+
+type CSRFConfig struct {
+	// 保存密钥的Cookie名称，默认 _csrf
+	CookieName string
+	// 表单中令牌字段的名称，默认 csrf_token
+	FieldName string
+	// AJAX请求携带令牌的请求头，默认 X-CSRF-Token
+	HeaderName string
+	// 不为nil时签名密钥Cookie，防止同站子域名写入的Cookie被接受
+	Cookies *securecookie.Codec
+	// 不检查令牌的路径，例如接收第三方回调的接口；支持通配符、正则和方法限定，见pathmatch.Matcher
+	SkipPaths []string
+}
+
+var defaultCSRFConfig = CSRFConfig{
+	CookieName: "_csrf",
+	FieldName:  "csrf_token",
+	HeaderName: "X-CSRF-Token",
+}
+
+func (config CSRFConfig) withDefaults() CSRFConfig {
+	if config.CookieName == "" {
+		config.CookieName = defaultCSRFConfig.CookieName
+	}
+	if config.FieldName == "" {
+		config.FieldName = defaultCSRFConfig.FieldName
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = defaultCSRFConfig.HeaderName
+	}
+	return config
+}
+
+const csrfSecretLen = 32
+
+// csrfSecret 是保存在Cookie中的密钥，表单中的令牌是它的掩码形式
+type csrfSecret struct {
+	secret []byte
+	field  string
+}
+
+func CSRFMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return CSRF(defaultCSRFConfig)(next)
+}
+
+// CSRF protects forms against cross-site request forgery with the
+// double-submit pattern: a random secret in a cookie, and a token derived
+// from it that the page embeds. Requests with unsafe methods (POST, PUT,
+// PATCH, DELETE) are rejected with 403 unless they carry a valid token in
+// the header, or in the form field of an application/x-www-form-urlencoded
+// body. Multipart requests, e.g. uploads, must send the header, since
+// parsing them here would consume the body before the handler. Templates rendered with HTML get the token
+// through the csrfField and csrfToken functions, see App.LoadTemplates.
+func CSRF(config CSRFConfig) Middleware {
+	config = config.withDefaults()
+	skip := pathmatch.Must(config.SkipPaths...)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			secret, fresh := csrfCookie(w, r, config)
+			if state := stateFromRequest(r); state != nil {
+				state.mu.Lock()
+				state.csrf = &csrfSecret{secret: secret, field: config.FieldName}
+				state.mu.Unlock()
+			}
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next(w, r)
+				return
+			}
+			if skip.Match(r) {
+				next(w, r)
+				return
+			}
+			token := r.Header.Get(config.HeaderName)
+			// 只从urlencoded表单读取字段：解析multipart会消耗请求体，上传文件的handler就读不到了
+			if token == "" && ContentType(r) == "application/x-www-form-urlencoded" {
+				token = r.PostFormValue(config.FieldName)
+			}
+			// 刚生成的密钥不可能出现在已经发出的页面中
+			if fresh || !validCSRFToken(secret, token) {
+				http.Error(w, "Forbidden - CSRF token invalid", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// csrfCookie 返回请求Cookie中的密钥，没有或无效时生成新密钥并设置Cookie
+func csrfCookie(w http.ResponseWriter, r *http.Request, config CSRFConfig) (secret []byte, fresh bool) {
+	var value string
+	if config.Cookies != nil {
+		value, _ = config.Cookies.Cookie(r, config.CookieName)
+	} else if cookie, err := r.Cookie(config.CookieName); err == nil {
+		value = cookie.Value
+	}
+	if secret, err := hex.DecodeString(value); err == nil && len(secret) == csrfSecretLen {
+		return secret, false
+	}
+	secret = make([]byte, csrfSecretLen)
+	rand.Read(secret)
+	cookie := &http.Cookie{
+		Name:     config.CookieName,
+		Value:    hex.EncodeToString(secret),
+		Path:     "/",
+		Secure:   IsTLS(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if config.Cookies != nil {
+		if err := config.Cookies.SetCookie(w, cookie); err != nil {
+			log.Printf("Error setting CSRF cookie: %v", err)
+		}
+	} else {
+		http.SetCookie(w, cookie)
+	}
+	return secret, true
+}
+
+// CSRFToken returns a token for the request to embed in a form field or
+// send in the header, or "" when the route isn't behind CSRF. Every call
+// returns a different token for the same secret, so compressed responses
+// don't leak it (BREACH).
+func CSRFToken(r *http.Request) string {
+	state := stateFromRequest(r)
+	if state == nil {
+		return ""
+	}
+	state.mu.Lock()
+	csrf := state.csrf
+	state.mu.Unlock()
+	if csrf == nil {
+		return ""
+	}
+	return maskCSRFSecret(csrf.secret)
+}
+
+// maskCSRFSecret 令牌为随机掩码加上掩码与密钥的异或
+func maskCSRFSecret(secret []byte) string {
+	token := make([]byte, 2*len(secret))
+	rand.Read(token[:len(secret)])
+	for i := range secret {
+		token[len(secret)+i] = token[i] ^ secret[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func validCSRFToken(secret []byte, token string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 2*len(secret) {
+		return false
+	}
+	unmasked := make([]byte, len(secret))
+	for i := range unmasked {
+		unmasked[i] = raw[i] ^ raw[len(secret)+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+var csrfMarker = templateMarker("csrf")
+
+// csrfFuncs 是LoadTemplates默认提供的模板函数
+func csrfFuncs() template.FuncMap {
+	return template.FuncMap{
+		// {{csrfField}} 输出包含令牌的隐藏字段
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + csrfMarker + `-field" value="` + csrfMarker + `">`)
+		},
+		// {{csrfToken}} 输出令牌，例如放在meta标签中供AJAX请求使用
+		"csrfToken": func() string {
+			return csrfMarker
+		},
+	}
+}
+
+// injectCSRF 把渲染结果中的占位符替换为请求的令牌
+func injectCSRF(r *http.Request, body []byte) []byte {
+	if !bytes.Contains(body, []byte(csrfMarker)) {
+		return body
+	}
+	field, token := defaultCSRFConfig.FieldName, ""
+	if state := stateFromRequest(r); state != nil {
+		state.mu.Lock()
+		if state.csrf != nil {
+			field, token = state.csrf.field, maskCSRFSecret(state.csrf.secret)
+		}
+		state.mu.Unlock()
+	}
+	if token == "" {
+		log.Printf("Template uses csrfField or csrfToken on a route without the CSRF middleware: %s", r.URL.Path)
+	}
+	body = bytes.ReplaceAll(body, []byte(csrfMarker+"-field"), []byte(template.HTMLEscapeString(field)))
+	return bytes.ReplaceAll(body, []byte(csrfMarker), []byte(token))
+}
@@ -2,47 +2,124 @@ package cyber
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
+
+	"github.com/suonanjiexi/cyber/queue"
+	"github.com/suonanjiexi/cyber/securecookie"
 )
 
-type HandlerFunc func(http.ResponseWriter, *http.Request)
+// HandlerFunc is an alias of http.HandlerFunc, so cyber handlers and net/http
+// handlers are interchangeable without conversion.
+type HandlerFunc = http.HandlerFunc
 type Middleware func(http.HandlerFunc) http.HandlerFunc
 
+// Hook 在应用启动前或关闭后执行，例如打开/关闭数据库连接池
+type Hook func(ctx context.Context) error
+
 type App struct {
 	Middlewares []Middleware
-	Server      *http.Server
+	// mu 保护Middlewares以及分组的中间件、策略和路由计数，使服务运行期间也可以安全地注册路由；
+	// Middlewares按写时复制更新，已注册路由持有的切片不会被修改
+	mu         sync.RWMutex
+	Server     *http.Server
+	startHooks []Hook
+	stopHooks  []Hook
+	// renderErrorHandler 在响应编码失败时调用，此时尚未写出任何响应头
+	renderErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+	// errorHandler 格式化Abort写出的错误响应
+	errorHandler func(w http.ResponseWriter, r *http.Request, err *HTTPError)
+	// envelope 非空时Success和Error以统一的信封格式输出
+	envelope   *EnvelopeConfig
+	routes     routeTable
+	mode       Mode
+	templates  *templateSet
+	listenerMu sync.Mutex
+	listener   net.Listener
+	conns      connTracker
+	streams    streamRegistry
+	// background 是Go启动的后台goroutine
+	background backgroundTasks
+	tcp        tcpOptions
+	// consumers 是消息队列的消费者，首次使用时注册生命周期钩子
+	consumers     queue.Consumers
+	consumersOnce sync.Once
+	// middlewareObservers 接收中间件的执行时间，在注册路由时套用
+	middlewareObservers []MiddlewareObserver
+	// trustedProxies 是可以通过X-Forwarded-Proto等请求头报告客户端协议的代理
+	trustedProxies []*net.IPNet
+	// proxyHeader 是可信代理报告客户端协议的请求头
+	proxyHeader string
+	// flashCookies 不为nil时签名或加密保存闪现消息的Cookie
+	flashCookies *securecookie.Codec
+	// mux 是注册路由的ServeMux
+	mux *http.ServeMux
 }
 
 type RouteGroup struct {
-	prefix string
-	app    *App
+	prefix      string
+	app         *App
+	parent      *RouteGroup
+	middlewares []Middleware
+	// policies 按名称登记内置中间件（限流、缓存等），子分组可以覆盖同名策略
+	policies    map[string]Middleware
+	policyNames []string
+	// routeCount 是分组及其子分组注册的路由数
+	routeCount int
 }
 
+// NewApp creates an App. Zero timeouts and MaxHeaderBytes in config take
+// the defaults one by one, so setting a single field keeps the others
+// protected; a negative timeout disables it. A nil config uses all
+// defaults.
 func NewApp(config *AppConfig) *App {
 	if config == nil {
-		config = &AppConfig{
-			ServerPort:   defaultServerPort,
-			ReadTimeout:  defaultReadTimeout,
-			WriteTimeout: defaultWriteTimeout,
-		}
+		config = &AppConfig{}
 	}
 
 	serverConfig := &http.Server{
-		Addr:         fmt.Sprintf(":%s", config.ServerPort),
-		ReadTimeout:  config.ReadTimeout,
-		WriteTimeout: config.WriteTimeout,
+		Addr:              serverAddr(config),
+		ReadTimeout:       orDefault(config.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:      orDefault(config.WriteTimeout, defaultWriteTimeout),
+		ReadHeaderTimeout: orDefault(config.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		IdleTimeout:       orDefault(config.IdleTimeout, defaultIdleTimeout),
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+	if serverConfig.MaxHeaderBytes <= 0 {
+		serverConfig.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+
+	if config.DisableKeepAlives {
+		serverConfig.SetKeepAlivesEnabled(false)
+	}
+	mux := http.DefaultServeMux
+	if config.Mux != nil {
+		mux = config.Mux
+		serverConfig.Handler = mux
 	}
 
 	return &App{
 		Server: serverConfig,
+		mode:   defaultMode(),
+		mux:    mux,
+		tcp: tcpOptions{
+			keepAlive: config.TCPKeepAlive,
+			noDelay:   !config.DisableNoDelay,
+			linger:    config.Linger,
+		},
 	}
 }
 
+// Use adds middlewares to routes registered afterwards. It is safe to call
+// while serving.
 func (app *App) Use(middlewares ...Middleware) {
-	app.Middlewares = append(app.Middlewares, middlewares...)
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.Middlewares = append(app.Middlewares[:len(app.Middlewares):len(app.Middlewares)], middlewares...)
 }
 
 func applyMiddlewares(handler http.HandlerFunc, middlewares []Middleware) http.HandlerFunc {
@@ -52,45 +129,110 @@ func applyMiddlewares(handler http.HandlerFunc, middlewares []Middleware) http.H
 	return handler
 }
 
+// Handle registers handler for method and pattern. Routes may also be added
+// while the app is serving; like any registration, a pattern conflicting
+// with an existing one makes the ServeMux panic.
 func (app *App) Handle(pattern string, method string, handler http.HandlerFunc) {
+	app.handle(pattern, method, handler, nil)
+}
+
+// handle 注册路由，middlewares是分组中间件，在应用中间件内侧执行
+func (app *App) handle(pattern string, method string, handler http.HandlerFunc, middlewares []Middleware) {
 	if !isValidHTTPMethod(method) {
 		log.Printf("Unsupported HTTP method: %s", method)
 		return
 	}
-	finalHandler := applyMiddlewares(handler, app.Middlewares)
-	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != method {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-			return
-		}
+	route, finalHandler := app.addRoute(method, pattern, handler, middlewares)
+	// 使用 "METHOD /path" 形式的模式注册，同一路径可以注册多个方法，其他方法由ServeMux返回405
+	app.mux.HandleFunc(method+" "+pattern, func(w http.ResponseWriter, r *http.Request) {
+		r, cancel := app.prepareRequest(r, route)
+		defer cancel()
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic occurred in handler: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				RenderPanic(w, r, err)
 			}
 		}()
 		finalHandler(w, r)
 	})
-	log.Printf("Route registered: %s %s", method, pattern)
+	app.debugf("Route registered: %s %s", method, pattern)
+}
+
+// HandleFunc registers handler for pattern regardless of the request method.
+func (app *App) HandleFunc(pattern string, handler http.HandlerFunc) {
+	route, finalHandler := app.addRoute("", pattern, handler, nil)
+	app.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		r, cancel := app.prepareRequest(r, route)
+		defer cancel()
+		finalHandler(w, r)
+	})
+	app.debugf("Route registered: * %s", pattern)
+}
+
+func (app *App) Get(pattern string, handler http.HandlerFunc) {
+	app.Handle(pattern, http.MethodGet, handler)
+}
+
+func (app *App) Post(pattern string, handler http.HandlerFunc) {
+	app.Handle(pattern, http.MethodPost, handler)
+}
+
+func (app *App) Put(pattern string, handler http.HandlerFunc) {
+	app.Handle(pattern, http.MethodPut, handler)
+}
+
+func (app *App) Patch(pattern string, handler http.HandlerFunc) {
+	app.Handle(pattern, http.MethodPatch, handler)
+}
+
+func (app *App) Delete(pattern string, handler http.HandlerFunc) {
+	app.Handle(pattern, http.MethodDelete, handler)
 }
 
 func (app *App) Group(prefix string) *RouteGroup {
-	return &RouteGroup{prefix: prefix, app: app}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return app.addGroup(&RouteGroup{prefix: prefix, app: app})
 }
 
 func (rg *RouteGroup) Handle(pattern string, method string, handler http.HandlerFunc) {
 	fullPattern := rg.joinPattern(pattern)
-	rg.app.Handle(fullPattern, method, handler)
+	rg.app.mu.RLock()
+	middlewares := rg.chain()
+	rg.app.mu.RUnlock()
+	rg.app.handle(fullPattern, method, handler, middlewares)
+	rg.app.mu.Lock()
+	for g := rg; g != nil; g = g.parent {
+		g.routeCount++
+	}
+	rg.app.mu.Unlock()
+}
+
+func (rg *RouteGroup) Get(pattern string, handler http.HandlerFunc) {
+	rg.Handle(pattern, http.MethodGet, handler)
+}
+
+func (rg *RouteGroup) Post(pattern string, handler http.HandlerFunc) {
+	rg.Handle(pattern, http.MethodPost, handler)
+}
+
+func (rg *RouteGroup) Put(pattern string, handler http.HandlerFunc) {
+	rg.Handle(pattern, http.MethodPut, handler)
+}
+
+func (rg *RouteGroup) Patch(pattern string, handler http.HandlerFunc) {
+	rg.Handle(pattern, http.MethodPatch, handler)
+}
+
+func (rg *RouteGroup) Delete(pattern string, handler http.HandlerFunc) {
+	rg.Handle(pattern, http.MethodDelete, handler)
 }
 
 func (rg *RouteGroup) joinPattern(pattern string) string {
 	if !strings.HasPrefix(pattern, "/") {
 		pattern = "/" + pattern
 	}
-	if rg.prefix != "/" && !strings.HasPrefix(rg.prefix, "/") {
-		rg.prefix = "/" + rg.prefix
-	}
-	return rg.prefix + pattern
+	return strings.TrimSuffix(rg.prefix, "/") + pattern
 }
 
 func isValidHTTPMethod(method string) bool {
@@ -103,13 +245,108 @@ func isValidHTTPMethod(method string) bool {
 	return false
 }
 
-// Run logs the successful server start.
+// OnRenderError sets the hook called when a response can't be encoded.
+// Nothing has been written to w when it runs.
+func (app *App) OnRenderError(handler func(w http.ResponseWriter, r *http.Request, err error)) {
+	app.renderErrorHandler = handler
+}
+
+// OnStart registers a hook that Run executes before accepting connections.
+func (app *App) OnStart(hook Hook) {
+	app.startHooks = append(app.startHooks, hook)
+}
+
+// OnStop registers a hook that Shutdown executes after the server stopped,
+// in reverse registration order.
+func (app *App) OnStop(hook Hook) {
+	app.stopHooks = append(app.stopHooks, hook)
+}
+
+// DumpRoutesEnv makes Run print the route table as JSON and return without
+// serving when set; the cyber CLI uses it to inspect built binaries.
+const DumpRoutesEnv = "CYBER_DUMP_ROUTES"
+
+// Run listens on the configured address, or on the socket passed by systemd
+// socket activation, and serves until Shutdown.
 func (app *App) Run() error {
-	log.Printf("Server starting on %s", app.Server.Addr)
-	return app.Server.ListenAndServe()
+	if os.Getenv(DumpRoutesEnv) != "" {
+		return app.ExportRoutes(os.Stdout)
+	}
+	listeners, err := SystemdListeners()
+	if err != nil {
+		return err
+	}
+	if len(listeners) > 0 {
+		for _, extra := range listeners[1:] {
+			extra.Close()
+		}
+		return app.Serve(listeners[0])
+	}
+	ln, err := net.Listen("tcp", app.Server.Addr)
+	if err != nil {
+		return err
+	}
+	return app.Serve(ln)
+}
+
+// Serve runs the start hooks and serves on ln until Shutdown, for listeners
+// created elsewhere such as an in-memory listener in tests.
+func (app *App) Serve(ln net.Listener) error {
+	if os.Getenv(DumpRoutesEnv) != "" {
+		ln.Close()
+		return app.ExportRoutes(os.Stdout)
+	}
+	for _, hook := range app.startHooks {
+		if err := hook(context.Background()); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+	app.listenerMu.Lock()
+	app.listener = ln
+	app.listenerMu.Unlock()
+	app.trackConnections()
+	if !app.tcp.isDefault() {
+		ln = &tcpTuningListener{Listener: ln, options: app.tcp}
+	}
+	if app.IsDebug() {
+		app.printBanner(os.Stderr)
+	}
+	log.Printf("Server starting on %s", ln.Addr())
+	return app.Server.Serve(ln)
+}
+
+// Addr returns the address the server is listening on, including the port
+// chosen by the system for port 0, or the configured address before Run has
+// bound it.
+func (app *App) Addr() string {
+	app.listenerMu.Lock()
+	defer app.listenerMu.Unlock()
+	if app.listener != nil {
+		return app.listener.Addr().String()
+	}
+	return app.Server.Addr
 }
 
 func (app *App) Shutdown(ctx context.Context) error {
-	log.Printf("Shutting down server on %s", app.Server.Addr)
-	return app.Server.Shutdown(ctx)
+	log.Printf("Shutting down server on %s", app.Addr())
+	// 长连接不会自己结束，先通知它们关闭，否则服务器要等到超时；被接管的WebSocket连接不由服务器跟踪，单独等待
+	app.closeStreams()
+	err := app.shutdownServer(ctx)
+	if streamErr := app.waitStreams(ctx); err == nil {
+		err = streamErr
+	}
+	// 后台goroutine可能还在使用数据库等资源，在停止钩子之前结束
+	if bgErr := app.stopBackground(ctx); err == nil {
+		err = bgErr
+	}
+	for i := len(app.stopHooks) - 1; i >= 0; i-- {
+		if hookErr := app.stopHooks[i](ctx); hookErr != nil {
+			log.Printf("Stop hook error: %v", hookErr)
+			if err == nil {
+				err = hookErr
+			}
+		}
+	}
+	return err
 }
@@ -0,0 +1,307 @@
+package cybertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// Contract runs requests against an in-process handler and checks every
+// response against an OpenAPI document, recording which operations and
+// status codes were exercised.
+type Contract struct {
+	spec    *cyber.OpenAPI
+	handler http.Handler
+	// mux 只用于把请求路径匹配到OpenAPI中的路径模板
+	mux *http.ServeMux
+
+	mu   sync.Mutex
+	hits map[string]map[string]int
+}
+
+// NewContract checks responses of app against app's own generated spec.
+func NewContract(app *cyber.App) *Contract {
+	return NewContractWithSpec(app.OpenAPI("contract", "test"), app.Handler())
+}
+
+func NewContractWithSpec(spec *cyber.OpenAPI, handler http.Handler) *Contract {
+	c := &Contract{spec: spec, handler: handler, mux: http.NewServeMux(), hits: make(map[string]map[string]int)}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			c.mux.HandleFunc(strings.ToUpper(method)+" "+path, func(http.ResponseWriter, *http.Request) {})
+		}
+	}
+	return c
+}
+
+// Case is one request of a contract suite.
+type Case struct {
+	Name    string
+	Request *http.Request
+}
+
+// Do serves req and returns the response together with every way it
+// deviates from the spec. JSON request and response bodies are checked
+// against the operation's schemas, when the spec has them. A HEAD request
+// is checked against the GET operation of its path.
+func (c *Contract) Do(req *http.Request) (*httptest.ResponseRecorder, []string) {
+	// 请求体在交给handler之前读出，用于按文档校验
+	var requestBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		requestBody, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+
+	_, pattern := c.mux.Handler(req)
+	method, path, _ := strings.Cut(pattern, " ")
+	// ServeMux的GET模式同样匹配HEAD，文档中没有单独的HEAD操作时按GET校验
+	if pattern == "" || (method != req.Method && !(req.Method == http.MethodHead && method == http.MethodGet)) {
+		return rec, []string{fmt.Sprintf("%s %s matches no operation in the spec", req.Method, req.URL.Path)}
+	}
+	operation := c.spec.Paths[path][strings.ToLower(method)]
+	status := strconv.Itoa(rec.Code)
+	c.mu.Lock()
+	key := method + " " + path
+	if c.hits[key] == nil {
+		c.hits[key] = make(map[string]int)
+	}
+	c.hits[key][status]++
+	c.mu.Unlock()
+
+	var violations []string
+	if operation.RequestBody != nil && len(requestBody) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if content, ok := operation.RequestBody.Content[mediaType]; !ok {
+			violations = append(violations, fmt.Sprintf("%s: request has undocumented Content-Type %q", key, mediaType))
+		} else {
+			violations = append(violations, c.checkBody(key+": request body", mediaType, requestBody, content)...)
+		}
+	} else if operation.RequestBody != nil && operation.RequestBody.Required {
+		violations = append(violations, fmt.Sprintf("%s: request body is required", key))
+	}
+
+	response, ok := operation.Responses[status]
+	if !ok {
+		response, ok = operation.Responses["default"]
+	}
+	if !ok {
+		return rec, append(violations, fmt.Sprintf("%s: status %s is not documented", key, status))
+	}
+	// HEAD的响应体不会发送给客户端，不做检查
+	if len(response.Content) > 0 && rec.Body.Len() > 0 && req.Method != http.MethodHead {
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		if content, ok := response.Content[mediaType]; !ok {
+			violations = append(violations, fmt.Sprintf("%s: status %s has undocumented Content-Type %q", key, status, mediaType))
+		} else {
+			violations = append(violations, c.checkBody(fmt.Sprintf("%s: status %s body", key, status), mediaType, rec.Body.Bytes(), content)...)
+		}
+	}
+	return rec, violations
+}
+
+// checkBody 检查JSON请求体或响应体是否有效并符合文档中的schema
+func (c *Contract) checkBody(prefix, mediaType string, body []byte, content *cyber.MediaType) []string {
+	if !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return []string{prefix + " is not valid JSON"}
+	}
+	if content == nil || content.Schema == nil {
+		return nil
+	}
+	var violations []string
+	for _, problem := range c.validate(value, content.Schema, "$", 0) {
+		violations = append(violations, prefix+": "+problem)
+	}
+	return violations
+}
+
+// validate 按schema检查解码后的JSON值，返回以JSON路径标明位置的问题
+func (c *Contract) validate(value interface{}, schema *cyber.Schema, at string, depth int) []string {
+	if schema.Ref != "" {
+		if depth > 64 {
+			return nil
+		}
+		resolved := c.resolve(schema.Ref)
+		if resolved == nil {
+			return []string{fmt.Sprintf("%s: unknown schema %s", at, schema.Ref)}
+		}
+		return c.validate(value, resolved, at, depth+1)
+	}
+	if value == nil {
+		if schema.Type == "" || schema.Nullable {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: null is not allowed", at)}
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: want object", at)}
+		}
+		var problems []string
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", at, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				problems = append(problems, c.validate(object[name], property, at+"."+name, depth)...)
+			} else if schema.AdditionalProperties != nil {
+				problems = append(problems, c.validate(object[name], schema.AdditionalProperties, at+"."+name, depth)...)
+			}
+		}
+		return problems
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: want array", at)}
+		}
+		var problems []string
+		if schema.Items != nil {
+			for i, item := range array {
+				problems = append(problems, c.validate(item, schema.Items, fmt.Sprintf("%s[%d]", at, i), depth)...)
+			}
+		}
+		return problems
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s: want string", at)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: want boolean", at)}
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return []string{fmt.Sprintf("%s: want number", at)}
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want integer", at)}
+		}
+		if _, err := n.Int64(); err != nil && !isUint64(n) {
+			return []string{fmt.Sprintf("%s: %s is not an integer", at, n)}
+		}
+	}
+	return nil
+}
+
+func isUint64(n json.Number) bool {
+	_, err := strconv.ParseUint(string(n), 10, 64)
+	return err == nil
+}
+
+// resolve 返回 "#/components/schemas/Name" 引用的schema
+func (c *Contract) resolve(ref string) *cyber.Schema {
+	name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+	if !ok || c.spec.Components == nil {
+		return nil
+	}
+	return c.spec.Components.Schemas[name]
+}
+
+// Run executes the cases and reports violations as test errors.
+func (c *Contract) Run(t testing.TB, cases []Case) {
+	t.Helper()
+	for _, tc := range cases {
+		if _, violations := c.Do(tc.Request); len(violations) > 0 {
+			t.Errorf("%s: %s", tc.Name, strings.Join(violations, "; "))
+		}
+	}
+}
+
+// OperationCoverage is how often each status code of an operation was seen.
+type OperationCoverage struct {
+	Method   string
+	Path     string
+	Statuses map[string]int
+	// 文档中声明但没有测试到的状态码
+	Missing []string
+}
+
+// Coverage returns one entry per operation in the spec, sorted by path.
+func (c *Contract) Coverage() []OperationCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var coverage []OperationCoverage
+	for path, operations := range c.spec.Paths {
+		for method, operation := range operations {
+			method = strings.ToUpper(method)
+			hits := c.hits[method+" "+path]
+			entry := OperationCoverage{Method: method, Path: path, Statuses: make(map[string]int)}
+			for status, count := range hits {
+				entry.Statuses[status] = count
+			}
+			for status := range operation.Responses {
+				if status != "default" && hits[status] == 0 {
+					entry.Missing = append(entry.Missing, status)
+				}
+			}
+			if operation.Responses["default"] != nil && len(hits) == 0 {
+				entry.Missing = append(entry.Missing, "default")
+			}
+			sort.Strings(entry.Missing)
+			coverage = append(coverage, entry)
+		}
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].Path != coverage[j].Path {
+			return coverage[i].Path < coverage[j].Path
+		}
+		return coverage[i].Method < coverage[j].Method
+	})
+	return coverage
+}
+
+// Report formats Coverage, e.g. for t.Log at the end of a suite.
+func (c *Contract) Report() string {
+	var b strings.Builder
+	covered, total := 0, 0
+	for _, entry := range c.Coverage() {
+		total++
+		mark := "  "
+		if len(entry.Statuses) > 0 {
+			covered++
+			mark = "ok"
+		}
+		statuses := make([]string, 0, len(entry.Statuses))
+		for status, count := range entry.Statuses {
+			statuses = append(statuses, fmt.Sprintf("%s×%d", status, count))
+		}
+		sort.Strings(statuses)
+		fmt.Fprintf(&b, "%s %-6s %s %s", mark, entry.Method, entry.Path, strings.Join(statuses, " "))
+		if len(entry.Missing) > 0 {
+			fmt.Fprintf(&b, " (missing %s)", strings.Join(entry.Missing, ", "))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d/%d operations exercised\n", covered, total)
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package cybertest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// The Fuzz functions are entry points for go-fuzz (go-fuzz-build -func
+// FuzzRouter). fuzz_test.go runs them as native fuzz targets with seed
+// corpora, e.g. go test -fuzz=FuzzRouter ./cybertest.
+//
+// They panic on a crash or a recovered handler panic and return 1 for
+// inputs worth keeping in the corpus, following the go-fuzz convention.
+
+var (
+	fuzzOnce sync.Once
+	fuzzApp  *cyber.App
+)
+
+func fuzzRoutes() *cyber.App {
+	fuzzOnce.Do(func() {
+		// 使用独立的mux，不在使用方的默认mux上注册路由
+		fuzzApp = cyber.NewApp(&cyber.AppConfig{Mux: http.NewServeMux()})
+		fuzzApp.SetMode(cyber.ReleaseMode)
+		echo := func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.PathValue("id") + r.PathValue("path")))
+		}
+		fuzzApp.Get("/users/{id}", echo)
+		fuzzApp.Post("/users/{id}", echo)
+		fuzzApp.Get("/files/{path...}", echo)
+		fuzzApp.Group("/api").Group("v1").Get("/items/{id}", echo)
+		fuzzApp.Mount("/static", http.HandlerFunc(echo))
+	})
+	return fuzzApp
+}
+
+var fuzzMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, "HEAD", "PROPFIND"}
+
+// FuzzRouter dispatches data as a raw request URI (the first byte selects
+// the method) through a fixed route table: unicode, encoded slashes, dot
+// segments and long segments must never panic or produce a 500.
+func FuzzRouter(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	method := fuzzMethods[int(data[0])%len(fuzzMethods)]
+	u, err := url.ParseRequestURI("/" + strings.TrimPrefix(string(data[1:]), "/"))
+	if err != nil {
+		return 0
+	}
+	req := &http.Request{
+		Method:     method,
+		URL:        u,
+		RequestURI: u.RequestURI(),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       "fuzz",
+		Body:       http.NoBody,
+	}
+	rec := httptest.NewRecorder()
+	fuzzRoutes().Handler().ServeHTTP(rec, req)
+	if rec.Code == http.StatusInternalServerError {
+		panic("router returned 500 for " + req.RequestURI)
+	}
+	if rec.Code == http.StatusOK {
+		return 1
+	}
+	return 0
+}
+
+type fuzzBindTarget struct {
+	Name  string                 `json:"name"`
+	Age   int                    `json:"age"`
+	Tags  []string               `json:"tags"`
+	Extra map[string]interface{} `json:"extra"`
+	Next  *fuzzBindTarget        `json:"next"`
+}
+
+// FuzzBind feeds data as a request body to BindJSON and a Typed handler,
+// which must answer with a 4xx instead of panicking on malformed input.
+func FuzzBind(data []byte) int {
+	var target fuzzBindTarget
+	bindErr := cyber.BindJSON(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data)), &target)
+
+	handler := cyber.Typed(func(r *http.Request, req fuzzBindTarget) (fuzzBindTarget, error) {
+		return req, nil
+	})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	handler(rec, req)
+	if rec.Code >= http.StatusInternalServerError {
+		panic("Typed handler returned " + rec.Result().Status)
+	}
+	if bindErr == nil {
+		return 1
+	}
+	return 0
+}
+
+// FuzzAccept parses data as Accept, Accept-Language and Accept-Encoding
+// headers.
+func FuzzAccept(data []byte) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	value := string(data)
+	req.Header.Set("Accept", value)
+	req.Header.Set("Accept-Language", value)
+	req.Header.Set("Accept-Encoding", value)
+	cyber.Accepts(req, "application/json", "text/html", "text/*")
+	cyber.AcceptsLanguages(req, "en", "zh-CN")
+	cyber.AcceptsEncodings(req, "gzip", "br")
+	return 0
+}
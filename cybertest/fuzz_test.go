@@ -0,0 +1,55 @@
+package cybertest_test
+
+import (
+	"testing"
+
+	"github.com/suonanjiexi/cyber/cybertest"
+)
+
+func FuzzRouter(f *testing.F) {
+	for _, seed := range []string{
+		"\x00users/42",
+		"\x01users/%E4%B8%AD%E6%96%87",
+		"\x00files/a/b/../c",
+		"\x00files/a%2Fb",
+		"\x00api/v1/items/7?q=1",
+		"\x05static/./x",
+		"\x03users//",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cybertest.FuzzRouter(data)
+	})
+}
+
+func FuzzBind(f *testing.F) {
+	for _, seed := range []string{
+		`{"name":"a","age":1,"tags":["x"],"extra":{"k":[1,2]}}`,
+		`{"next":{"next":{"name":"b"}}}`,
+		`{"age":"1"}`,
+		`{"age":1e400}`,
+		`[`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cybertest.FuzzBind(data)
+	})
+}
+
+func FuzzAccept(f *testing.F) {
+	for _, seed := range []string{
+		"text/html,application/json;q=0.9,*/*;q=0.1",
+		"zh-CN,zh;q=0.9,en;q=0.8",
+		"gzip;q=0, br",
+		";q=",
+		"*/*;q=abc",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cybertest.FuzzAccept(data)
+	})
+}
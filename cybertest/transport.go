@@ -0,0 +1,200 @@
+// Package cybertest provides helpers for testing services built on cyber.
+package cybertest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+type Mode int
+
+const (
+	// Replay 只从fixture文件返回响应，未匹配的请求返回错误
+	Replay Mode = iota
+	// Record 把请求转发到真实服务并记录响应
+	Record
+)
+
+// RecordEnv set to a non-empty value makes ModeFromEnv return Record, so
+// fixtures can be refreshed with e.g. CYBERTEST_RECORD=1 go test ./...
+const RecordEnv = "CYBERTEST_RECORD"
+
+func ModeFromEnv() Mode {
+	if os.Getenv(RecordEnv) != "" {
+		return Record
+	}
+	return Replay
+}
+
+// Fixture is one recorded exchange. Request headers are not recorded, so
+// credentials never end up in fixture files.
+type Fixture struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body,omitempty"`
+	// 响应体不是UTF-8文本时以base64保存
+	BodyBase64 bool `json:"body_base64,omitempty"`
+}
+
+// Transport is an http.RoundTripper that records outbound requests to a
+// fixture file or replays them from it, making tests of handlers that call
+// other services deterministic. Use it as the HTTPClient transport of the
+// oauth, storage or shadow configs, or of any client a handler uses.
+type Transport struct {
+	path string
+	mode Mode
+	// Next 是录制模式下实际发送请求的Transport，默认http.DefaultTransport
+	Next http.RoundTripper
+
+	mu       sync.Mutex
+	fixtures []Fixture
+	used     []bool
+}
+
+// NewTransport returns a transport for the fixture file at path. In Replay
+// mode the file must exist.
+func NewTransport(path string, mode Mode) (*Transport, error) {
+	t := &Transport{path: path, mode: mode, Next: http.DefaultTransport}
+	if mode == Record {
+		return t, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &t.fixtures); err != nil {
+		return nil, fmt.Errorf("cybertest: invalid fixture file %s: %w", path, err)
+	}
+	t.used = make([]bool, len(t.fixtures))
+	return t, nil
+}
+
+// Client returns an http.Client using the transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	if t.mode == Record {
+		return t.record(req, reqBody)
+	}
+	return t.replay(req, reqBody)
+}
+
+func (t *Transport) record(req *http.Request, reqBody []byte) (*http.Response, error) {
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	fixture := Fixture{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(reqBody),
+		Status:      resp.StatusCode,
+		Header:      resp.Header.Clone(),
+	}
+	fixture.Header.Del("Set-Cookie")
+	if utf8.Valid(body) {
+		fixture.Body = string(body)
+	} else {
+		fixture.Body, fixture.BodyBase64 = base64.StdEncoding.EncodeToString(body), true
+	}
+	t.mu.Lock()
+	t.fixtures = append(t.fixtures, fixture)
+	t.used = append(t.used, true)
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// replay 返回第一个未使用的匹配记录；全部用过时重复使用最后一个，以便支持重试
+func (t *Transport) replay(req *http.Request, reqBody []byte) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	match := -1
+	for i, f := range t.fixtures {
+		if f.Method != req.Method || f.URL != req.URL.String() || f.RequestBody != string(reqBody) {
+			continue
+		}
+		match = i
+		if !t.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("cybertest: no fixture for %s %s in %s", req.Method, req.URL, t.path)
+	}
+	t.used[match] = true
+	f := t.fixtures[match]
+	body := []byte(f.Body)
+	if f.BodyBase64 {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(f.Body); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded fixtures in Record mode; in Replay mode it does
+// nothing. Call it at the end of the test, e.g. t.Cleanup.
+func (t *Transport) Save() error {
+	if t.mode != Record {
+		return nil
+	}
+	t.mu.Lock()
+	data, err := json.MarshalIndent(t.fixtures, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, append(data, '\n'), 0o644)
+}
+
+// Unused returns the fixtures that were never replayed, to catch tests that
+// stopped making an expected call.
+func (t *Transport) Unused() []Fixture {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var unused []Fixture
+	for i, f := range t.fixtures {
+		if !t.used[i] {
+			unused = append(unused, f)
+		}
+	}
+	return unused
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+type Config struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// 启动时Ping数据库的超时时间
+	PingTimeout time.Duration
+}
+
+var defaultConfig = Config{
+	MaxOpenConns:    25,
+	MaxIdleConns:    25,
+	ConnMaxLifetime: 30 * time.Minute,
+	ConnMaxIdleTime: 5 * time.Minute,
+	PingTimeout:     5 * time.Second,
+}
+
+// Open creates a connection pool whose lifecycle is tied to app: it is pinged
+// when the app starts and closed when the app shuts down.
+func Open(app *cyber.App, driverName, dsn string, config *Config) (*sql.DB, error) {
+	if config == nil {
+		config = &defaultConfig
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	pingTimeout := config.PingTimeout
+	if pingTimeout <= 0 {
+		pingTimeout = defaultConfig.PingTimeout
+	}
+	app.OnStart(func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		return db.PingContext(ctx)
+	})
+	app.OnStop(func(ctx context.Context) error {
+		return db.Close()
+	})
+	return db, nil
+}
+
+// Querier is implemented by both *sql.DB and *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// Conn returns the request's transaction if middleware.Transaction opened
+// one, otherwise db itself.
+func Conn(r *http.Request, db *sql.DB) Querier {
+	if tx, ok := TxFromContext(r.Context()); ok {
+		return tx
+	}
+	return db
+}
+
+// HealthCheck returns a check function suitable for readiness probes.
+func HealthCheck(db *sql.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// HealthHandler responds 200 with pool statistics when the database is
+// reachable and 503 otherwise. Register it with app.Handle.
+func HealthHandler(db *sql.DB) http.HandlerFunc {
+	check := HealthCheck(db)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		status, code := "ok", http.StatusOK
+		errMessage := ""
+		if err := check(ctx); err != nil {
+			status, code, errMessage = "unavailable", http.StatusServiceUnavailable, err.Error()
+		}
+		stats := db.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":           status,
+			"error":            errMessage,
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+			"wait_count":       stats.WaitCount,
+		})
+	}
+}
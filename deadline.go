@@ -0,0 +1,54 @@
+package cyber
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// 为写出响应预留的时间上限
+const maxWriteMargin = time.Second
+
+// prepareRequest 放入请求状态，并根据WriteTimeout为请求context设置截止时间：
+// 超过WriteTimeout后连接上的写入会失败，handler应当在此之前结束，预留部分时间用于写出响应。
+// 两者合并为一次WithContext，每个请求只复制一次*http.Request。
+// 返回的cancel在请求结束时调用，调试模式下同时检查请求启动的goroutine
+func (app *App) prepareRequest(r *http.Request, route *Route) (*http.Request, context.CancelFunc) {
+	// 批量请求的子请求放入的是nil状态，不算嵌套
+	outer, _ := r.Context().Value(requestStateKey{}).(*requestState)
+	nested := outer != nil
+	ctx := withRequestState(r.Context(), app, route)
+	cancel := context.CancelFunc(func() {})
+	if timeout := app.Server.WriteTimeout; timeout > 0 {
+		margin := timeout / 10
+		if margin > maxWriteMargin {
+			margin = maxWriteMargin
+		}
+		ctx, cancel = context.WithTimeout(ctx, timeout-margin)
+	}
+	if app.IsDebug() && !nested {
+		state := ctx.Value(requestStateKey{}).(*requestState)
+		cancelTimeout := cancel
+		cancel = func() {
+			cancelTimeout()
+			state.finishGoroutines()
+		}
+	}
+	if ctx == r.Context() {
+		return r, cancel
+	}
+	return r.WithContext(ctx), cancel
+}
+
+// TimeRemaining returns the time left before the request's deadline, which
+// is derived from the server's WriteTimeout and narrowed by middleware such
+// as middleware.Timeout. ok is false when the request has no deadline.
+// Database and HTTP client calls made with r.Context() stop at the same
+// deadline.
+func TimeRemaining(r *http.Request) (remaining time.Duration, ok bool) {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
@@ -0,0 +1,81 @@
+package cyber
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadRoutesYAML is LoadRoutes for a YAML manifest:
+//
+//	routes:
+//	  - method: GET
+//	    pattern: /users/:id
+//	    handler: showUser
+func (app *App) LoadRoutesYAML(r io.Reader, factories map[string]HandlerFactory) error {
+	var manifest RouteManifest
+	if err := yaml.NewDecoder(r).Decode(&manifest); err != nil {
+		return fmt.Errorf("cyber: invalid route manifest: %w", err)
+	}
+	return app.RegisterManifest(manifest, factories)
+}
+
+var handlerFuncType = reflect.TypeOf(http.HandlerFunc(nil))
+
+// RegisterController registers the handler fields of a controller struct
+// tagged with a route, for example:
+//
+//	type UserController struct {
+//		List http.HandlerFunc `route:"GET /users"`
+//		Show http.HandlerFunc `route:"GET /users/:id" name:"user"`
+//	}
+//
+// Fields may be http.HandlerFunc or func(http.ResponseWriter, *http.Request);
+// untagged fields are ignored and nil handlers are an error.
+func (app *App) RegisterController(controller interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(controller))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cyber: controller must be a struct, got %T", controller)
+	}
+	var manifest RouteManifest
+	factories := make(map[string]HandlerFactory)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		tag, ok := field.Tag.Lookup("route")
+		if !ok {
+			continue
+		}
+		method, pattern, found := strings.Cut(strings.TrimSpace(tag), " ")
+		if !found {
+			// 只写路径时不限制方法
+			method, pattern = "", method
+		}
+		if !field.IsExported() || !field.Type.ConvertibleTo(handlerFuncType) || v.Field(i).IsNil() {
+			return fmt.Errorf("cyber: controller field %s must be a non-nil exported handler", field.Name)
+		}
+		handler := v.Field(i).Convert(handlerFuncType).Interface().(http.HandlerFunc)
+		factories[field.Name] = func(map[string]string) (http.HandlerFunc, error) { return handler, nil }
+		manifest.Routes = append(manifest.Routes, &Route{
+			Method:  strings.ToUpper(method),
+			Pattern: strings.TrimSpace(pattern),
+			Name:    field.Tag.Get("name"),
+			Handler: field.Name,
+		})
+	}
+	return app.RegisterManifest(manifest, factories)
+}
+
+// convertPathParams 把 /users/:id 形式的参数转换为ServeMux的 /users/{id}
+func convertPathParams(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") && len(segment) > 1 {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
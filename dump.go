@@ -0,0 +1,181 @@
+package cyber
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// 请求转储中请求体的最大字节数
+const maxDumpBody = 64 << 10
+
+// 替换被隐藏内容的值
+const redactedValue = "[redacted]"
+
+// Redaction lists what a dump hides. Query parameters and fields are
+// matched case-insensitively.
+type Redaction struct {
+	// 请求头和响应头，例如 Authorization
+	Headers []string
+	// 查询参数，例如 access_token、code
+	Query []string
+	// 请求体和响应体中的字段：JSON在任意嵌套层级匹配，表单按字段名匹配
+	Fields []string
+}
+
+// defaultRedaction 是 DumpRequest 和调试错误页隐藏的内容
+var defaultRedaction = Redaction{
+	Headers: []string{"Authorization", "Cookie", "Proxy-Authorization"},
+	Query:   []string{"access_token", "id_token", "refresh_token", "code", "token", "client_secret"},
+	Fields:  []string{"password", "access_token", "id_token", "refresh_token", "token", "client_secret", "secret"},
+}
+
+// DumpRequest returns the request in wire format for debugging, with
+// credential headers, query parameters and body fields redacted. With
+// includeBody the first 64KB of the body is included and r.Body is restored
+// so handlers can still read it.
+func DumpRequest(r *http.Request, includeBody bool) ([]byte, error) {
+	return DumpRequestRedacted(r, includeBody, maxDumpBody, defaultRedaction)
+}
+
+// DumpRequestRedacted is DumpRequest with a custom body cap and redaction.
+func DumpRequestRedacted(r *http.Request, includeBody bool, maxBody int64, redact Redaction) ([]byte, error) {
+	clone := r.Clone(r.Context())
+	clone.Header = redact.Header(r.Header)
+	clone.URL.RawQuery = redactPairs(r.URL.RawQuery, redact.Query)
+	// httputil.DumpRequest优先使用RequestURI，其中是未隐藏的原始查询串
+	clone.RequestURI = ""
+	dump, err := httputil.DumpRequest(clone, false)
+	if err != nil || !includeBody || r.Body == nil || r.Body == http.NoBody {
+		return dump, err
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	// 已读取的部分与剩余部分拼接，保证handler读到完整的请求体
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return dump, err
+	}
+	truncated := int64(len(body)) > maxBody
+	if truncated {
+		body = body[:maxBody]
+	}
+	dump = append(dump, redact.Body(r.Header.Get("Content-Type"), body, truncated)...)
+	if truncated {
+		dump = append(dump, fmt.Sprintf("\n[truncated at %d bytes]", maxBody)...)
+	}
+	return dump, nil
+}
+
+// Header returns a copy of header with the configured headers redacted.
+func (rd Redaction) Header(header http.Header) http.Header {
+	header = header.Clone()
+	for _, key := range rd.Headers {
+		if header.Get(key) != "" {
+			header.Set(key, redactedValue)
+		}
+	}
+	return header
+}
+
+// Body returns body, of the given content type, with the configured fields
+// redacted. JSON and form bodies, and untyped bodies that are valid JSON,
+// are redacted; a JSON body that is truncated or malformed can't be parsed
+// and is replaced by a note. Other bodies, such as multipart forms, are
+// returned unchanged.
+func (rd Redaction) Body(contentType string, body []byte, truncated bool) []byte {
+	if len(rd.Fields) == 0 || len(body) == 0 {
+		return body
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return []byte(redactPairs(string(body), rd.Fields))
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "application/x-ndjson":
+		if truncated {
+			return []byte("[body omitted: truncated JSON can't be redacted]")
+		}
+		redacted, err := redactJSON(body, rd.Fields)
+		if err != nil {
+			return []byte("[body omitted: malformed JSON can't be redacted]")
+		}
+		return redacted
+	case mediaType == "" && !truncated && json.Valid(body):
+		// 没有声明类型的JSON请求体同样需要隐藏
+		redacted, _ := redactJSON(body, rd.Fields)
+		return redacted
+	}
+	return body
+}
+
+// redactPairs 隐藏 a=1&b=2 形式的查询串或表单中指定名称的值，保留原有顺序
+func redactPairs(raw string, names []string) string {
+	if raw == "" || len(names) == 0 {
+		return raw
+	}
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil && containsFold(names, name) {
+			pairs[i] = key + "=" + redactedValue
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactJSON 逐个解析JSON值（支持NDJSON等JSON流）并隐藏指定字段，字段顺序不保留
+func redactJSON(body []byte, fields []string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	for {
+		var value interface{}
+		if err := dec.Decode(&value); err == io.EOF {
+			return out.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(redactValue(value, fields)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func redactValue(value interface{}, fields []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if containsFold(fields, key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(field, fields)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i], fields)
+		}
+	}
+	return value
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,78 @@
+package cyber
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+type EnvelopeConfig struct {
+	// 成功响应的code和message，默认 "ok" 和 "success"
+	SuccessCode    string
+	SuccessMessage string
+	// TraceID 返回写入trace_id的值，默认取 traceparent 请求头中的trace-id，其次 X-Request-Id
+	TraceID func(r *http.Request) string
+}
+
+var defaultEnvelopeConfig = EnvelopeConfig{
+	SuccessCode:    "ok",
+	SuccessMessage: "success",
+	TraceID:        traceID,
+}
+
+// Envelope is the body Success and Error write when the app uses
+// UseEnvelope. Timestamp is in Unix milliseconds.
+type Envelope struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data"`
+	TraceID   string      `json:"trace_id,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// UseEnvelope makes Success and Error wrap every response in an Envelope,
+// {code, message, data, trace_id, timestamp}, so services share one
+// response contract. The HTTP status is unchanged. A nil config uses the
+// defaults. Like SetJSONCodec, call it before the app starts serving.
+func (app *App) UseEnvelope(config *EnvelopeConfig) {
+	if config == nil {
+		config = &defaultEnvelopeConfig
+	}
+	c := *config
+	if c.SuccessCode == "" {
+		c.SuccessCode = defaultEnvelopeConfig.SuccessCode
+	}
+	if c.SuccessMessage == "" {
+		c.SuccessMessage = defaultEnvelopeConfig.SuccessMessage
+	}
+	if c.TraceID == nil {
+		c.TraceID = defaultEnvelopeConfig.TraceID
+	}
+	app.envelope = &c
+}
+
+// envelopeFor 返回请求所属应用的信封配置，未启用时返回nil
+func envelopeFor(r *http.Request) *EnvelopeConfig {
+	if state := stateFromRequest(r); state != nil && state.app != nil {
+		return state.app.envelope
+	}
+	return nil
+}
+
+func (c *EnvelopeConfig) wrap(r *http.Request, code, message string, data interface{}) Envelope {
+	return Envelope{
+		Code:      code,
+		Message:   message,
+		Data:      data,
+		TraceID:   c.TraceID(r),
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
+// traceID 读取W3C traceparent（00-<trace-id>-<parent-id>-<flags>）中的trace-id，其次是请求ID
+func traceID(r *http.Request) string {
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+	return r.Header.Get("X-Request-Id")
+}
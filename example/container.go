@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/db"
+	"github.com/suonanjiexi/cyber/example/repository"
+	"github.com/suonanjiexi/cyber/example/service"
+	"github.com/suonanjiexi/cyber/jwt"
+	"github.com/suonanjiexi/cyber/middleware"
+)
+
+// container 集中创建并持有应用依赖，handler通过它拿到service而不是直接访问存储
+type container struct {
+	userService *service.UserService
+	// 写操作使用的事务中间件，使用内存存储时为nil
+	tx cyber.Middleware
+	// tokens 签发登录令牌；密钥只在内存中，重启后之前签发的令牌失效
+	tokens *jwt.Keyring
+}
+
+// newContainer 在设置了 DB_DRIVER 和 DB_DSN 时使用SQL存储，否则使用内存存储。
+// 本程序不注册任何数据库驱动，需要先在自己的程序中导入驱动；基于sqlite的完整示例见 example/sqlite 模块
+func newContainer(app *cyber.App) (*container, error) {
+	tokens, err := jwt.NewKeyring(jwt.KeyringConfig{RotateEvery: 24 * time.Hour})
+	if err != nil {
+		return nil, err
+	}
+	driver, dsn := os.Getenv("DB_DRIVER"), os.Getenv("DB_DSN")
+	if driver == "" || dsn == "" {
+		return &container{userService: service.NewUserService(repository.NewMemoryUserStore()), tokens: tokens}, nil
+	}
+	database, err := db.Open(app, driver, dsn, nil)
+	if err != nil {
+		return nil, err
+	}
+	store := repository.NewSQLUserStore(database)
+	app.OnStart(func(ctx context.Context) error {
+		return store.Migrate(ctx)
+	})
+	return &container{
+		userService: service.NewUserService(store),
+		tx:          middleware.Transaction(database),
+		tokens:      tokens,
+	}, nil
+}
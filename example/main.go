@@ -21,7 +21,13 @@ func main() {
 		fmt.Println("Hello, World!")
 		cyber.Success(w, r, http.StatusOK, "Hello, World!")
 	})
-	routers.UserRoutes(app)
+	deps, err := newContainer(app)
+	if err != nil {
+		log.Fatalf("Failed to initialize dependencies: %v", err)
+	}
+	routers.UserRoutes(app, deps.userService, deps.tokens, deps.tx)
+	// 发布校验登录令牌的公钥
+	app.WellKnownHandler("jwks.json", deps.tokens.Handler)
 	routers.OrderRoutes(app)
 	// 启动服务器
 	if err := app.Run(); err != nil {
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type User struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// DeletedAt 非空表示已软删除，可以恢复
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Deleted reports whether the user has been soft-deleted.
+func (u *User) Deleted() bool {
+	return u.DeletedAt != nil
+}
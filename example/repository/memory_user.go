@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber/example/model"
+)
+
+type MemoryUserStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	users  map[int64]model.User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[int64]model.User)}
+}
+
+func (s *MemoryUserStore) Create(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 已软删除的用户仍占用邮箱，恢复时才不会冲突
+	for _, u := range s.users {
+		if u.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	s.nextID++
+	now := time.Now()
+	user.ID, user.CreatedAt, user.UpdatedAt = s.nextID, now, now
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *MemoryUserStore) Get(ctx context.Context, id int64, filter Filter) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[id]
+	if !ok || (user.Deleted() && !filter.IncludeDeleted) {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *MemoryUserStore) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, user := range s.users {
+		if user.Email == email && !user.Deleted() {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryUserStore) List(ctx context.Context, filter Filter) ([]*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]*model.User, 0, len(s.users))
+	for _, user := range s.users {
+		user := user
+		if user.Deleted() && !filter.IncludeDeleted {
+			continue
+		}
+		users = append(users, &user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (s *MemoryUserStore) Update(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.users[user.ID]
+	if !ok || stored.Deleted() {
+		return ErrNotFound
+	}
+	// 审计字段由存储维护，不接受调用方修改
+	user.CreatedAt, user.DeletedAt = stored.CreatedAt, nil
+	user.UpdatedAt = time.Now()
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *MemoryUserStore) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok || user.Deleted() {
+		return ErrNotFound
+	}
+	now := time.Now()
+	user.DeletedAt, user.UpdatedAt = &now, now
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) Restore(ctx context.Context, id int64) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// 恢复未删除的记录不是错误，重复请求得到同样的结果
+	if user.Deleted() {
+		user.DeletedAt, user.UpdatedAt = nil, time.Now()
+		s.users[id] = user
+	}
+	return &user, nil
+}
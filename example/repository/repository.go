@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/suonanjiexi/cyber/example/model"
+)
+
+var (
+	ErrNotFound  = errors.New("repository: not found")
+	ErrDuplicate = errors.New("repository: duplicate")
+)
+
+// Filter 是查询的通用过滤条件
+type Filter struct {
+	// 包含已软删除的记录，对应查询参数 ?include_deleted=true
+	IncludeDeleted bool
+}
+
+// UserRepository 是service层依赖的存储接口，内存和SQL实现可以互换。
+// Delete 是软删除：记录保留并设置 DeletedAt，Restore 撤销删除；
+// 除非 Filter.IncludeDeleted，否则查询不返回已删除的记录。
+// 存储自动维护 CreatedAt、UpdatedAt 和 DeletedAt。
+type UserRepository interface {
+	Create(ctx context.Context, user *model.User) error
+	Get(ctx context.Context, id int64, filter Filter) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	List(ctx context.Context, filter Filter) ([]*model.User, error)
+	Update(ctx context.Context, user *model.User) error
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) (*model.User, error)
+}
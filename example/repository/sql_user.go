@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/suonanjiexi/cyber/db"
+	"github.com/suonanjiexi/cyber/example/model"
+)
+
+const userSchema = `CREATE TABLE IF NOT EXISTS users (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	name          TEXT NOT NULL,
+	email         TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	updated_at    TIMESTAMP NOT NULL,
+	deleted_at    TIMESTAMP
+)`
+
+const userColumns = `id, name, email, password_hash, created_at, updated_at, deleted_at`
+
+// SQLUserStore 基于database/sql实现（SQL语句按sqlite编写）。
+// 请求经过 middleware.Transaction 时，所有操作都在同一个事务中执行（unit of work）。
+type SQLUserStore struct {
+	db *sql.DB
+}
+
+func NewSQLUserStore(database *sql.DB) *SQLUserStore {
+	return &SQLUserStore{db: database}
+}
+
+func (s *SQLUserStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, userSchema); err != nil {
+		return err
+	}
+	// 旧版本创建的表没有 deleted_at 列
+	if _, err := s.db.ExecContext(ctx, `SELECT deleted_at FROM users LIMIT 0`); err != nil {
+		_, err = s.db.ExecContext(ctx, `ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP`)
+		return err
+	}
+	return nil
+}
+
+// conn 优先使用请求上下文中的事务
+func (s *SQLUserStore) conn(ctx context.Context) db.Querier {
+	if tx, ok := db.TxFromContext(ctx); ok {
+		return tx
+	}
+	return s.db
+}
+
+func (s *SQLUserStore) Create(ctx context.Context, user *model.User) error {
+	now := time.Now()
+	result, err := s.conn(ctx).ExecContext(ctx,
+		`INSERT INTO users (name, email, password_hash, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		user.Name, user.Email, user.PasswordHash, now, now)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "unique") {
+			return ErrDuplicate
+		}
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	user.ID, user.CreatedAt, user.UpdatedAt = id, now, now
+	return nil
+}
+
+// notDeleted 返回排除已软删除记录的条件
+func notDeleted(filter Filter) string {
+	if filter.IncludeDeleted {
+		return ""
+	}
+	return ` AND deleted_at IS NULL`
+}
+
+func (s *SQLUserStore) Get(ctx context.Context, id int64, filter Filter) (*model.User, error) {
+	return s.scanOne(s.conn(ctx).QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = ?`+notDeleted(filter), id))
+}
+
+func (s *SQLUserStore) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return s.scanOne(s.conn(ctx).QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE email = ?`+notDeleted(Filter{}), email))
+}
+
+func (s *SQLUserStore) List(ctx context.Context, filter Filter) ([]*model.User, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE 1 = 1`+notDeleted(filter)+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []*model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLUserStore) Update(ctx context.Context, user *model.User) error {
+	user.UpdatedAt = time.Now()
+	result, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE users SET name = ?, email = ?, password_hash = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`,
+		user.Name, user.Email, user.PasswordHash, user.UpdatedAt, user.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (s *SQLUserStore) Delete(ctx context.Context, id int64) error {
+	now := time.Now()
+	result, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE users SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`, now, now, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (s *SQLUserStore) Restore(ctx context.Context, id int64) (*model.User, error) {
+	// 恢复未删除的记录不是错误，重复请求得到同样的结果
+	if _, err := s.conn(ctx).ExecContext(ctx,
+		`UPDATE users SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL`, time.Now(), id); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id, Filter{})
+}
+
+func (s *SQLUserStore) scanOne(row *sql.Row) (*model.User, error) {
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return user, err
+}
+
+func scanUser(row interface{ Scan(dest ...any) error }) (*model.User, error) {
+	var user model.User
+	var deletedAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	return &user, nil
+}
+
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -6,15 +6,19 @@ import (
 	"net/http"
 )
 
+type orderController struct{}
+
+func (orderController) Index(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("API Order")
+	cyber.Success(w, r, http.StatusOK, "API Order")
+}
+
+func (orderController) Show(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("API Order id ")
+	cyber.Success(w, r, http.StatusOK, "API Order id "+r.PathValue("id"))
+}
+
 func OrderRoutes(app *cyber.App) {
-	//定义路由组
-	order := app.Group("/order")
-	order.Get("/detail", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("API Order")
-		cyber.Success(w, r, http.StatusOK, "API Order")
-	})
-	order.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("API Order id ")
-		cyber.Success(w, r, http.StatusOK, "API Order id ")
-	})
+	// GET /order 和 GET /order/{id}
+	app.Resource("/order", orderController{}, nil)
 }
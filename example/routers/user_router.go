@@ -1,18 +1,199 @@
 package routers
 
 import (
-	"fmt"
-	"github.com/suonanjiexi/cyber"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/auth"
+	"github.com/suonanjiexi/cyber/example/model"
+	"github.com/suonanjiexi/cyber/example/repository"
+	"github.com/suonanjiexi/cyber/example/service"
+	"github.com/suonanjiexi/cyber/jwt"
 )
 
-func UserRoutes(app *cyber.App) {
+type registerRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string      `json:"token"`
+	ExpiresAt int64       `json:"expires_at"`
+	User      *model.User `json:"user"`
+}
+
+// 登录令牌的有效期
+const tokenTTL = time.Hour
+
+// UserRoutes 注册用户相关路由。tokens 签发登录令牌，tx 为写操作包装事务中间件，可以为nil
+func UserRoutes(app *cyber.App, users *service.UserService, tokens *jwt.Keyring, tx cyber.Middleware) {
+	if tx == nil {
+		tx = func(next http.HandlerFunc) http.HandlerFunc { return next }
+	}
+	guard := auth.NewGuard(&auth.GuardConfig{
+		// 实际应用中通过邮件等渠道把解锁令牌发给用户
+		OnLockout: func(username, ip, token string) {
+			log.Printf("Account %s locked for %s, unlock token issued", username, ip)
+		},
+	})
 	user := app.Group("/user")
-	user.Get("/user", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("API User")
-		cyber.Success(w, r, http.StatusOK, "API User")
+	user.Post("/register", tx(func(w http.ResponseWriter, r *http.Request) {
+		var req registerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			cyber.Error(w, r, http.StatusBadRequest, "invalid_body", "request body must be JSON")
+			return
+		}
+		created, err := users.Register(r.Context(), req.Name, req.Email, req.Password)
+		if err != nil {
+			writeUserError(w, r, err)
+			return
+		}
+		cyber.Success(w, r, http.StatusCreated, created)
+	}))
+	user.Post("/login", guard.Protect(loginEmail)(func(w http.ResponseWriter, r *http.Request) {
+		var req loginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			cyber.Error(w, r, http.StatusBadRequest, "invalid_body", "request body must be JSON")
+			return
+		}
+		logged, err := users.Authenticate(r.Context(), req.Email, req.Password)
+		if err != nil {
+			// 密码错误返回401，由guard计为一次失败
+			writeUserError(w, r, err)
+			return
+		}
+		expires := time.Now().Add(tokenTTL)
+		token, err := tokens.Sign(jwt.Claims{
+			"sub": strconv.FormatInt(logged.ID, 10),
+			"exp": expires.Unix(),
+			"iat": time.Now().Unix(),
+		})
+		if err != nil {
+			writeUserError(w, r, err)
+			return
+		}
+		cyber.Success(w, r, http.StatusOK, loginResponse{Token: token, ExpiresAt: expires.Unix(), User: logged})
+	}))
+	user.Get("/list", func(w http.ResponseWriter, r *http.Request) {
+		list, err := users.List(r.Context(), queryFilter(r))
+		if err != nil {
+			writeUserError(w, r, err)
+			return
+		}
+		cyber.Success(w, r, http.StatusOK, list)
 	})
 	user.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
-		cyber.Success(w, r, http.StatusOK, "API Order id ")
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		found, err := users.Get(r.Context(), id, queryFilter(r))
+		if err != nil {
+			writeUserError(w, r, err)
+			return
+		}
+		cyber.Success(w, r, http.StatusOK, found)
 	})
+	user.Patch("/{id}", tx(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			cyber.Error(w, r, http.StatusBadRequest, "invalid_body", "request body must be JSON")
+			return
+		}
+		updated, err := users.Rename(r.Context(), id, req.Name)
+		if err != nil {
+			writeUserError(w, r, err)
+			return
+		}
+		cyber.Success(w, r, http.StatusOK, updated)
+	}))
+	user.Delete("/{id}", tx(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		if err := users.Delete(r.Context(), id); err != nil {
+			writeUserError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	user.Post("/{id}/restore", tx(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		restored, err := users.Restore(r.Context(), id)
+		if err != nil {
+			writeUserError(w, r, err)
+			return
+		}
+		cyber.Success(w, r, http.StatusOK, restored)
+	}))
+}
+
+// queryFilter 从查询参数读取通用过滤条件，例如 ?include_deleted=true
+func queryFilter(r *http.Request) repository.Filter {
+	includeDeleted, _ := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	return repository.Filter{IncludeDeleted: includeDeleted}
+}
+
+// loginEmail 读取登录请求中的email作为防暴力破解的key，并还原请求体供handler使用
+func loginEmail(r *http.Request) string {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var req loginRequest
+	json.Unmarshal(body, &req)
+	return strings.ToLower(strings.TrimSpace(req.Email))
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		cyber.Error(w, r, http.StatusBadRequest, "invalid_id", "id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeUserError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		cyber.Error(w, r, http.StatusNotFound, "not_found", "user not found")
+	case errors.Is(err, service.ErrEmailTaken):
+		cyber.Error(w, r, http.StatusConflict, "email_taken", err.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		cyber.Error(w, r, http.StatusUnauthorized, "invalid_credentials", err.Error())
+	case errors.Is(err, service.ErrInvalidInput),
+		errors.Is(err, auth.ErrPasswordTooShort),
+		errors.Is(err, auth.ErrPasswordTooLong),
+		errors.Is(err, auth.ErrPasswordTooWeak),
+		errors.Is(err, auth.ErrPasswordBlacklisted):
+		cyber.Error(w, r, http.StatusBadRequest, "invalid_input", err.Error())
+	default:
+		cyber.Error(w, r, http.StatusInternalServerError, "internal_error", "internal server error")
+	}
 }
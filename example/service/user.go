@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/suonanjiexi/cyber/auth"
+	"github.com/suonanjiexi/cyber/example/model"
+	"github.com/suonanjiexi/cyber/example/repository"
+)
+
+var (
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrEmailTaken         = errors.New("email already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// UserService 承载业务规则，handler只负责HTTP编解码
+type UserService struct {
+	users repository.UserRepository
+}
+
+func NewUserService(users repository.UserRepository) *UserService {
+	return &UserService{users: users}
+}
+
+func (s *UserService) Register(ctx context.Context, name, email, password string) (*model.User, error) {
+	name, email = strings.TrimSpace(name), strings.ToLower(strings.TrimSpace(email))
+	if name == "" || !strings.Contains(email, "@") {
+		return nil, ErrInvalidInput
+	}
+	if err := auth.DefaultPasswordPolicy.Validate(password); err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &model.User{Name: name, Email: email, PasswordHash: hash}
+	if err := s.users.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) Authenticate(ctx context.Context, email, password string) (*model.User, error) {
+	user, err := s.users.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := auth.CheckPassword(password, user.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (s *UserService) Get(ctx context.Context, id int64, filter repository.Filter) (*model.User, error) {
+	return s.users.Get(ctx, id, filter)
+}
+
+func (s *UserService) List(ctx context.Context, filter repository.Filter) ([]*model.User, error) {
+	return s.users.List(ctx, filter)
+}
+
+func (s *UserService) Rename(ctx context.Context, id int64, name string) (*model.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidInput
+	}
+	user, err := s.users.Get(ctx, id, repository.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	user.Name = name
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Delete 软删除用户，之后可以用 Restore 恢复
+func (s *UserService) Delete(ctx context.Context, id int64) error {
+	return s.users.Delete(ctx, id)
+}
+
+func (s *UserService) Restore(ctx context.Context, id int64) (*model.User, error) {
+	return s.users.Restore(ctx, id)
+}
@@ -0,0 +1,52 @@
+// Command sqlite runs the example user routes on a SQLite database. It is a
+// separate module so that the driver, modernc.org/sqlite, stays out of the
+// framework's go.mod.
+//
+//	cd example/sqlite && DB_DSN=file:cyber.db go run .
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/db"
+	"github.com/suonanjiexi/cyber/example/repository"
+	"github.com/suonanjiexi/cyber/example/routers"
+	"github.com/suonanjiexi/cyber/example/service"
+	"github.com/suonanjiexi/cyber/jwt"
+	"github.com/suonanjiexi/cyber/middleware"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	dsn := os.Getenv("DB_DSN")
+	if dsn == "" {
+		dsn = "file:cyber.db"
+	}
+	app := cyber.NewApp(nil)
+	app.Use(middleware.Recovery)
+	app.Use(middleware.Logger)
+	database, err := db.Open(app, "sqlite", dsn, nil)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	store := repository.NewSQLUserStore(database)
+	app.OnStart(func(ctx context.Context) error {
+		return store.Migrate(ctx)
+	})
+	tokens, err := jwt.NewKeyring(jwt.KeyringConfig{RotateEvery: 24 * time.Hour})
+	if err != nil {
+		log.Fatalf("Failed to create keyring: %v", err)
+	}
+	routers.UserRoutes(app, service.NewUserService(store), tokens, middleware.Transaction(database))
+	app.WellKnownHandler("jwks.json", tokens.Handler)
+	if err := app.Run(); err != nil {
+		log.Printf("Server error: %v", err)
+	}
+	if err := app.Shutdown(context.Background()); err != nil {
+		log.Printf("Failed to shutdown server: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package cyber
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// 导出时每写出多少行刷新一次，让客户端尽早开始下载
+const exportFlushRows = 100
+
+// 导出的缓冲区大小，第一次刷新之前出错时响应尚未开始，仍可返回错误
+const exportBufferSize = 32 << 10
+
+// utf8BOM 让Excel按UTF-8打开CSV，否则中文等非ASCII字符会乱码
+const utf8BOM = "\ufeff"
+
+// CSV streams rows as a CSV download named filename: header first, then
+// the rows returned by next until it returns io.EOF. The file starts with
+// a UTF-8 BOM so Excel detects the encoding. Rows are flushed as they go,
+// so exports use constant memory; fetch them page by page in next. An
+// export must finish before the request's deadline, which is derived from
+// the server's WriteTimeout; streaming stops when the client goes away.
+//
+// An error from next before anything was sent is returned and nothing is
+// written, so the caller can still respond with an error. Once rows have
+// been sent the status can't change: the response is aborted with
+// http.ErrAbortHandler, so the client sees a failed rather than a
+// truncated download.
+func CSV(w http.ResponseWriter, r *http.Request, statusCode int, filename string, header []string, next func() ([]string, error)) error {
+	ew := &exportWriter{w: w, r: r, status: statusCode, contentType: "text/csv; charset=utf-8", filename: filename}
+	bw := bufio.NewWriterSize(ew, exportBufferSize)
+	bw.WriteString(utf8BOM)
+	cw := csv.NewWriter(bw)
+	if len(header) > 0 {
+		cw.Write(header)
+	}
+	flush := func() error {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		return ew.flush()
+	}
+	err := exportRows(r, next, cw.Write, flush)
+	if err == nil {
+		err = flush()
+	}
+	return ew.finish(err)
+}
+
+// XLSX streams rows as a single-sheet Excel download named filename, like
+// CSV. Cells keep their types: numbers and booleans are numeric and
+// boolean cells, see XLSXWriter.
+func XLSX(w http.ResponseWriter, r *http.Request, statusCode int, filename string, header []string, next func() ([]interface{}, error)) error {
+	ew := &exportWriter{w: w, r: r, status: statusCode, contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", filename: filename}
+	bw := bufio.NewWriterSize(ew, exportBufferSize)
+	xw, err := NewXLSXWriter(bw, "Sheet1")
+	if err != nil {
+		return ew.finish(err)
+	}
+	if len(header) > 0 {
+		values := make([]interface{}, len(header))
+		for i, h := range header {
+			values[i] = h
+		}
+		xw.WriteRow(values...)
+	}
+	err = exportRows(r, next, func(row []interface{}) error { return xw.WriteRow(row...) }, func() error {
+		if err := xw.Flush(); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		return ew.flush()
+	})
+	if err == nil {
+		if err = xw.Close(); err == nil {
+			err = bw.Flush()
+		}
+	}
+	return ew.finish(err)
+}
+
+// exportRows 逐行写出直到next返回io.EOF，每exportFlushRows行调用一次flush
+func exportRows[T any](r *http.Request, next func() (T, error), write func(T) error, flush func() error) error {
+	for n := 1; ; n++ {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := write(row); err != nil {
+			return err
+		}
+		if n%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// exportWriter 在第一次写出时才发送响应头，出错时据此决定返回错误还是中断响应
+type exportWriter struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	status      int
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (ew *exportWriter) Write(p []byte) (int, error) {
+	if !ew.started {
+		ew.started = true
+		header := ew.w.Header()
+		header.Set("Content-Type", ew.contentType)
+		header.Set("Content-Disposition", contentDisposition(ew.filename))
+		header.Set("X-Content-Type-Options", "nosniff")
+		ew.w.WriteHeader(ew.status)
+	}
+	return ew.w.Write(p)
+}
+
+func (ew *exportWriter) flush() error {
+	if !ew.started {
+		return nil
+	}
+	err := http.NewResponseController(ew.w).Flush()
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+func (ew *exportWriter) finish(err error) error {
+	if err == nil || !ew.started {
+		return err
+	}
+	// 客户端已经断开时无需中断；截止时间到期等其他错误必须中断，否则客户端会收到看似完整的截断文件
+	if IsClientGone(ew.r) {
+		return err
+	}
+	log.Printf("Export aborted: %v", err)
+	panic(http.ErrAbortHandler)
+}
+
+// contentDisposition 生成附件下载头，非ASCII文件名按RFC 2231编码
+func contentDisposition(filename string) string {
+	filename = strings.NewReplacer("/", "_", "\\", "_").Replace(filename)
+	if filename == "" {
+		return "attachment"
+	}
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
@@ -0,0 +1,360 @@
+// Package feed renders Atom and RSS 2.0 feeds, e.g. for changelogs and
+// blogs, from a single Feed value.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// 两种格式的Content-Type
+const (
+	AtomType = "application/atom+xml"
+	RSSType  = "application/rss+xml"
+)
+
+type Feed struct {
+	Title string
+	// 网站的地址
+	Link string
+	// 订阅源自身的地址，阅读器据此识别订阅源
+	FeedURL     string
+	Description string
+	// 为空时使用FeedURL，再为空时使用Link
+	ID       string
+	Author   *Author
+	Language string
+	// 为零值时使用最新条目的更新时间
+	Updated time.Time
+	Items   []Item
+}
+
+type Item struct {
+	// 为空时使用Link；同一条目必须保持不变，阅读器据此去重
+	ID    string
+	Title string
+	Link  string
+	// 摘要，纯文本
+	Summary string
+	// 正文，HTML
+	Content   string
+	Author    *Author
+	Published time.Time
+	// 为零值时使用Published
+	Updated    time.Time
+	Categories []string
+}
+
+type Author struct {
+	Name  string
+	Email string
+	URI   string
+}
+
+// Render writes f as Atom or RSS 2.0, whichever the request prefers: the
+// "format" query parameter ("atom" or "rss") wins over the Accept header,
+// and Atom is the default. It answers conditional GETs with 304 via ETag
+// and Last-Modified, so polling readers don't download unchanged feeds.
+func Render(w http.ResponseWriter, r *http.Request, statusCode int, f *Feed) {
+	contentType := negotiate(r)
+	var body []byte
+	var err error
+	if contentType == RSSType {
+		body, err = f.RSS()
+	} else {
+		body, err = f.Atom()
+	}
+	if err != nil {
+		log.Printf("Error rendering feed: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h := fnv.New64a()
+	h.Write(body)
+	etag := fmt.Sprintf(`"%016x"`, h.Sum64())
+	header := w.Header()
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	header.Add("Vary", "Accept")
+	updated := f.updated()
+	if statusCode == http.StatusOK {
+		header.Set("ETag", etag)
+		if !updated.IsZero() {
+			header.Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+		}
+		if notModified(r, etag, updated) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+func negotiate(r *http.Request) string {
+	switch r.URL.Query().Get("format") {
+	case "rss":
+		return RSSType
+	case "atom":
+		return AtomType
+	}
+	if cyber.Accepts(r, AtomType, RSSType) == RSSType {
+		return RSSType
+	}
+	return AtomType
+}
+
+// notModified 优先比较If-None-Match，没有时才比较If-Modified-Since
+func notModified(r *http.Request, etag string, updated time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("If-None-Match") != "" {
+		return cyber.IfNoneMatch(r, etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || updated.IsZero() {
+		return false
+	}
+	// HTTP日期只精确到秒
+	return !updated.Truncate(time.Second).After(since)
+}
+
+func (f *Feed) updated() time.Time {
+	if !f.Updated.IsZero() {
+		return f.Updated
+	}
+	var latest time.Time
+	for i := range f.Items {
+		if t := f.Items[i].updated(); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+func (item *Item) updated() time.Time {
+	if !item.Updated.IsZero() {
+		return item.Updated
+	}
+	return item.Published
+}
+
+func (item *Item) id() string {
+	if item.ID != "" {
+		return item.ID
+	}
+	return item.Link
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"xml:lang,attr,omitempty"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Summary string      `xml:"subtitle,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+	URI   string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// Atom encodes f as an Atom 1.0 document (RFC 4287).
+func (f *Feed) Atom() ([]byte, error) {
+	feed := atomFeed{
+		Lang:    f.Language,
+		Title:   f.Title,
+		ID:      f.ID,
+		Updated: atomTime(f.updated()),
+		Author:  atomAuthor(f.Author),
+		Summary: f.Description,
+	}
+	if feed.ID == "" {
+		feed.ID = f.FeedURL
+	}
+	if feed.ID == "" {
+		feed.ID = f.Link
+	}
+	if f.Link != "" {
+		feed.Links = append(feed.Links, atomLink{Href: f.Link, Rel: "alternate"})
+	}
+	if f.FeedURL != "" {
+		feed.Links = append(feed.Links, atomLink{Href: f.FeedURL, Rel: "self"})
+	}
+	for i := range f.Items {
+		item := &f.Items[i]
+		entry := atomEntry{
+			Title:   item.Title,
+			ID:      item.id(),
+			Updated: atomTime(item.updated()),
+			Author:  atomAuthor(item.Author),
+		}
+		if !item.Published.IsZero() {
+			entry.Published = atomTime(item.Published)
+		}
+		if item.Link != "" {
+			entry.Links = []atomLink{{Href: item.Link, Rel: "alternate"}}
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Body: item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: item.Content}
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshal(feed)
+}
+
+func atomAuthor(author *Author) *atomPerson {
+	if author == nil {
+		return nil
+	}
+	return &atomPerson{Name: author.Name, Email: author.Email, URI: author.URI}
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		// updated是必填元素
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          *rssLink  `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title,omitempty"`
+	Link        string   `xml:"link,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category"`
+	GUID        *rssGUID `xml:"guid,omitempty"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+}
+
+// RSS encodes f as an RSS 2.0 document.
+func (f *Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+		Language:    f.Language,
+	}
+	if updated := f.updated(); !updated.IsZero() {
+		channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	if f.FeedURL != "" {
+		channel.Self = &rssLink{Href: f.FeedURL, Rel: "self", Type: RSSType}
+	}
+	for i := range f.Items {
+		item := &f.Items[i]
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Content,
+			Categories:  item.Categories,
+		}
+		if entry.Description == "" {
+			entry.Description = item.Summary
+		}
+		if id := item.id(); id != "" {
+			entry.GUID = &rssGUID{IsPermaLink: item.ID == "" || item.ID == item.Link, Value: id}
+		}
+		// RSS的author必须是邮箱地址，可附带名字
+		if item.Author != nil && item.Author.Email != "" {
+			entry.Author = item.Author.Email
+			if item.Author.Name != "" {
+				entry.Author += " (" + item.Author.Name + ")"
+			}
+		}
+		if !item.Published.IsZero() {
+			entry.PubDate = item.Published.UTC().Format(time.RFC1123Z)
+		}
+		channel.Items = append(channel.Items, entry)
+	}
+	return marshal(rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel})
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
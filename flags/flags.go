@@ -0,0 +1,90 @@
+package flags
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"net/http"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// Provider evaluates a flag for a subject key (user or tenant id, may be
+// empty).
+type Provider interface {
+	Enabled(ctx context.Context, flag, key string) (bool, error)
+}
+
+// Rule is a flag definition used by the built-in providers.
+type Rule struct {
+	Enabled bool `json:"enabled"`
+	// 按key灰度的百分比，0-100，为0时对所有key开启
+	Percent float64 `json:"percent,omitempty"`
+	// 无论灰度比例都开启的key
+	Allow []string `json:"allow,omitempty"`
+}
+
+// Evaluate reports whether the rule is on for key. Percentage rollouts hash
+// the flag name with the key, so a key keeps its assignment as the
+// percentage grows and different flags sample different keys.
+func (rule Rule) Evaluate(flag, key string) bool {
+	if !rule.Enabled {
+		return false
+	}
+	if rule.Percent <= 0 || rule.Percent >= 100 {
+		return true
+	}
+	for _, allowed := range rule.Allow {
+		if allowed == key {
+			return true
+		}
+	}
+	if key == "" {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(flag + ":" + key))
+	return float64(h.Sum32()%10000) < rule.Percent*100
+}
+
+type Flags struct {
+	provider Provider
+	// keyFunc 返回灰度使用的用户或租户标识
+	keyFunc func(r *http.Request) string
+}
+
+// New returns flags evaluated by provider. keyFunc returns the user or
+// tenant the rollout is keyed by; nil means no key.
+func New(provider Provider, keyFunc func(r *http.Request) string) *Flags {
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) string { return "" }
+	}
+	return &Flags{provider: provider, keyFunc: keyFunc}
+}
+
+// Enabled evaluates flag for the request. The result is memoized for the
+// rest of the request, and evaluation errors count as disabled.
+func (f *Flags) Enabled(r *http.Request, flag string) bool {
+	value, err := cyber.Memo(r, "flags:"+flag, func() (interface{}, error) {
+		return f.provider.Enabled(r.Context(), flag, f.keyFunc(r))
+	})
+	if err != nil {
+		log.Printf("Error evaluating flag %s: %v", flag, err)
+		return false
+	}
+	return value.(bool)
+}
+
+// Require gates a route behind flag, answering 404 while it is disabled so
+// unreleased endpoints stay invisible.
+func (f *Flags) Require(flag string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !f.Enabled(r, flag) {
+				http.NotFound(w, r)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build openfeature
+
+package flags
+
+import (
+	"context"
+
+	"github.com/open-feature/go-sdk/openfeature"
+)
+
+// OpenFeatureProvider evaluates flags with an OpenFeature client, passing
+// the key as the targeting key. Build with -tags openfeature.
+type OpenFeatureProvider struct {
+	Client *openfeature.Client
+}
+
+func (p OpenFeatureProvider) Enabled(ctx context.Context, flag, key string) (bool, error) {
+	return p.Client.BooleanValue(ctx, flag, false, openfeature.NewEvaluationContext(key, nil))
+}
@@ -0,0 +1,103 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// MemoryProvider holds rules in memory; Set can change them at runtime.
+type MemoryProvider struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+func NewMemoryProvider(rules map[string]Rule) *MemoryProvider {
+	p := &MemoryProvider{rules: make(map[string]Rule, len(rules))}
+	for name, rule := range rules {
+		p.rules[name] = rule
+	}
+	return p
+}
+
+func (p *MemoryProvider) Set(flag string, rule Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules[flag] = rule
+}
+
+func (p *MemoryProvider) Enabled(ctx context.Context, flag, key string) (bool, error) {
+	p.mu.RLock()
+	rule, ok := p.rules[flag]
+	p.mu.RUnlock()
+	return ok && rule.Evaluate(flag, key), nil
+}
+
+// FileProvider reads rules from a JSON object of flag name to Rule and
+// reloads the file when its modification time changes.
+type FileProvider struct {
+	path     string
+	interval time.Duration
+	mu       sync.Mutex
+	checked  time.Time
+	modTime  time.Time
+	memory   *MemoryProvider
+}
+
+// NewFileProvider loads path, checking it for changes at most once per
+// interval (default 5s).
+func NewFileProvider(path string, interval time.Duration) (*FileProvider, error) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	p := &FileProvider{path: path, interval: interval, memory: NewMemoryProvider(nil)}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload reads the file unconditionally.
+func (p *FileProvider) Reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	var rules map[string]Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	p.memory.mu.Lock()
+	p.memory.rules = rules
+	p.memory.mu.Unlock()
+	p.mu.Lock()
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *FileProvider) Enabled(ctx context.Context, flag, key string) (bool, error) {
+	p.mu.Lock()
+	stale := time.Since(p.checked) >= p.interval
+	if stale {
+		p.checked = time.Now()
+	}
+	modTime := p.modTime
+	p.mu.Unlock()
+	if stale {
+		// 重新加载失败时继续使用旧规则
+		if info, err := os.Stat(p.path); err == nil && !info.ModTime().Equal(modTime) {
+			if err := p.Reload(); err != nil {
+				log.Printf("Error reloading flags from %s: %v", p.path, err)
+			}
+		}
+	}
+	return p.memory.Enabled(ctx, flag, key)
+}
@@ -0,0 +1,191 @@
+package cyber
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/suonanjiexi/cyber/securecookie"
+)
+
+// FlashCookie is the cookie holding flash messages until they are shown.
+const FlashCookie = "_flash"
+
+// 浏览器通常只保存4KB以内的Cookie
+const maxFlashCookieSize = 4000
+
+// FlashMessage is a one-time message such as "Saved!", shown on the next
+// page the user sees.
+type FlashMessage struct {
+	// Category 例如 success、error，模板据此选择样式
+	Category string `json:"c"`
+	Message  string `json:"m"`
+}
+
+// flashState 是请求中的闪现消息：incoming是请求Cookie中的，outgoing是本次新增的
+type flashState struct {
+	loaded   bool
+	consumed bool
+	incoming []FlashMessage
+	outgoing []FlashMessage
+}
+
+// SetFlashCodec signs or encrypts the flash cookie with codec. Without it
+// messages are stored in plain base64; they are always escaped when shown.
+func (app *App) SetFlashCodec(codec *securecookie.Codec) {
+	app.flashCookies = codec
+}
+
+// Flash adds a message for the next page, typically before redirecting
+// after a POST (Post/Redirect/Get). Messages are kept in a cookie, so they
+// survive the redirect to any instance; unread ones accumulate.
+func Flash(w http.ResponseWriter, r *http.Request, category, message string) {
+	state := loadFlashes(r)
+	state.outgoing = append(state.outgoing, FlashMessage{Category: category, Message: message})
+	writeFlashes(w, r, state)
+}
+
+// Flashes returns the pending messages and clears them, so each one is
+// shown once. Later calls in the same request return the same messages.
+// Templates rendered with HTML can call {{flashes}} instead, see
+// App.LoadTemplates.
+func Flashes(w http.ResponseWriter, r *http.Request) []FlashMessage {
+	state := loadFlashes(r)
+	if !state.consumed {
+		state.consumed = true
+		writeFlashes(w, r, state)
+	}
+	return state.incoming
+}
+
+func loadFlashes(r *http.Request) *flashState {
+	state := stateFromRequest(r)
+	if state == nil {
+		flash := &flashState{}
+		flash.incoming = readFlashCookie(r, nil)
+		return flash
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.flash == nil {
+		state.flash = &flashState{}
+	}
+	if !state.flash.loaded {
+		state.flash.loaded = true
+		state.flash.incoming = readFlashCookie(r, state.app)
+	}
+	return state.flash
+}
+
+func flashCodec(app *App) *securecookie.Codec {
+	if app == nil {
+		return nil
+	}
+	return app.flashCookies
+}
+
+func readFlashCookie(r *http.Request, app *App) []FlashMessage {
+	var value string
+	if codec := flashCodec(app); codec != nil {
+		value, _ = codec.Cookie(r, FlashCookie)
+	} else if cookie, err := r.Cookie(FlashCookie); err == nil {
+		value = cookie.Value
+	}
+	if value == "" {
+		return nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	var messages []FlashMessage
+	if json.Unmarshal(data, &messages) != nil {
+		return nil
+	}
+	return messages
+}
+
+// writeFlashes 设置包含所有未读消息的Cookie，替换本次响应中已经设置的同名Cookie
+func writeFlashes(w http.ResponseWriter, r *http.Request, flash *flashState) {
+	pending := flash.outgoing
+	if !flash.consumed {
+		pending = append(append([]FlashMessage(nil), flash.incoming...), flash.outgoing...)
+	}
+	removeSetCookie(w.Header(), FlashCookie)
+	cookie := &http.Cookie{Name: FlashCookie, Path: "/", Secure: IsTLS(r), HttpOnly: true, SameSite: http.SameSiteLaxMode}
+	if len(pending) == 0 {
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+		return
+	}
+	data, _ := json.Marshal(pending)
+	cookie.Value = base64.RawURLEncoding.EncodeToString(data)
+	if len(cookie.Value) > maxFlashCookieSize {
+		log.Printf("Flash messages exceed %d bytes, browsers may drop the cookie", maxFlashCookieSize)
+	}
+	var app *App
+	if state := stateFromRequest(r); state != nil {
+		app = state.app
+	}
+	if codec := flashCodec(app); codec != nil {
+		if err := codec.SetCookie(w, cookie); err != nil {
+			log.Printf("Error setting flash cookie: %v", err)
+		}
+		return
+	}
+	http.SetCookie(w, cookie)
+}
+
+func removeSetCookie(header http.Header, name string) {
+	values := header.Values("Set-Cookie")
+	kept := values[:0:0]
+	for _, value := range values {
+		if !strings.HasPrefix(value, name+"=") {
+			kept = append(kept, value)
+		}
+	}
+	if len(kept) == len(values) {
+		return
+	}
+	header.Del("Set-Cookie")
+	for _, value := range kept {
+		header.Add("Set-Cookie", value)
+	}
+}
+
+var flashMarker = templateMarker("flashes")
+
+// flashFuncs 是LoadTemplates默认提供的模板函数
+func flashFuncs() template.FuncMap {
+	return template.FuncMap{
+		// {{flashes}} 输出待显示的消息并清除它们
+		"flashes": func() template.HTML {
+			return template.HTML(flashMarker)
+		},
+	}
+}
+
+// injectFlashes 把渲染结果中的占位符替换为消息，只有模板显示了消息时才清除它们
+func injectFlashes(w http.ResponseWriter, r *http.Request, body []byte) []byte {
+	if !bytes.Contains(body, []byte(flashMarker)) {
+		return body
+	}
+	return bytes.ReplaceAll(body, []byte(flashMarker), []byte(flashesHTML(Flashes(w, r))))
+}
+
+// flashesHTML 把消息渲染为 <div class="flash flash-success" role="alert">Saved!</div>
+func flashesHTML(messages []FlashMessage) string {
+	var b strings.Builder
+	for _, message := range messages {
+		b.WriteString(`<div class="flash flash-`)
+		b.WriteString(template.HTMLEscapeString(message.Category))
+		b.WriteString(`" role="alert">`)
+		b.WriteString(template.HTMLEscapeString(message.Message))
+		b.WriteString(`</div>`)
+	}
+	return b.String()
+}
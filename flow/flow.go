@@ -0,0 +1,290 @@
+// Package flow runs multi-step operations as sagas: each step may declare
+// a compensation, and when a step fails the completed steps are
+// compensated in reverse order. The state of every run is saved to a Store
+// after each step, so runs interrupted by a crash are resumed when the app
+// starts again.
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// Status is the state of a run.
+type Status string
+
+const (
+	Running      Status = "running"
+	Completed    Status = "completed"
+	Compensating Status = "compensating"
+	// Compensated 表示某一步失败，之前完成的步骤都已补偿
+	Compensated Status = "compensated"
+	// Failed 表示补偿本身失败，需要人工处理
+	Failed Status = "failed"
+)
+
+// Finished reports whether the run will not make progress any more.
+func (s Status) Finished() bool {
+	return s == Completed || s == Compensated || s == Failed
+}
+
+// Step is one step of a flow. Do and Compensate may run again after a
+// crash, so they must be idempotent.
+type Step struct {
+	Name       string
+	Do         func(ctx context.Context, run *Run) error
+	Compensate func(ctx context.Context, run *Run) error
+}
+
+// Run is the persisted state of one execution of a flow.
+type Run struct {
+	ID   string
+	Flow string
+	// Data 在步骤之间传递数据，例如订单号、支付流水号，每一步之后保存
+	Data   map[string]string
+	Status Status
+	// Step 是下一个要执行的步骤；补偿时是仍需补偿的已完成步骤数
+	Step    int
+	Error   string
+	Updated time.Time
+}
+
+// Flow is a named sequence of steps.
+type Flow struct {
+	Name  string
+	Steps []Step
+}
+
+// New declares a flow.
+func New(name string, steps ...Step) *Flow {
+	return &Flow{Name: name, Steps: steps}
+}
+
+var (
+	ErrUnknownFlow = errors.New("flow: unknown flow")
+	ErrRunning     = errors.New("flow: run already in progress")
+	ErrClosed      = errors.New("flow: engine shut down")
+)
+
+// Engine executes flows and persists their runs. Use NewEngine to create
+// one.
+type Engine struct {
+	store   Store
+	mu      sync.Mutex
+	flows   map[string]*Flow
+	running map[string]struct{}
+	// ctx 是执行所有运行的context，在应用关闭时取消，中断的运行在下次启动时恢复
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewEngine creates an Engine whose unfinished runs are resumed in the
+// background when app starts. Runs still executing at shutdown have their
+// context cancelled and are resumed on the next start.
+func NewEngine(app *cyber.App, store Store) *Engine {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{store: store, flows: make(map[string]*Flow), running: make(map[string]struct{}), ctx: ctx, cancel: cancel}
+	app.OnStart(func(ctx context.Context) error {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			if err := e.Resume(e.ctx); err != nil {
+				log.Printf("Flow resume error: %v", err)
+			}
+		}()
+		return nil
+	})
+	app.OnStop(e.Shutdown)
+	return e
+}
+
+// Register adds flows to the engine. Register every flow before the app
+// starts so interrupted runs can be resumed.
+func (e *Engine) Register(flows ...*Flow) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, f := range flows {
+		e.flows[f.Name] = f
+	}
+}
+
+// Start runs flow with the given run ID and data and returns the final
+// state. A step error is not returned: it shows up as a Compensated or
+// Failed run with the error recorded. The returned error is about the
+// engine itself, e.g. the store failing. The run executes on the engine's
+// context, not ctx: if ctx, e.g. the request's, is cancelled first, Start
+// returns ctx's error while the run goes on in the background until it
+// finishes or Shutdown interrupts it. Starting an ID that already exists
+// continues or returns that run instead, so retried requests don't run a
+// flow twice.
+func (e *Engine) Start(ctx context.Context, flow, id string, data map[string]string) (*Run, error) {
+	run, err := e.store.Load(ctx, id)
+	create := false
+	switch {
+	case err == nil:
+		if run.Status.Finished() {
+			return run, nil
+		}
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	default:
+		if data == nil {
+			data = make(map[string]string)
+		}
+		run, create = &Run{ID: id, Flow: flow, Data: data, Status: Running}, true
+	}
+	e.mu.Lock()
+	if e.ctx.Err() != nil {
+		e.mu.Unlock()
+		return nil, ErrClosed
+	}
+	e.wg.Add(1)
+	e.mu.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		defer e.wg.Done()
+		done <- e.execute(e.ctx, run, create)
+	}()
+	select {
+	case err := <-done:
+		return run, err
+	case <-ctx.Done():
+		// 运行仍在后台修改run，不返回给调用方
+		return nil, ctx.Err()
+	}
+}
+
+// Get returns the saved state of a run.
+func (e *Engine) Get(ctx context.Context, id string) (*Run, error) {
+	return e.store.Load(ctx, id)
+}
+
+// Resume continues every unfinished run in the store, one after another.
+func (e *Engine) Resume(ctx context.Context) error {
+	runs, err := e.store.Unfinished(ctx)
+	if err != nil {
+		return err
+	}
+	if len(runs) > 0 {
+		log.Printf("Resuming %d flow run(s)", len(runs))
+	}
+	for _, run := range runs {
+		if err := e.execute(ctx, run, false); err != nil && !errors.Is(err, ErrRunning) {
+			log.Printf("Flow %s run %s: %v", run.Flow, run.ID, err)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) execute(ctx context.Context, run *Run, create bool) error {
+	e.mu.Lock()
+	f, ok := e.flows[run.Flow]
+	if _, busy := e.running[run.ID]; busy {
+		e.mu.Unlock()
+		return ErrRunning
+	}
+	if ok {
+		e.running[run.ID] = struct{}{}
+	}
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownFlow, run.Flow)
+	}
+	defer func() {
+		e.mu.Lock()
+		delete(e.running, run.ID)
+		e.mu.Unlock()
+	}()
+	if create {
+		if err := e.save(ctx, run); err != nil {
+			return err
+		}
+	}
+	for run.Status == Running {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if run.Step >= len(f.Steps) {
+			run.Status = Completed
+			if err := e.save(ctx, run); err != nil {
+				return err
+			}
+			break
+		}
+		if err := f.Steps[run.Step].Do(ctx, run); err != nil {
+			if ctx.Err() != nil {
+				// 关闭导致的中断不是步骤失败，下次启动时重新执行这一步
+				return ctx.Err()
+			}
+			log.Printf("Flow %s run %s: step %s failed: %v", run.Flow, run.ID, f.Steps[run.Step].Name, err)
+			run.Status, run.Error = Compensating, fmt.Sprintf("%s: %v", f.Steps[run.Step].Name, err)
+		} else {
+			run.Step++
+		}
+		if run.Status == Running && run.Step == len(f.Steps) {
+			run.Status = Completed
+		}
+		if err := e.save(ctx, run); err != nil {
+			return err
+		}
+	}
+	for run.Status == Compensating {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if run.Step == 0 {
+			run.Status = Compensated
+		} else if step := f.Steps[run.Step-1]; step.Compensate == nil {
+			run.Step--
+		} else if err := step.Compensate(ctx, run); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Flow %s run %s: compensating %s failed: %v", run.Flow, run.ID, step.Name, err)
+			run.Status, run.Error = Failed, fmt.Sprintf("%s; compensate %s: %v", run.Error, step.Name, err)
+		} else {
+			run.Step--
+		}
+		if err := e.save(ctx, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Engine) save(ctx context.Context, run *Run) error {
+	run.Updated = time.Now()
+	if err := e.store.Save(ctx, run); err != nil {
+		return fmt.Errorf("flow: save run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+// Shutdown cancels the runs in progress, including those started by
+// Start, and waits for them and the resume started by the app to return.
+// Start fails with ErrClosed afterwards. It is registered as a stop hook by
+// NewEngine.
+func (e *Engine) Shutdown(ctx context.Context) error {
+	// 与Start中的wg.Add互斥，取消后不再有新的运行加入等待
+	e.mu.Lock()
+	e.cancel()
+	e.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,201 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Store.Load for an unknown run.
+var ErrNotFound = errors.New("flow: run not found")
+
+// Store persists runs. Save is called after every step.
+type Store interface {
+	Save(ctx context.Context, run *Run) error
+	Load(ctx context.Context, id string) (*Run, error)
+	// Unfinished 返回所有未结束的运行，按更新时间从旧到新
+	Unfinished(ctx context.Context) ([]*Run, error)
+}
+
+// MemoryStore keeps runs in process, for development and tests. Runs
+// don't survive a restart.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	runs map[string]*Run
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{runs: make(map[string]*Run)}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.ID] = copyRun(run)
+	return nil
+}
+
+func (s *MemoryStore) Load(ctx context.Context, id string) (*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyRun(run), nil
+}
+
+func (s *MemoryStore) Unfinished(ctx context.Context) ([]*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var runs []*Run
+	for _, run := range s.runs {
+		if !run.Status.Finished() {
+			runs = append(runs, copyRun(run))
+		}
+	}
+	sortByUpdated(runs)
+	return runs, nil
+}
+
+// copyRun 复制运行状态，避免存储中的数据被步骤修改
+func copyRun(run *Run) *Run {
+	c := *run
+	c.Data = make(map[string]string, len(run.Data))
+	for key, value := range run.Data {
+		c.Data[key] = value
+	}
+	return &c
+}
+
+func sortByUpdated(runs []*Run) {
+	for i := 1; i < len(runs); i++ {
+		for j := i; j > 0 && runs[j].Updated.Before(runs[j-1].Updated); j-- {
+			runs[j], runs[j-1] = runs[j-1], runs[j]
+		}
+	}
+}
+
+type SQLConfig struct {
+	// 表名，默认 cyber_flow_runs
+	Table string
+	// 建表语句，默认按sqlite编写；其他数据库需要提供等价的语句，%s 会替换为表名
+	Schema string
+	// Placeholder 返回第n个参数（从1开始）的占位符，默认 "?"；PostgreSQL 使用 $n
+	Placeholder func(n int) string
+	// Upsert 是保存运行的语句，默认使用 INSERT ... ON CONFLICT (sqlite、PostgreSQL)；MySQL需要改为 ON DUPLICATE KEY UPDATE
+	Upsert string
+}
+
+const defaultRunSchema = `CREATE TABLE IF NOT EXISTS %s (
+	id         TEXT PRIMARY KEY,
+	flow       TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	step       INTEGER NOT NULL,
+	error      TEXT NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+)`
+
+// SQLStore persists runs in a database table.
+type SQLStore struct {
+	db     *sql.DB
+	config SQLConfig
+}
+
+func NewSQLStore(database *sql.DB, config SQLConfig) *SQLStore {
+	if config.Table == "" {
+		config.Table = "cyber_flow_runs"
+	}
+	if config.Schema == "" {
+		config.Schema = defaultRunSchema
+	}
+	if config.Placeholder == nil {
+		config.Placeholder = func(int) string { return "?" }
+	}
+	if config.Upsert == "" {
+		p := make([]string, 7)
+		for i := range p {
+			p[i] = config.Placeholder(i + 1)
+		}
+		config.Upsert = fmt.Sprintf(`INSERT INTO %s (id, flow, data, status, step, error, updated_at) VALUES (%s)
+ON CONFLICT (id) DO UPDATE SET data = excluded.data, status = excluded.status, step = excluded.step,
+error = excluded.error, updated_at = excluded.updated_at`, config.Table, strings.Join(p, ", "))
+	}
+	return &SQLStore{db: database, config: config}
+}
+
+// Migrate creates the runs table if it doesn't exist.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(s.config.Schema, s.config.Table))
+	return err
+}
+
+func (s *SQLStore) Save(ctx context.Context, run *Run) error {
+	data, err := json.Marshal(run.Data)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.config.Upsert,
+		run.ID, run.Flow, string(data), string(run.Status), run.Step, run.Error, run.Updated)
+	return err
+}
+
+const runColumns = `id, flow, data, status, step, error, updated_at`
+
+func (s *SQLStore) Load(ctx context.Context, id string) (*Run, error) {
+	rows, err := s.db.QueryContext(ctx,
+		fmt.Sprintf(`SELECT %s FROM %s WHERE id = %s`, runColumns, s.config.Table, s.config.Placeholder(1)), id)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := scanRuns(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, ErrNotFound
+	}
+	return runs[0], nil
+}
+
+func (s *SQLStore) Unfinished(ctx context.Context) ([]*Run, error) {
+	rows, err := s.db.QueryContext(ctx,
+		fmt.Sprintf(`SELECT %s FROM %s WHERE status IN (%s, %s) ORDER BY updated_at`,
+			runColumns, s.config.Table, s.config.Placeholder(1), s.config.Placeholder(2)),
+		string(Running), string(Compensating))
+	if err != nil {
+		return nil, err
+	}
+	return scanRuns(rows)
+}
+
+func scanRuns(rows *sql.Rows) ([]*Run, error) {
+	defer rows.Close()
+	var runs []*Run
+	for rows.Next() {
+		run := &Run{}
+		var data, status string
+		var updated time.Time
+		if err := rows.Scan(&run.ID, &run.Flow, &data, &status, &run.Step, &run.Error, &updated); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &run.Data); err != nil {
+			return nil, fmt.Errorf("flow: run %s: %w", run.ID, err)
+		}
+		run.Status, run.Updated = Status(status), updated
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Dollar is the PostgreSQL placeholder style for SQLConfig.Placeholder.
+func Dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
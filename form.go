@@ -0,0 +1,142 @@
+package cyber
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 解析multipart表单时保存在内存中的最大字节数，超过的部分写入临时文件
+const formMaxMemory = 32 << 20
+
+// FieldErrors maps form field names to messages. A Validator may return it
+// to report errors next to the fields; the "" key holds errors that
+// concern the whole form.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		if name == "" {
+			parts[i] = e[name]
+		} else {
+			parts[i] = name + ": " + e[name]
+		}
+	}
+	return "cyber: invalid form: " + strings.Join(parts, "; ")
+}
+
+// Form is a submitted HTML form: the values as sent and the errors found
+// binding and validating them. Pass it to the template re-rendering the
+// form, which shows the previous input and the errors:
+//
+//	<input name="email" value="{{.Value "email"}}">
+//	{{with .Error "email"}}<p class="error">{{.}}</p>{{end}}
+type Form struct {
+	Values url.Values
+	Errors FieldErrors
+	// Data 是页面需要的其他数据
+	Data interface{}
+}
+
+// Value returns the submitted value of the field.
+func (f *Form) Value(name string) string {
+	return f.Values.Get(name)
+}
+
+// Error returns the error message of the field, or "" if it's valid.
+func (f *Form) Error(name string) string {
+	return f.Errors[name]
+}
+
+// Valid reports whether the form has no errors.
+func (f *Form) Valid() bool {
+	return len(f.Errors) == 0
+}
+
+// Render re-renders the named template with the form and status 422.
+func (f *Form) Render(w http.ResponseWriter, r *http.Request, name string) {
+	HTML(w, r, http.StatusUnprocessableEntity, name, f)
+}
+
+// BindForm sets the fields of the struct v points to from a POSTed form,
+// urlencoded or multipart, named by their form tag, e.g. `form:"email"`.
+// Values are converted as in BindURI and v is validated when it implements
+// Validator. Unlike the other binders it doesn't stop at the first error:
+// every invalid field is reported in the returned Form, and the error is
+// its FieldErrors. A malformed request body returns a nil Form.
+//
+//	var req SignupForm
+//	if form, err := cyber.BindForm(r, &req); err != nil {
+//		if form == nil {
+//			cyber.Abort(w, r, err)
+//			return
+//		}
+//		form.Render(w, r, "signup.html")
+//		return
+//	}
+func BindForm(r *http.Request, v interface{}) (*Form, error) {
+	var err error
+	if ContentType(r) == "multipart/form-data" {
+		err = r.ParseMultipartForm(formMaxMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return nil, NewHTTPError(http.StatusBadRequest, "invalid_form", err.Error())
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cyber: bind form: expected a pointer to a struct, got %T", v)
+	}
+	form := &Form{Values: r.PostForm, Errors: FieldErrors{}}
+	recorder, _ := v.(presenceRecorder)
+	rv = rv.Elem()
+	for _, field := range boundFields(rv.Type(), "form") {
+		values := r.PostForm[field.name]
+		if len(values) == 0 || values[0] == "" {
+			switch {
+			case field.required:
+				form.Errors[field.name] = ErrRequired.Error()
+			case field.hasDefault:
+				if err := setField(rv.FieldByIndex(field.index), []string{field.def}); err != nil {
+					return nil, &BindError{Source: "form", Name: field.name, Value: field.def, Err: fmt.Errorf("default: %w", err)}
+				}
+			}
+			continue
+		}
+		if err := setField(rv.FieldByIndex(field.index), values); err != nil {
+			form.Errors[field.name] = "invalid value"
+			continue
+		}
+		if recorder != nil {
+			recorder.markPresent(field.field)
+		}
+	}
+	// 绑定出错时结构体不完整，不再校验
+	if form.Valid() {
+		if err := validate(v); err != nil {
+			var fieldErrors FieldErrors
+			if errors.As(err, &fieldErrors) {
+				for name, message := range fieldErrors {
+					form.Errors[name] = message
+				}
+			} else {
+				form.Errors[""] = err.Error()
+			}
+		}
+	}
+	if !form.Valid() {
+		return form, form.Errors
+	}
+	return form, nil
+}
@@ -0,0 +1,218 @@
+package cyber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// requestGoroutine 是GoExpect在调试模式下为请求启动的goroutine
+type requestGoroutine struct {
+	// site 是调用GoExpect的位置
+	site string
+	// within 是请求结束后goroutine应当结束的时间，超过后报告为可能的泄漏
+	within time.Duration
+	// id 是goroutine的ID，启动后设置，用于在报告泄漏时找到它的调用栈
+	id   atomic.Value
+	done atomic.Bool
+}
+
+// backgroundTasks 记录Go启动的goroutine，关闭应用时取消并等待它们
+type backgroundTasks struct {
+	once    sync.Once
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running atomic.Int64
+}
+
+func (b *backgroundTasks) context() context.Context {
+	b.once.Do(func() { b.ctx, b.cancel = context.WithCancel(context.Background()) })
+	return b.ctx
+}
+
+// Go runs fn in a new goroutine for work that continues after the
+// response, e.g. sending an email. Its context keeps the request's values,
+// such as the request ID and trace, but not its deadline or cancellation:
+// it is cancelled when the app shuts down, and Shutdown waits for fn to
+// return. A panic in fn is logged instead of crashing the process. fn may
+// run as long as the app; use GoExpect for work that should end soon after
+// the request.
+func Go(r *http.Request, fn func(ctx context.Context)) {
+	goRequest(r, callerSite(2), 0, fn)
+}
+
+// GoExpect is Go for work expected to finish within the given time after
+// the request ends. In debug mode, a goroutine still running after that is
+// logged with its stack as a possible leak or deadlock.
+func GoExpect(r *http.Request, within time.Duration, fn func(ctx context.Context)) {
+	goRequest(r, callerSite(2), within, fn)
+}
+
+// goRequest 启动请求的后台goroutine，within大于0时在调试模式下检查泄漏
+func goRequest(r *http.Request, site string, within time.Duration, fn func(ctx context.Context)) {
+	values := context.WithoutCancel(r.Context())
+	state := stateFromRequest(r)
+	if state == nil || state.app == nil {
+		go runBackground(values, site, fn)
+		return
+	}
+	app := state.app
+	ctx, cancel := context.WithCancel(values)
+	stop := context.AfterFunc(app.background.context(), cancel)
+	app.background.running.Add(1)
+	var g *requestGoroutine
+	if within > 0 && app.IsDebug() {
+		g = &requestGoroutine{site: site, within: within}
+		state.mu.Lock()
+		state.goroutines = append(state.goroutines, g)
+		state.mu.Unlock()
+	}
+	go func() {
+		defer app.background.running.Add(-1)
+		defer cancel()
+		defer stop()
+		if g != nil {
+			defer g.done.Store(true)
+			g.id.Store(goroutineID())
+		}
+		runBackground(ctx, site, fn)
+	}()
+}
+
+// Fork returns a copy of r for handling the same request a second time,
+// e.g. as a shadow of the real one. Its context is ctx with a fresh request
+// state for the same app and route, so Abort, Memo, timings and flash
+// messages of the fork don't leak into r or the other way round.
+func Fork(ctx context.Context, r *http.Request) *http.Request {
+	if state := stateFromRequest(r); state != nil {
+		state.mu.Lock()
+		app, route := state.app, state.route
+		state.mu.Unlock()
+		ctx = context.WithValue(ctx, requestStateKey{}, &requestState{app: app, route: route})
+	}
+	return r.Clone(ctx)
+}
+
+// Copy returns a snapshot of r that stays valid after the response is
+// sent, for passing to goroutines or jobs. Headers, URL, form values and
+// path values are deep copies; the body, which the server closes with the
+// response, is replaced by http.NoBody and uploaded files are dropped. Its
+// context keeps the request's values without its cancellation. Treat the
+// copy as read-only.
+func Copy(r *http.Request) *http.Request {
+	c := r.Clone(context.WithoutCancel(r.Context()))
+	c.Body = http.NoBody
+	c.GetBody = nil
+	if r.MultipartForm != nil {
+		form := &multipart.Form{Value: make(map[string][]string, len(r.MultipartForm.Value))}
+		for key, values := range r.MultipartForm.Value {
+			form.Value[key] = append([]string(nil), values...)
+		}
+		c.MultipartForm = form
+	}
+	return c
+}
+
+func runBackground(ctx context.Context, site string, fn func(ctx context.Context)) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Panic occurred in goroutine started at %s: %v\n%s", site, err, debug.Stack())
+		}
+	}()
+	fn(ctx)
+}
+
+// 等待后台goroutine结束时检查的间隔
+const backgroundPollInterval = 50 * time.Millisecond
+
+// stopBackground 取消Go启动的goroutine并等待它们结束或ctx到期
+func (app *App) stopBackground(ctx context.Context) error {
+	app.background.context()
+	app.background.cancel()
+	ticker := time.NewTicker(backgroundPollInterval)
+	defer ticker.Stop()
+	for {
+		running := app.background.running.Load()
+		if running == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("Shutdown stopped with %d background goroutine(s) running", running)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// finishGoroutines 在请求结束时调用，稍后检查它通过GoExpect启动的goroutine
+func (state *requestState) finishGoroutines() {
+	state.mu.Lock()
+	goroutines, route := state.goroutines, state.route
+	state.mu.Unlock()
+	if len(goroutines) == 0 {
+		return
+	}
+	label := "request"
+	if route != nil {
+		label = route.Key()
+	}
+	for _, g := range goroutines {
+		time.AfterFunc(g.within, func() {
+			if g.done.Load() {
+				return
+			}
+			id, _ := g.id.Load().(string)
+			state.app.debugf("Goroutine started at %s by %s still running %s after the request, possible leak:\n%s",
+				g.site, label, g.within, goroutineStack(id))
+		})
+	}
+}
+
+func callerSite(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			file = file[j+1:]
+		}
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// goroutineID 从当前goroutine调用栈的第一行 "goroutine 123 [running]:" 解析ID
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = bytes.TrimPrefix(buf[:runtime.Stack(buf, false)], []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		return string(buf[:i])
+	}
+	return ""
+}
+
+// goroutineStack 返回指定goroutine的调用栈
+func goroutineStack(id string) string {
+	if id == "" {
+		return "(stack unavailable)"
+	}
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	header := []byte("goroutine " + id + " ")
+	for _, block := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(block, header) {
+			return string(block)
+		}
+	}
+	return "(stack unavailable)"
+}
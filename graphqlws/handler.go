@@ -0,0 +1,285 @@
+//go:build websocket
+
+package graphqlws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/suonanjiexi/cyber"
+)
+
+// 写消息的超时时间
+const writeTimeout = 10 * time.Second
+
+// Handler upgrades the request to a graphql-transport-ws connection. The
+// connection is tracked by the app and closed with a going-away frame on
+// Shutdown. Build with -tags websocket.
+func Handler(config Config) http.HandlerFunc {
+	if config.InitTimeout <= 0 {
+		config.InitTimeout = defaultConfig.InitTimeout
+	}
+	if config.KeepAlive == 0 {
+		config.KeepAlive = defaultConfig.KeepAlive
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: config.CheckOrigin, Subprotocols: []string{Subprotocol}}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade已经写出错误响应
+			return
+		}
+		defer ws.Close()
+		c := &conn{ws: ws, config: config, r: r, subs: make(map[string]context.CancelFunc)}
+		if ws.Subprotocol() != Subprotocol {
+			c.close(websocket.CloseProtocolError, "unsupported subprotocol")
+			return
+		}
+		done := cyber.TrackStream(r, func(reason string) {
+			c.close(websocket.CloseGoingAway, reason)
+		})
+		defer done()
+		c.serve()
+	}
+}
+
+// conn 是一个graphql-ws连接
+type conn struct {
+	ws     *websocket.Conn
+	config Config
+	r      *http.Request
+	// writeMu 保证同一时间只有一个写操作
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	ctx     context.Context
+	acked   bool
+	subs    map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (c *conn) serve() {
+	// 请求context带有根据WriteTimeout设置的截止时间，订阅不能随之结束；
+	// 保留其中的值，连接关闭时取消
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.r.Context()))
+	defer func() {
+		cancel()
+		c.wg.Wait()
+	}()
+	c.ctx = ctx
+	initTimer := time.AfterFunc(c.config.InitTimeout, func() {
+		c.mu.Lock()
+		acked := c.acked
+		c.mu.Unlock()
+		if !acked {
+			c.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+	if c.config.KeepAlive > 0 {
+		go c.keepAlive(ctx)
+	}
+	for {
+		var msg message
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			if isJSONError(err) {
+				c.close(closeBadRequest, "Invalid message received")
+			}
+			return
+		}
+		if !c.handle(msg) {
+			return
+		}
+	}
+}
+
+// handle 处理一条客户端消息，返回false时连接已关闭
+func (c *conn) handle(msg message) bool {
+	switch msg.Type {
+	case typeConnectionInit:
+		return c.init(msg)
+	case typePing:
+		c.send(message{Type: typePong})
+	case typePong:
+	case typeSubscribe:
+		return c.subscribe(msg)
+	case typeComplete:
+		c.mu.Lock()
+		cancel := c.subs[msg.ID]
+		delete(c.subs, msg.ID)
+		c.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	default:
+		c.close(closeBadRequest, fmt.Sprintf("Invalid message type %q", msg.Type))
+		return false
+	}
+	return true
+}
+
+func (c *conn) init(msg message) bool {
+	c.mu.Lock()
+	acked := c.acked
+	c.mu.Unlock()
+	if acked {
+		c.close(closeTooManyInitialise, "Too many initialisation requests")
+		return false
+	}
+	var payload map[string]interface{}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			c.close(closeBadRequest, "Invalid connection_init payload")
+			return false
+		}
+	}
+	ctx := c.ctx
+	if c.config.OnInit != nil {
+		initCtx, err := c.config.OnInit(c.r, payload)
+		if err != nil {
+			c.close(closeForbidden, "Forbidden")
+			return false
+		}
+		if initCtx != nil {
+			// 连接关闭时订阅也要结束，所以只取OnInit返回的值，不取它的取消信号
+			ctx = valueContext{Context: c.ctx, values: initCtx}
+		}
+	}
+	c.mu.Lock()
+	c.ctx, c.acked = ctx, true
+	c.mu.Unlock()
+	c.send(message{Type: typeConnectionAck})
+	return true
+}
+
+func (c *conn) subscribe(msg message) bool {
+	c.mu.Lock()
+	acked, parent := c.acked, c.ctx
+	_, duplicate := c.subs[msg.ID]
+	c.mu.Unlock()
+	if !acked {
+		c.close(closeUnauthorized, "Unauthorized")
+		return false
+	}
+	if msg.ID == "" {
+		c.close(closeBadRequest, "Subscribe message without id")
+		return false
+	}
+	if duplicate {
+		c.close(closeDuplicateID, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+		return false
+	}
+	var req Request
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || req.Query == "" {
+		c.close(closeBadRequest, "Invalid subscribe payload")
+		return false
+	}
+	ctx, cancel := context.WithCancel(parent)
+	c.mu.Lock()
+	c.subs[msg.ID] = cancel
+	c.mu.Unlock()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer cancel()
+		c.run(ctx, msg.ID, &req)
+	}()
+	return true
+}
+
+// run 执行订阅并转发结果，订阅正常结束时发送complete
+func (c *conn) run(ctx context.Context, id string, req *Request) {
+	results, err := c.config.Subscribe(ctx, req)
+	if err != nil {
+		if c.finish(id) {
+			payload, _ := json.Marshal([]Error{{Message: err.Error()}})
+			c.send(message{ID: id, Type: typeError, Payload: payload})
+		}
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// 客户端取消订阅或连接关闭，不再发送complete
+			return
+		case result, ok := <-results:
+			if !ok {
+				if c.finish(id) {
+					c.send(message{ID: id, Type: typeComplete})
+				}
+				return
+			}
+			payload, err := json.Marshal(result)
+			if err != nil {
+				payload, _ = json.Marshal(Result{Errors: []Error{{Message: err.Error()}}})
+			}
+			c.send(message{ID: id, Type: typeNext, Payload: payload})
+		}
+	}
+}
+
+// finish 移除订阅，返回false表示客户端已经取消了它
+func (c *conn) finish(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.subs[id]; !ok {
+		return false
+	}
+	delete(c.subs, id)
+	return true
+}
+
+func (c *conn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(c.config.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.send(message{Type: typePing}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) send(msg message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.ws.WriteJSON(msg)
+}
+
+// close 发送关闭帧，读循环随后结束
+func (c *conn) close(code int, reason string) {
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+	if code != websocket.CloseGoingAway {
+		c.ws.Close()
+	}
+}
+
+// isJSONError 判断读取错误是否是消息格式错误，其他错误表示连接已断开
+func isJSONError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
+
+// valueContext 使用values中的值，取消信号和截止时间来自内嵌的Context
+type valueContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valueContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
@@ -0,0 +1,86 @@
+// Package graphqlws serves GraphQL subscriptions over WebSocket with the
+// graphql-transport-ws protocol used by the graphql-ws client. It is
+// independent of the GraphQL library: Config.Subscribe executes the
+// operation and streams its results. The handler is built with the
+// websocket tag; the protocol types are always available.
+package graphqlws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Subprotocol is the WebSocket subprotocol negotiated with clients.
+const Subprotocol = "graphql-transport-ws"
+
+// 协议中的消息类型
+const (
+	typeConnectionInit = "connection_init"
+	typeConnectionAck  = "connection_ack"
+	typePing           = "ping"
+	typePong           = "pong"
+	typeSubscribe      = "subscribe"
+	typeNext           = "next"
+	typeError          = "error"
+	typeComplete       = "complete"
+)
+
+// 协议规定的关闭码
+const (
+	closeBadRequest        = 4400
+	closeUnauthorized      = 4401
+	closeForbidden         = 4403
+	closeInitTimeout       = 4408
+	closeDuplicateID       = 4409
+	closeTooManyInitialise = 4429
+)
+
+// message 是客户端与服务器之间交换的消息
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Request is the payload of a subscribe message.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Result is one execution result sent in a next message.
+type Result struct {
+	Data       interface{}            `json:"data,omitempty"`
+	Errors     []Error                `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Error is a GraphQL error.
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type Config struct {
+	// OnInit 校验connection_init的payload，例如其中的JWT；r.Context()中有HTTP认证中间件写入的信息。
+	// 返回的context用于该连接的所有订阅，返回错误时以4403关闭连接。为空时接受所有连接
+	OnInit func(r *http.Request, payload map[string]interface{}) (context.Context, error)
+	// Subscribe 执行订阅操作，返回的channel关闭表示订阅结束；ctx在客户端取消订阅或连接关闭时取消
+	Subscribe func(ctx context.Context, req *Request) (<-chan *Result, error)
+	// 等待connection_init的时间，超时以4408关闭连接，默认10秒
+	InitTimeout time.Duration
+	// 服务器发送ping的间隔，默认15秒，负数表示不发送
+	KeepAlive time.Duration
+	// CheckOrigin 校验Origin请求头，默认只允许同源
+	CheckOrigin func(r *http.Request) bool
+}
+
+var defaultConfig = Config{
+	InitTimeout: 10 * time.Second,
+	KeepAlive:   15 * time.Second,
+}
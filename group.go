@@ -0,0 +1,106 @@
+package cyber
+
+import (
+	"context"
+
+	"github.com/suonanjiexi/cyber/cache"
+	"github.com/suonanjiexi/cyber/ratelimit"
+)
+
+// 内置策略的名称
+const (
+	PolicyRateLimit = "ratelimit"
+	PolicyCache     = "cache"
+)
+
+// Group returns a child group under rg's prefix. The child inherits rg's
+// middlewares and policies.
+func (rg *RouteGroup) Group(prefix string) *RouteGroup {
+	return rg.app.addGroup(&RouteGroup{prefix: rg.joinPattern(prefix), app: rg.app, parent: rg})
+}
+
+// Use adds middlewares to routes registered on the group and its children
+// afterwards.
+func (rg *RouteGroup) Use(middlewares ...Middleware) *RouteGroup {
+	rg.app.mu.Lock()
+	defer rg.app.mu.Unlock()
+	rg.middlewares = append(rg.middlewares, middlewares...)
+	return rg
+}
+
+// WithPolicy sets the named policy middleware for the group. A child group
+// setting the same name replaces the inherited one; a nil middleware
+// disables it for the child.
+func (rg *RouteGroup) WithPolicy(name string, middleware Middleware) *RouteGroup {
+	rg.app.mu.Lock()
+	defer rg.app.mu.Unlock()
+	if rg.policies == nil {
+		rg.policies = make(map[string]Middleware)
+	}
+	if _, ok := rg.policies[name]; !ok {
+		rg.policyNames = append(rg.policyNames, name)
+	}
+	rg.policies[name] = middleware
+	return rg
+}
+
+// WithoutPolicy disables an inherited policy for the group.
+func (rg *RouteGroup) WithoutPolicy(name string) *RouteGroup {
+	return rg.WithPolicy(name, nil)
+}
+
+// WithRateLimit limits the group's routes, sharing one limiter across them.
+func (rg *RouteGroup) WithRateLimit(config ratelimit.Config) *RouteGroup {
+	return rg.WithPolicy(PolicyRateLimit, ratelimit.Middleware(config))
+}
+
+// WithCache caches the group's GET responses in one shared store.
+func (rg *RouteGroup) WithCache(config cache.Config) *RouteGroup {
+	return rg.WithPolicy(PolicyCache, cache.Middleware(config))
+}
+
+// WarmCache requests paths through the app in process once it starts, so
+// routes behind cache middleware are populated before traffic arrives. Use
+// the returned Warmup's Check as a readiness check.
+func (app *App) WarmCache(paths []string, config cache.WarmConfig) *cache.Warmup {
+	warmup := cache.NewWarmup(paths, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	app.OnStart(func(context.Context) error {
+		warmup.Start(ctx, app.Handler())
+		return nil
+	})
+	app.OnStop(func(context.Context) error {
+		cancel()
+		return nil
+	})
+	return warmup
+}
+
+// chain 返回分组最终生效的中间件：先是继承后的策略（按首次声明的顺序），再是从根到当前分组的Use中间件。
+// 调用方需持有app.mu
+func (rg *RouteGroup) chain() []Middleware {
+	var groups []*RouteGroup
+	for g := rg; g != nil; g = g.parent {
+		groups = append([]*RouteGroup{g}, groups...)
+	}
+	var names []string
+	policies := make(map[string]Middleware)
+	for _, g := range groups {
+		for _, name := range g.policyNames {
+			if _, ok := policies[name]; !ok {
+				names = append(names, name)
+			}
+			policies[name] = g.policies[name]
+		}
+	}
+	var middlewares []Middleware
+	for _, name := range names {
+		if policies[name] != nil {
+			middlewares = append(middlewares, policies[name])
+		}
+	}
+	for _, g := range groups {
+		middlewares = append(middlewares, g.middlewares...)
+	}
+	return middlewares
+}
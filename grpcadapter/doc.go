@@ -0,0 +1,4 @@
+// Package grpcadapter converts between cyber middlewares and gRPC server
+// interceptors, so auth, logging, metrics and recovery are written once
+// for HTTP and gRPC. Build with -tags grpc.
+package grpcadapter
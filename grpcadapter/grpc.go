@@ -0,0 +1,238 @@
+//go:build grpc
+
+package grpcadapter
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/suonanjiexi/cyber"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor runs middlewares around each unary call. They see
+// a POST request whose path is the full method name and whose headers are
+// the incoming metadata; context values they add reach the gRPC handler.
+// The handler's error is written as the matching HTTP status, so logging
+// and metrics middlewares record it. A middleware that responds without
+// calling the next handler, e.g. with 401, fails the call with the
+// matching gRPC code.
+func UnaryServerInterceptor(middlewares ...cyber.Middleware) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		var err error
+		done := false
+		callErr := run(ctx, info.FullMethod, middlewares, func(w http.ResponseWriter, r *http.Request) {
+			resp, err = handler(r.Context(), req)
+			done = true
+			w.WriteHeader(HTTPStatus(status.Code(err)))
+		}, &done, &err)
+		return resp, callErr
+	}
+}
+
+// StreamServerInterceptor runs middlewares around each streaming call,
+// like UnaryServerInterceptor. The stream's context is replaced by the
+// one the middlewares pass on.
+func StreamServerInterceptor(middlewares ...cyber.Middleware) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		var err error
+		done := false
+		return run(ss.Context(), info.FullMethod, middlewares, func(w http.ResponseWriter, r *http.Request) {
+			err = handler(srv, &serverStream{ServerStream: ss, ctx: r.Context()})
+			done = true
+			w.WriteHeader(HTTPStatus(status.Code(err)))
+		}, &done, &err)
+	}
+}
+
+// run 以HTTP请求的形式执行中间件链，final返回前设置done和err
+func run(ctx context.Context, fullMethod string, middlewares []cyber.Middleware, final http.HandlerFunc, done *bool, err *error) error {
+	w := &statusWriter{header: make(http.Header)}
+	chain(final, middlewares)(w, newRequest(ctx, fullMethod))
+	if *done {
+		return *err
+	}
+	// 中间件没有调用处理函数就直接响应，或者处理函数panic后被恢复
+	message := strings.TrimSpace(w.body.String())
+	if message == "" {
+		message = http.StatusText(w.Status())
+	}
+	return status.Error(Code(w.Status()), message)
+}
+
+func chain(handler http.HandlerFunc, middlewares []cyber.Middleware) http.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// newRequest 根据gRPC调用构造中间件看到的HTTP请求
+func newRequest(ctx context.Context, fullMethod string) *http.Request {
+	r := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: fullMethod},
+		RequestURI: fullMethod,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if key == ":authority" && len(values) > 0 {
+				r.Host = values[0]
+			} else if !strings.HasPrefix(key, ":") {
+				r.Header[http.CanonicalHeaderKey(key)] = values
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r.WithContext(ctx)
+}
+
+// 记录中间件直接响应时的错误信息，超过的部分丢弃
+const maxMessageSize = 1 << 10
+
+// statusWriter 记录中间件写出的状态码和错误信息
+type statusWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *statusWriter) Header() http.Header { return w.header }
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if room := maxMessageSize - w.body.Len(); room > 0 {
+		if len(b) > room {
+			w.body.Write(b[:room])
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return len(b), nil
+}
+
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// UnaryMiddleware runs a unary server interceptor around HTTP handlers.
+// The interceptor receives r as the request, "METHOD /path" as the full
+// method and the request headers as incoming metadata. An error it returns
+// without calling the handler is written as the matching HTTP status.
+func UnaryMiddleware(interceptor grpc.UnaryServerInterceptor) cyber.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			md := make(metadata.MD, len(r.Header))
+			for key, values := range r.Header {
+				md[strings.ToLower(key)] = values
+			}
+			ctx := metadata.NewIncomingContext(r.Context(), md)
+			info := &grpc.UnaryServerInfo{FullMethod: r.Method + " " + r.URL.Path}
+			called := false
+			_, err := interceptor(ctx, r, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				next(w, r.WithContext(ctx))
+				return nil, nil
+			})
+			if err != nil && !called {
+				st := status.Convert(err)
+				http.Error(w, st.Message(), HTTPStatus(st.Code()))
+			}
+		}
+	}
+}
+
+// HTTPStatus maps a gRPC code to the HTTP status with the same meaning.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		// 499 是nginx约定的客户端关闭连接
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Code maps an HTTP status to the gRPC code with the same meaning.
+func Code(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	}
+	switch {
+	case statusCode < http.StatusBadRequest:
+		return codes.OK
+	case statusCode < http.StatusInternalServerError:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,217 @@
+package cyber
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ParseETags parses a list of entity tags as found in If-Match and
+// If-None-Match. Weak tags keep their W/ prefix; "*" is returned as is.
+func ParseETags(header string) []string {
+	var tags []string
+	for header != "" {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			break
+		}
+		if header[0] == '*' {
+			tags = append(tags, "*")
+			header = header[1:]
+			continue
+		}
+		start := 0
+		if strings.HasPrefix(header, "W/") {
+			start = 2
+		}
+		// 实体标签是带引号的字符串，其中可能包含逗号
+		if start >= len(header) || header[start] != '"' {
+			end := strings.IndexByte(header, ',')
+			if end < 0 {
+				end = len(header)
+			}
+			header = header[end:]
+			continue
+		}
+		end := strings.IndexByte(header[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		end += start + 2
+		tags = append(tags, header[:end])
+		header = header[end:]
+	}
+	return tags
+}
+
+// IfMatch reports whether the request's If-Match precondition holds for a
+// resource whose current entity tag is etag, e.g. `"v3"`. A request
+// without If-Match always matches; "*" matches any existing resource, so
+// pass an empty etag when the resource doesn't exist. Comparison is strong:
+// weak tags never match. Respond with 412 Precondition Failed when it
+// returns false.
+func IfMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, tag := range ParseETags(header) {
+		if tag == "*" {
+			return etag != ""
+		}
+		if !strings.HasPrefix(tag, "W/") && !strings.HasPrefix(etag, "W/") && tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// IfNoneMatch reports whether the request's If-None-Match header matches
+// etag, in which case a GET or HEAD should be answered with 304 Not
+// Modified. Comparison is weak: W/"x" matches "x".
+func IfNoneMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range ParseETags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireIfMatch guards a write against lost updates: it returns a 428
+// error when the request has no If-Match header and a 412 error when the
+// header doesn't match currentETag, the entity tag of the resource as
+// stored. Pass the result to Abort:
+//
+//	if err := cyber.RequireIfMatch(r, cyber.VersionETag(user.Version)); err != nil {
+//		cyber.Abort(w, r, err)
+//		return
+//	}
+func RequireIfMatch(r *http.Request, currentETag string) error {
+	if r.Header.Get("If-Match") == "" {
+		return NewHTTPError(http.StatusPreconditionRequired, "precondition_required", "If-Match header is required")
+	}
+	if !IfMatch(r, currentETag) {
+		return NewHTTPError(http.StatusPreconditionFailed, "precondition_failed", "Resource has been modified").
+			WithHeader("ETag", currentETag)
+	}
+	return nil
+}
+
+// ETagger is implemented by resources whose entity tag is known, usually
+// derived from a version field that changes on every write. Success sets
+// the ETag header of a 2xx response from it:
+//
+//	func (u User) ETag() string { return cyber.VersionETag(u.Version) }
+type ETagger interface {
+	ETag() string
+}
+
+// VersionETag formats a resource version, e.g. 3 or an update timestamp,
+// as a strong entity tag: "3".
+func VersionETag(version interface{}) string {
+	return strconv.Quote(fmt.Sprint(version))
+}
+
+// Authorization splits the Authorization header into its scheme, e.g.
+// "Bearer" or "Basic", and credentials. ok is false when the header is
+// missing or has no credentials.
+func Authorization(r *http.Request) (scheme, credentials string, ok bool) {
+	scheme, credentials, ok = strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	credentials = strings.TrimSpace(credentials)
+	if !ok || scheme == "" || credentials == "" {
+		return "", "", false
+	}
+	return scheme, credentials, true
+}
+
+// BearerToken returns the token of an Authorization header using the Bearer
+// scheme, which is matched case-insensitively.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := Authorization(r)
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return token, true
+}
+
+// ContentRange is a parsed Content-Range header, e.g. "bytes 0-499/1234".
+type ContentRange struct {
+	Unit string
+	// Start 和 End 都包含在范围内；"bytes */1234" 这类不可满足的范围两者都为-1
+	Start int64
+	End   int64
+	// Size 是完整内容的长度，"*" 表示未知，为-1
+	Size int64
+}
+
+// ErrInvalidContentRange is returned by ParseContentRange for a malformed
+// header.
+var ErrInvalidContentRange = errors.New("cyber: invalid Content-Range")
+
+// ParseContentRange parses a Content-Range header, as sent by clients
+// uploading a file in chunks or in a 206 response.
+func ParseContentRange(header string) (ContentRange, error) {
+	invalid := fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+	unit, rest, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || unit == "" {
+		return ContentRange{}, invalid
+	}
+	span, size, ok := strings.Cut(strings.TrimSpace(rest), "/")
+	if !ok {
+		return ContentRange{}, invalid
+	}
+	cr := ContentRange{Unit: unit, Start: -1, End: -1, Size: -1}
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			return ContentRange{}, invalid
+		}
+		cr.Size = n
+	}
+	if span == "*" {
+		// 不可满足的范围必须给出完整长度
+		if cr.Size < 0 {
+			return ContentRange{}, invalid
+		}
+		return cr, nil
+	}
+	first, last, ok := strings.Cut(span, "-")
+	if !ok {
+		return ContentRange{}, invalid
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start || (cr.Size >= 0 && end >= cr.Size) {
+		return ContentRange{}, invalid
+	}
+	cr.Start, cr.End = start, end
+	return cr, nil
+}
+
+// Length returns the number of units in the range, 0 when unsatisfied.
+func (cr ContentRange) Length() int64 {
+	if cr.Start < 0 {
+		return 0
+	}
+	return cr.End - cr.Start + 1
+}
+
+// String formats the range as a Content-Range header value.
+func (cr ContentRange) String() string {
+	size := "*"
+	if cr.Size >= 0 {
+		size = strconv.FormatInt(cr.Size, 10)
+	}
+	if cr.Start < 0 {
+		return fmt.Sprintf("%s */%s", cr.Unit, size)
+	}
+	return fmt.Sprintf("%s %d-%d/%s", cr.Unit, cr.Start, cr.End, size)
+}
@@ -1 +1,77 @@
 package cyber
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	redirectHostsMu sync.RWMutex
+	redirectHosts   = map[string]struct{}{}
+)
+
+// AllowRedirectHosts adds hosts that Redirect may send clients to with an
+// absolute URL. Everything else must be a relative path.
+func AllowRedirectHosts(hosts ...string) {
+	redirectHostsMu.Lock()
+	defer redirectHostsMu.Unlock()
+	for _, host := range hosts {
+		redirectHosts[strings.ToLower(host)] = struct{}{}
+	}
+}
+
+// IsSafeRedirect reports whether target is a relative path on this site or an
+// absolute http(s) URL whose host is allowlisted.
+func IsSafeRedirect(target string) bool {
+	if target == "" || strings.ContainsAny(target, "\\\r\n\t") {
+		return false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		// 拒绝 "//evil.com" 这类协议相对地址
+		return strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	redirectHostsMu.RLock()
+	defer redirectHostsMu.RUnlock()
+	_, ok := redirectHosts[strings.ToLower(u.Hostname())]
+	return ok
+}
+
+// Redirect redirects to target if it is safe, otherwise to "/".
+func Redirect(w http.ResponseWriter, r *http.Request, StatusCode int, target string) {
+	if !IsSafeRedirect(target) {
+		target = "/"
+	}
+	http.Redirect(w, r, target, StatusCode)
+}
+
+// RedirectBack redirects to the Referer when it points back to this host,
+// otherwise to fallback.
+func RedirectBack(w http.ResponseWriter, r *http.Request, fallback string) {
+	target := fallback
+	if referer, err := url.Parse(r.Referer()); err == nil && referer.Host != "" && strings.EqualFold(referer.Host, r.Host) {
+		target = referer.RequestURI()
+	}
+	Redirect(w, r, http.StatusFound, target)
+}
+
+// StatusClientClosedRequest is the nginx-style status recorded by logging
+// middleware for requests whose client went away before the response.
+const StatusClientClosedRequest = 499
+
+// IsClientGone reports whether the client closed the connection. The server
+// cancels the request context when it notices the disconnect, so handlers
+// doing long work should also select on r.Context().Done().
+func IsClientGone(r *http.Request) bool {
+	return errors.Is(context.Cause(r.Context()), context.Canceled)
+}
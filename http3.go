@@ -0,0 +1,59 @@
+//go:build http3
+
+package cyber
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// altSvcHandler 在TCP响应中通过Alt-Svc告知客户端可以改用HTTP/3
+type altSvcHandler struct {
+	next http.Handler
+	h3   *http3.Server
+}
+
+func (h altSvcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.h3.SetQUICHeaders(w.Header())
+	h.next.ServeHTTP(w, r)
+}
+
+func (h altSvcHandler) unwrap() http.Handler {
+	return h.next
+}
+
+// RunHTTP3 serves HTTPS over TCP and, experimentally, HTTP/3 over QUIC on
+// the same port. TCP responses advertise HTTP/3 with an Alt-Svc header, so
+// clients switch on their next request. Build with -tags http3.
+//
+// Shutdown drains the TCP server gracefully; the HTTP/3 server is closed
+// once it has, which aborts any QUIC requests still running.
+func (app *App) RunHTTP3(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", app.Server.Addr)
+	if err != nil {
+		return err
+	}
+	// UDP使用与TCP相同的端口，包括端口0时系统分配的端口
+	h3 := &http3.Server{Addr: ln.Addr().String(), Handler: app.Handler()}
+	app.Server.Handler = altSvcHandler{next: app.Handler(), h3: h3}
+	go func() {
+		if err := h3.ListenAndServeTLS(certFile, keyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP/3 server error: %v", err)
+		}
+	}()
+	defer h3.Close()
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	return app.Serve(tls.NewListener(ln, tlsConfig))
+}
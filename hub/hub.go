@@ -0,0 +1,159 @@
+// Package hub is an in-process publish/subscribe hub for realtime fan-out,
+// with SSE and WebSocket bridges and an optional Redis adapter (build tag
+// redis) to fan out across nodes.
+package hub
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Message is a message published to a topic.
+type Message struct {
+	Topic string
+	Data  []byte
+}
+
+// Adapter relays messages between nodes. Run delivers every message
+// published on any node, including this one, until ctx is cancelled.
+type Adapter interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	Run(ctx context.Context, deliver func(topic string, data []byte)) error
+}
+
+type Config struct {
+	// 每个订阅者的缓冲消息数，缓冲已满时丢弃发给该订阅者的消息
+	Buffer int
+	// Adapter 用于多节点广播，为空时只在本进程内分发
+	Adapter Adapter
+}
+
+const defaultBuffer = 64
+
+// 适配器断开后重连的间隔
+const adapterRetryInterval = time.Second
+
+// Hub routes published messages to the subscribers of their topic.
+type Hub struct {
+	config  Config
+	mu      sync.RWMutex
+	topics  map[string]map[*Subscription]struct{}
+	cancel  context.CancelFunc
+	dropped atomic.Uint64
+}
+
+// New creates a hub. With an adapter, it starts relaying in the background
+// until Close.
+func New(config Config) *Hub {
+	if config.Buffer <= 0 {
+		config.Buffer = defaultBuffer
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Hub{config: config, topics: make(map[string]map[*Subscription]struct{}), cancel: cancel}
+	if config.Adapter != nil {
+		go h.runAdapter(ctx)
+	}
+	return h
+}
+
+func (h *Hub) runAdapter(ctx context.Context) {
+	for {
+		err := h.config.Adapter.Run(ctx, h.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Hub adapter stopped, retrying: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(adapterRetryInterval):
+		}
+	}
+}
+
+// Close stops the adapter. Subscriptions stay open until closed.
+func (h *Hub) Close() error {
+	h.cancel()
+	return nil
+}
+
+// Subscription receives the messages of its topics on C.
+type Subscription struct {
+	C      <-chan Message
+	ch     chan Message
+	hub    *Hub
+	topics []string
+	once   sync.Once
+}
+
+// Subscribe subscribes to topics. Close the subscription when done.
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	ch := make(chan Message, h.config.Buffer)
+	sub := &Subscription{C: ch, ch: ch, hub: h, topics: topics}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		subs, ok := h.topics[topic]
+		if !ok {
+			subs = make(map[*Subscription]struct{})
+			h.topics[topic] = subs
+		}
+		subs[sub] = struct{}{}
+	}
+	return sub
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		h := s.hub
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, topic := range s.topics {
+			delete(h.topics[topic], s)
+			if len(h.topics[topic]) == 0 {
+				delete(h.topics, topic)
+			}
+		}
+		close(s.ch)
+	})
+}
+
+// Publish sends data to the subscribers of topic, on every node when the
+// hub has an adapter.
+func (h *Hub) Publish(ctx context.Context, topic string, data []byte) error {
+	if h.config.Adapter != nil {
+		return h.config.Adapter.Publish(ctx, topic, data)
+	}
+	h.deliver(topic, data)
+	return nil
+}
+
+// deliver 分发给本地订阅者，不阻塞发布者：订阅者的缓冲已满时丢弃该消息
+func (h *Hub) deliver(topic string, data []byte) {
+	msg := Message{Topic: topic, Data: data}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.topics[topic] {
+		select {
+		case sub.ch <- msg:
+		default:
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribers returns the number of local subscribers of topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Dropped returns how many messages were dropped for slow subscribers.
+func (h *Hub) Dropped() uint64 {
+	return h.dropped.Load()
+}
@@ -0,0 +1,46 @@
+//go:build redis
+
+package hub
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisAdapter fans messages out across nodes through Redis pub/sub.
+// Channels are named prefix+topic. Build with -tags redis.
+type RedisAdapter struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisAdapter(client *redis.Client, prefix string) *RedisAdapter {
+	return &RedisAdapter{client: client, prefix: prefix}
+}
+
+func (a *RedisAdapter) Publish(ctx context.Context, topic string, data []byte) error {
+	return a.client.Publish(ctx, a.prefix+topic, data).Err()
+}
+
+func (a *RedisAdapter) Run(ctx context.Context, deliver func(topic string, data []byte)) error {
+	pubsub := a.client.PSubscribe(ctx, a.prefix+"*")
+	defer pubsub.Close()
+	// 等待订阅确认，连接失败时返回错误以便重试
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			deliver(strings.TrimPrefix(msg.Channel, a.prefix), []byte(msg.Payload))
+		}
+	}
+}
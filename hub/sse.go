@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// SSE连接的心跳间隔，防止代理关闭空闲连接
+const sseHeartbeat = 15 * time.Second
+
+// SSEHandler streams the messages of the topics returned by topics as
+// server-sent events, with the topic as the event name. The stream is
+// tracked by the app; on Shutdown a "close" event carrying the reason is
+// sent and the stream ends.
+func (h *Hub) SSEHandler(topics func(r *http.Request) []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming Unsupported", http.StatusInternalServerError)
+			return
+		}
+		sub := h.Subscribe(topics(r)...)
+		defer sub.Close()
+		closing := make(chan string, 1)
+		done := cyber.TrackStream(r, func(reason string) {
+			select {
+			case closing <- reason:
+			default:
+			}
+		})
+		defer done()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case reason := <-closing:
+				writeEvent(w, Message{Topic: "close", Data: []byte(reason)})
+				flusher.Flush()
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case msg, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				writeEvent(w, msg)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent 写出一个SSE事件，数据中的每一行都需要单独的data字段
+func writeEvent(w http.ResponseWriter, msg Message) {
+	fmt.Fprintf(w, "event: %s\n", msg.Topic)
+	for _, line := range bytes.Split(msg.Data, []byte("\n")) {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
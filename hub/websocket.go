@@ -0,0 +1,85 @@
+//go:build websocket
+
+package hub
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/suonanjiexi/cyber"
+)
+
+type WebSocketConfig struct {
+	// Topics 返回连接订阅的主题
+	Topics func(r *http.Request) []string
+	// OnMessage 处理客户端发来的消息，例如转发到某个主题；为空时忽略客户端消息
+	OnMessage func(r *http.Request, data []byte)
+	// CheckOrigin 校验Origin请求头，默认只允许同源
+	CheckOrigin func(r *http.Request) bool
+}
+
+// WebSocket连接的写超时和心跳间隔
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+// WebSocketHandler upgrades the request and sends the messages of the
+// subscribed topics as text frames. The connection is tracked by the app;
+// on Shutdown it receives a going-away close frame with the reason. Build
+// with -tags websocket.
+func (h *Hub) WebSocketHandler(config WebSocketConfig) http.HandlerFunc {
+	upgrader := websocket.Upgrader{CheckOrigin: config.CheckOrigin}
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade已经写出错误响应
+			return
+		}
+		defer conn.Close()
+		sub := h.Subscribe(config.Topics(r)...)
+		defer sub.Close()
+		// 关闭服务器时发送关闭帧，客户端回应后读循环结束
+		done := cyber.TrackStream(r, func(reason string) {
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, reason), time.Now().Add(wsWriteTimeout))
+		})
+		defer done()
+
+		// 读循环：处理客户端消息，连接关闭时通知写循环退出
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if config.OnMessage != nil {
+					config.OnMessage(r, data)
+				}
+			}
+		}()
+
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+					return
+				}
+			case msg, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteMessage(websocket.TextMessage, msg.Data); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package id generates unique, time-ordered identifiers for request IDs,
+// idempotency keys and primary keys: UUIDv7 and ULID, both 128-bit with
+// a millisecond timestamp and randomness, and Snowflake, a 64-bit integer
+// made of a timestamp, a node ID and a sequence. IDs from one process are
+// strictly increasing, so they index well as database keys.
+package id
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// UUID is an RFC 9562 UUID.
+type UUID [16]byte
+
+// ErrInvalid is returned when parsing a malformed ID.
+var ErrInvalid = errors.New("id: invalid id")
+
+// uuidState 保证同一毫秒内生成的UUIDv7递增
+var uuidState struct {
+	mu      sync.Mutex
+	ms      int64
+	counter uint16
+}
+
+// NewUUID returns a UUIDv7. Within one millisecond the 12-bit rand_a field
+// is used as a counter (RFC 9562 method 1), so IDs from this process sort
+// in generation order.
+func NewUUID() UUID {
+	var u UUID
+	randomBytes(u[6:])
+	ms := time.Now().UnixMilli()
+	uuidState.mu.Lock()
+	if ms <= uuidState.ms {
+		ms = uuidState.ms
+		uuidState.counter++
+		// 计数器用尽时借用下一毫秒
+		if uuidState.counter > 0xfff {
+			ms++
+			uuidState.counter = uint16(binary.BigEndian.Uint16(u[6:]) & 0x7ff)
+		}
+	} else {
+		// 新的毫秒从随机值开始，最高位留空，给同一毫秒内的递增留出空间
+		uuidState.counter = binary.BigEndian.Uint16(u[6:]) & 0x7ff
+	}
+	uuidState.ms = ms
+	counter := uuidState.counter
+	uuidState.mu.Unlock()
+	putMillis(u[:6], ms)
+	binary.BigEndian.PutUint16(u[6:], 0x7000|counter)
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// NewUUIDString returns a UUIDv7 in its canonical string form.
+func NewUUIDString() string {
+	return NewUUID().String()
+}
+
+// ParseUUID parses the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx,
+// in either case.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUID{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	return u, nil
+}
+
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// Version returns the UUID version, 7 for NewUUID.
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// Time returns the creation time of a UUIDv7 to the millisecond.
+func (u UUID) Time() time.Time {
+	return time.UnixMilli(millis(u[:6]))
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("id: reading random bytes: %v", err))
+	}
+}
+
+// putMillis 把毫秒时间戳写成48位大端整数
+func putMillis(b []byte, ms int64) {
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+func millis(b []byte) int64 {
+	var ms int64
+	for _, c := range b[:6] {
+		ms = ms<<8 | int64(c)
+	}
+	return ms
+}
@@ -0,0 +1,85 @@
+package id
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	// MaxNode is the largest node ID a Snowflake accepts.
+	MaxNode     = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+)
+
+type SnowflakeConfig struct {
+	// 节点ID，0到MaxNode，同一时间运行的每个实例必须不同
+	Node int64
+	// 时间戳的起点，默认2024-01-01 UTC；41位毫秒可以用约69年。ID生成后不能再修改
+	Epoch time.Time
+}
+
+var defaultSnowflakeConfig = SnowflakeConfig{
+	Epoch: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// Snowflake generates 64-bit IDs made of 41 bits of milliseconds since
+// Epoch, 10 bits of node ID and a 12-bit sequence, up to 4096 IDs per
+// millisecond per node. Use NewSnowflake to create one.
+type Snowflake struct {
+	mu       sync.Mutex
+	node     int64
+	epoch    int64
+	last     int64
+	sequence int64
+}
+
+// NewSnowflake creates a generator for config.Node.
+func NewSnowflake(config SnowflakeConfig) (*Snowflake, error) {
+	if config.Node < 0 || config.Node > MaxNode {
+		return nil, fmt.Errorf("id: snowflake node %d out of range [0, %d]", config.Node, MaxNode)
+	}
+	if config.Epoch.IsZero() {
+		config.Epoch = defaultSnowflakeConfig.Epoch
+	}
+	return &Snowflake{node: config.Node, epoch: config.Epoch.UnixMilli()}, nil
+}
+
+// Next returns the next ID. When the sequence of the current millisecond
+// is used up, or the clock moved backwards, it waits for the clock to
+// catch up so IDs keep increasing.
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixMilli() - s.epoch
+	if now < s.last {
+		// 时钟回拨时等待追上，不生成可能重复的ID
+		time.Sleep(time.Duration(s.last-now) * time.Millisecond)
+		now = s.last
+	}
+	if now == s.last {
+		s.sequence = (s.sequence + 1) & maxSequence
+		if s.sequence == 0 {
+			for now <= s.last {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixMilli() - s.epoch
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.last = now
+	return now<<(nodeBits+sequenceBits) | s.node<<sequenceBits | s.sequence
+}
+
+// Time returns the creation time of an ID from this generator.
+func (s *Snowflake) Time(id int64) time.Time {
+	return time.UnixMilli(id>>(nodeBits+sequenceBits) + s.epoch)
+}
+
+// Node returns the node ID encoded in id.
+func (s *Snowflake) Node(id int64) int64 {
+	return id >> sequenceBits & MaxNode
+}
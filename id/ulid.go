@@ -0,0 +1,121 @@
+package id
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ULID is a Universally Unique Lexicographically Sortable Identifier: a
+// 48-bit millisecond timestamp and 80 random bits, written as 26
+// characters of Crockford's base32.
+type ULID [16]byte
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// crockfordValues 把字符映射回5位的值，不区分大小写，I L 视为 1，O 视为 0
+var crockfordValues = func() [256]byte {
+	var values [256]byte
+	for i := range values {
+		values[i] = 0xff
+	}
+	for i := 0; i < len(crockford); i++ {
+		values[crockford[i]] = byte(i)
+		values[crockford[i]|0x20] = byte(i)
+	}
+	values['I'], values['i'], values['L'], values['l'] = 1, 1, 1, 1
+	values['O'], values['o'] = 0, 0
+	return values
+}()
+
+// ulidState 保证同一毫秒内生成的ULID递增
+var ulidState struct {
+	mu   sync.Mutex
+	last ULID
+}
+
+// NewULID returns a ULID. Within one millisecond the random part of the
+// previous ULID is incremented, so IDs from this process sort in
+// generation order.
+func NewULID() ULID {
+	var u ULID
+	ms := time.Now().UnixMilli()
+	ulidState.mu.Lock()
+	defer ulidState.mu.Unlock()
+	if last := millis(ulidState.last[:6]); ms <= last {
+		u = ulidState.last
+		// 随机部分加一，溢出时进位到时间戳
+		for i := 15; i >= 0; i-- {
+			u[i]++
+			if u[i] != 0 {
+				break
+			}
+		}
+	} else {
+		putMillis(u[:6], ms)
+		randomBytes(u[6:])
+	}
+	ulidState.last = u
+	return u
+}
+
+// NewULIDString returns a ULID in its string form.
+func NewULIDString() string {
+	return NewULID().String()
+}
+
+// ParseULID parses the 26-character string form of a ULID.
+func ParseULID(s string) (ULID, error) {
+	var u ULID
+	// 26个字符共130位，第一个字符只能表示高3位
+	if len(s) != 26 || crockfordValues[s[0]] > 7 {
+		return u, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		v := crockfordValues[s[i]]
+		if v == 0xff {
+			return ULID{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	for i := 0; i < 8; i++ {
+		u[i] = byte(hi >> (56 - 8*i))
+		u[8+i] = byte(lo >> (56 - 8*i))
+	}
+	return u, nil
+}
+
+func (u ULID) String() string {
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(u[i])
+		lo = lo<<8 | uint64(u[8+i])
+	}
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// Time returns the creation time of the ULID to the millisecond.
+func (u ULID) Time() time.Time {
+	return time.UnixMilli(millis(u[:6]))
+}
+
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *ULID) UnmarshalText(text []byte) error {
+	parsed, err := ParseULID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package jwt signs and verifies JSON Web Tokens with keys held in a
+// Keyring, which rotates them and publishes the public keys as a JWKS for
+// other services validating the tokens.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+	HS256 = "HS256"
+)
+
+var (
+	ErrInvalidToken = errors.New("jwt: invalid token")
+	ErrExpired      = errors.New("jwt: token expired")
+	// ErrUnknownKey 表示令牌的kid不在密钥环中，或者密钥已经过了宽限期
+	ErrUnknownKey = errors.New("jwt: unknown key")
+)
+
+// Claims are the token's payload. Numeric claims such as exp are float64
+// after Verify, as with encoding/json.
+type Claims map[string]interface{}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// sign 用key签名claims，返回紧凑格式的令牌
+func sign(key *Key, claims Claims) (string, error) {
+	h, err := json.Marshal(header{Alg: key.Algorithm, Kid: key.ID, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// token 是拆分后的令牌，载荷在校验签名后才解码
+type token struct {
+	header       header
+	signingInput string
+	payload      string
+	signature    []byte
+}
+
+func parse(s string) (*token, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	t := &token{signingInput: parts[0] + "." + parts[1], payload: parts[1]}
+	if err := decodeSegment(parts[0], &t.header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	t.signature = signature
+	return t, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// checkTime 校验exp和nbf，leeway容忍服务器之间的时钟偏差
+func checkTime(claims Claims, now time.Time, leeway time.Duration) error {
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	digest := sha256.Sum256(input)
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RS256:
+		return rsa.SignPKCS1v15(rand.Reader, k.Private.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case ES256:
+		r, s, err := ecdsa.Sign(rand.Reader, k.Private.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS使用定长的 r||s，而不是ASN.1编码
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported algorithm %q", k.Algorithm)
+}
+
+func (k *Key) verify(input, signature []byte) bool {
+	digest := sha256.Sum256(input)
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(input)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case RS256:
+		return rsa.VerifyPKCS1v15(&k.Private.(*rsa.PrivateKey).PublicKey, crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(&k.Private.(*ecdsa.PrivateKey).PublicKey, digest[:], r, s)
+	}
+	return false
+}
@@ -0,0 +1,409 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// Key is a signing key.
+type Key struct {
+	// ID 是令牌头部的kid，为空时取公钥的JWK指纹（RFC 7638），HS256密钥随机生成
+	ID string
+	// Algorithm 是RS256、ES256或HS256
+	Algorithm string
+	// Private 是RS256的*rsa.PrivateKey或ES256的P-256 *ecdsa.PrivateKey
+	Private crypto.Signer
+	// Secret 是HS256的密钥，至少32字节；HS256密钥不会在JWKS中发布
+	Secret []byte
+	// Created 是密钥开始使用的时间，最新的有效密钥用于签名，自动轮换也从它起算；为零时取加入的时间
+	Created time.Time
+	// Retired 是密钥被新密钥取代的时间，之后在宽限期内只用于校验；为零表示有效
+	Retired time.Time
+}
+
+type KeyringConfig struct {
+	// Algorithm 是Rotate生成的密钥的算法，默认ES256
+	Algorithm string
+	// RotateEvery 是签名密钥的使用期限，过期后下一次签名前自动轮换；为0时只能手动调用Rotate
+	RotateEvery time.Duration
+	// Grace 是密钥退役后继续用于校验并在JWKS中发布的时间，应不小于令牌有效期加上校验方缓存JWKS的时间，默认24小时
+	Grace time.Duration
+	// Leeway 是校验exp和nbf时容忍的时钟偏差
+	Leeway time.Duration
+	// OnRotate 在轮换生成新密钥后调用，用于持久化密钥或分发给其他实例（通过Add）
+	OnRotate func(Key)
+}
+
+const defaultGrace = 24 * time.Hour
+
+var defaultKeyringConfig = KeyringConfig{
+	Algorithm: ES256,
+	Grace:     defaultGrace,
+}
+
+// Keyring holds the keys tokens are signed and verified with. The newest
+// key that isn't retired signs; retired keys still verify, and stay in the
+// JWKS, for the grace period, so tokens signed before a rotation remain
+// valid. Instances of one service must share keys: rotate on one and pass
+// the key to the others with Add, e.g. from OnRotate through a database.
+type Keyring struct {
+	config KeyringConfig
+	mu     sync.RWMutex
+	keys   []*Key
+	// rotateMu 使并发签名时到期的密钥只轮换一次
+	rotateMu sync.Mutex
+}
+
+// NewKeyring creates a keyring holding keys, e.g. loaded from storage.
+// Without any key that isn't retired, it generates one.
+func NewKeyring(config KeyringConfig, keys ...Key) (*Keyring, error) {
+	if config.Algorithm == "" {
+		config.Algorithm = defaultKeyringConfig.Algorithm
+	}
+	if config.Grace <= 0 {
+		config.Grace = defaultKeyringConfig.Grace
+	}
+	kr := &Keyring{config: config}
+	for _, key := range keys {
+		if err := kr.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	if kr.signingKey() == nil {
+		if _, err := kr.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+// GenerateKey creates a key for algorithm: a P-256 key for ES256, a
+// 2048-bit RSA key for RS256 or a random 32-byte secret for HS256.
+func GenerateKey(algorithm string) (Key, error) {
+	key := Key{Algorithm: algorithm, Created: time.Now()}
+	var err error
+	switch algorithm {
+	case ES256:
+		key.Private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case RS256:
+		key.Private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case HS256:
+		key.Secret = make([]byte, 32)
+		_, err = rand.Read(key.Secret)
+	default:
+		return key, fmt.Errorf("jwt: unsupported algorithm %q", algorithm)
+	}
+	if err != nil {
+		return key, err
+	}
+	return key, key.setID()
+}
+
+// Add adds key to the keyring, replacing a key with the same ID. A key
+// that isn't retired and is newer than the current signing key takes over
+// signing; the previous keys stay valid until retired by Rotate.
+func (kr *Keyring) Add(key Key) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+	if key.ID == "" {
+		if err := key.setID(); err != nil {
+			return err
+		}
+	}
+	if key.Created.IsZero() {
+		key.Created = time.Now()
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.remove(key.ID)
+	kr.keys = append(kr.keys, &key)
+	return nil
+}
+
+// Remove deletes the key with id, e.g. a compromised one. Tokens signed
+// with it fail verification immediately.
+func (kr *Keyring) Remove(id string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.remove(id)
+}
+
+func (kr *Keyring) remove(id string) {
+	for i, k := range kr.keys {
+		if k.ID == id {
+			kr.keys = append(kr.keys[:i], kr.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// Rotate generates a new signing key and retires all others, which keep
+// verifying tokens for the grace period.
+func (kr *Keyring) Rotate() (Key, error) {
+	key, err := GenerateKey(kr.config.Algorithm)
+	if err != nil {
+		return key, err
+	}
+	kr.mu.Lock()
+	// 顺便丢弃已过宽限期的密钥
+	keys := kr.keys[:0]
+	for _, k := range kr.keys {
+		if k.Retired.IsZero() {
+			k.Retired = key.Created
+		}
+		if kr.usable(k, key.Created) {
+			keys = append(keys, k)
+		}
+	}
+	kr.keys = append(keys, &key)
+	kr.mu.Unlock()
+	if kr.config.OnRotate != nil {
+		kr.config.OnRotate(key)
+	}
+	return key, nil
+}
+
+// Keys returns the keys, including retired keys within the grace period,
+// e.g. to persist them.
+func (kr *Keyring) Keys() []Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	now := time.Now()
+	keys := make([]Key, 0, len(kr.keys))
+	for _, k := range kr.keys {
+		if kr.usable(k, now) {
+			keys = append(keys, *k)
+		}
+	}
+	return keys
+}
+
+// usable 报告密钥是否仍可用于校验：未退役，或者仍在宽限期内
+func (kr *Keyring) usable(k *Key, now time.Time) bool {
+	return k.Retired.IsZero() || now.Before(k.Retired.Add(kr.config.Grace))
+}
+
+// signingKey 返回最新的未退役密钥，调用方持有锁或在初始化时调用
+func (kr *Keyring) signingKey() *Key {
+	var newest *Key
+	for _, k := range kr.keys {
+		if k.Retired.IsZero() && (newest == nil || k.Created.After(newest.Created)) {
+			newest = k
+		}
+	}
+	return newest
+}
+
+// Sign signs claims with the current signing key, rotating it first when
+// it is older than RotateEvery.
+func (kr *Keyring) Sign(claims Claims) (string, error) {
+	key := kr.currentKey()
+	if kr.due(key) {
+		key = kr.rotateDue()
+	}
+	if key == nil {
+		return "", errors.New("jwt: no signing key")
+	}
+	return sign(key, claims)
+}
+
+func (kr *Keyring) currentKey() *Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.signingKey()
+}
+
+func (kr *Keyring) due(key *Key) bool {
+	return key == nil || (kr.config.RotateEvery > 0 && time.Since(key.Created) >= kr.config.RotateEvery)
+}
+
+// rotateDue 轮换到期的签名密钥，轮换失败时继续使用旧密钥
+func (kr *Keyring) rotateDue() *Key {
+	kr.rotateMu.Lock()
+	defer kr.rotateMu.Unlock()
+	// 等待锁期间其他签名可能已经轮换
+	key := kr.currentKey()
+	if !kr.due(key) {
+		return key
+	}
+	if _, err := kr.Rotate(); err != nil {
+		log.Printf("JWT key rotation failed: %v", err)
+		return key
+	}
+	return kr.currentKey()
+}
+
+// Verify checks the token's signature with the key named by its kid, and
+// exp and nbf when present, and returns its claims. The token's algorithm
+// must be the key's, so an RS256 key can't be used as an HS256 secret.
+func (kr *Keyring) Verify(token string) (Claims, error) {
+	t, err := parse(token)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	kr.mu.RLock()
+	var key *Key
+	for _, k := range kr.keys {
+		if k.ID == t.header.Kid && kr.usable(k, now) {
+			key = k
+			break
+		}
+	}
+	kr.mu.RUnlock()
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	if t.header.Alg != key.Algorithm || !key.verify([]byte(t.signingInput), t.signature) {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := decodeSegment(t.payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := checkTime(claims, now, kr.config.Leeway); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// JWK is a public key as published in a JWKS.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the RS256 and ES256 keys that verify
+// tokens, newest first.
+func (kr *Keyring) JWKS() JWKS {
+	keys := kr.Keys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.After(keys[j].Created) })
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range keys {
+		if jwk, ok := k.publicJWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// Handler serves the JWKS, e.g.
+// app.Get("/.well-known/jwks.json", keyring.Handler). Validators may cache
+// it for five minutes; keep Grace longer than that.
+func (kr *Keyring) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	cyber.ServeJSON(w, r, kr.JWKS())
+}
+
+func (k *Key) validate() error {
+	switch k.Algorithm {
+	case HS256:
+		if len(k.Secret) < 32 {
+			return errors.New("jwt: HS256 secret must be at least 32 bytes")
+		}
+	case RS256:
+		if _, ok := k.Private.(*rsa.PrivateKey); !ok {
+			return errors.New("jwt: RS256 needs an *rsa.PrivateKey")
+		}
+	case ES256:
+		ec, ok := k.Private.(*ecdsa.PrivateKey)
+		if !ok || ec.Curve != elliptic.P256() {
+			return errors.New("jwt: ES256 needs a P-256 *ecdsa.PrivateKey")
+		}
+	default:
+		return fmt.Errorf("jwt: unsupported algorithm %q", k.Algorithm)
+	}
+	return nil
+}
+
+// setID 取公钥的JWK指纹作为kid，HS256密钥没有公钥，使用随机值
+func (k *Key) setID() error {
+	jwk, ok := k.publicJWK()
+	if !ok {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		k.ID = hex.EncodeToString(b)
+		return nil
+	}
+	// RFC 7638：按字典序只包含必需成员的JSON
+	var members interface{}
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	k.ID = base64.RawURLEncoding.EncodeToString(sum[:])
+	return nil
+}
+
+func (k *Key) publicJWK() (JWK, bool) {
+	switch priv := k.Private.(type) {
+	case *rsa.PrivateKey:
+		if k.Algorithm != RS256 {
+			return JWK{}, false
+		}
+		return JWK{
+			Kty: "RSA", Kid: k.ID, Use: "sig", Alg: RS256,
+			N: base64.RawURLEncoding.EncodeToString(priv.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.E)).Bytes()),
+		}, true
+	case *ecdsa.PrivateKey:
+		if k.Algorithm != ES256 {
+			return JWK{}, false
+		}
+		x, y := make([]byte, 32), make([]byte, 32)
+		priv.X.FillBytes(x)
+		priv.Y.FillBytes(y)
+		return JWK{
+			Kty: "EC", Kid: k.ID, Use: "sig", Alg: ES256, Crv: "P-256",
+			X: base64.RawURLEncoding.EncodeToString(x),
+			Y: base64.RawURLEncoding.EncodeToString(y),
+		}, true
+	}
+	return JWK{}, false
+}
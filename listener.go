@@ -0,0 +1,134 @@
+package cyber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// systemd传入的第一个文件描述符
+const listenFDsStart = 3
+
+// SystemdListeners returns the sockets passed by systemd socket activation
+// (LISTEN_PID/LISTEN_FDS), or nil when the process wasn't socket activated.
+func SystemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	// 避免子进程再次继承这些变量
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	listeners := make([]net.Listener, 0, count)
+	for fd := listenFDsStart; fd < listenFDsStart+count; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("cyber: systemd socket %d: %w", fd, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+var errListenerClosed = errors.New("cyber: listener closed")
+
+// MemoryListener is an in-memory net.Listener for tests: serve an App on it
+// and point an http.Transport's DialContext at its Dial method, without
+// opening a port.
+type MemoryListener struct {
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func NewMemoryListener() *MemoryListener {
+	return &MemoryListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *MemoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *MemoryListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *MemoryListener) Addr() net.Addr {
+	return memoryAddr{}
+}
+
+// Dial connects to the listener; the network and address are ignored.
+func (l *MemoryListener) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		server.Close()
+		client.Close()
+		return nil, errListenerClosed
+	case <-ctx.Done():
+		server.Close()
+		client.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type memoryAddr struct{}
+
+func (memoryAddr) Network() string { return "memory" }
+func (memoryAddr) String() string  { return "memory" }
+
+// tcpTuningListener 对每个接受的TCP连接应用AppConfig中的TCP选项，其他类型的连接原样返回
+type tcpTuningListener struct {
+	net.Listener
+	options tcpOptions
+}
+
+func (l *tcpTuningListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return conn, nil
+	}
+	if l.options.keepAlive < 0 {
+		tc.SetKeepAlive(false)
+	} else if l.options.keepAlive > 0 {
+		tc.SetKeepAlive(true)
+		tc.SetKeepAlivePeriod(l.options.keepAlive)
+	}
+	if !l.options.noDelay {
+		tc.SetNoDelay(false)
+	}
+	if l.options.linger < 0 {
+		tc.SetLinger(0)
+	} else if l.options.linger > 0 {
+		// SO_LINGER以秒为单位，不足一秒按一秒处理
+		tc.SetLinger(int((l.options.linger + time.Second - 1) / time.Second))
+	}
+	return conn, nil
+}
@@ -0,0 +1,64 @@
+// Package logger throttles log output for noisy events, such as rejected
+// requests or 404 floods during an attack, so the log can't grow into a
+// storm that slows the service down. Suppressed messages are counted and
+// the count is reported with the next message written.
+package logger
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Printer is the output of a Logger, e.g. a *log.Logger.
+type Printer interface {
+	Printf(format string, v ...interface{})
+}
+
+// Logger writes only some of the messages passed to Printf. Create one
+// with Sampled or Every; it is safe for concurrent use.
+type Logger struct {
+	allow      func() bool
+	out        Printer
+	suppressed atomic.Uint64
+}
+
+// Sampled writes each message with probability rate, between 0 and 1,
+// e.g. 0.01 for one in a hundred.
+func Sampled(rate float64) *Logger {
+	return &Logger{out: log.Default(), allow: func() bool {
+		return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+	}}
+}
+
+// Every writes at most one message per interval.
+func Every(interval time.Duration) *Logger {
+	var next atomic.Int64
+	return &Logger{out: log.Default(), allow: func() bool {
+		now := time.Now().UnixNano()
+		n := next.Load()
+		return now >= n && next.CompareAndSwap(n, now+int64(interval))
+	}}
+}
+
+// To sets the output, log.Default() by default, and returns l.
+func (l *Logger) To(out Printer) *Logger {
+	l.out = out
+	return l
+}
+
+// Printf writes the message if the logger lets it through, followed by the
+// number of messages suppressed since the last one written.
+func (l *Logger) Printf(format string, v ...interface{}) {
+	if !l.allow() {
+		l.suppressed.Add(1)
+		return
+	}
+	if n := l.suppressed.Swap(0); n > 0 {
+		l.out.Printf("%s (%d similar messages suppressed)", fmt.Sprintf(format, v...), n)
+		return
+	}
+	l.out.Printf(format, v...)
+}
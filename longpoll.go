@@ -0,0 +1,53 @@
+package cyber
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// 长轮询的重试间隔从longPollMinInterval开始翻倍，最多longPollMaxInterval
+const (
+	longPollMinInterval = 50 * time.Millisecond
+	longPollMaxInterval = time.Second
+)
+
+// LongPoll calls poll with backoff until it reports data, which is sent
+// with Success, or until timeout passes, which answers 204 No Content so
+// the client polls again. Errors from poll are passed to Abort. Nothing is
+// written when the client goes away. The wait is also capped by the
+// request's deadline, see TimeRemaining.
+func LongPoll(w http.ResponseWriter, r *http.Request, timeout time.Duration, poll func(ctx context.Context) (data interface{}, ok bool, err error)) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	interval := longPollMinInterval
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if !IsClientGone(r) {
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		case <-timer.C:
+		}
+		data, ok, err := poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				// poll因超时或断开而失败，按上面的分支处理
+				continue
+			}
+			Abort(w, r, err)
+			return
+		}
+		if ok {
+			Success(w, r, http.StatusOK, data)
+			return
+		}
+		timer.Reset(interval)
+		if interval *= 2; interval > longPollMaxInterval {
+			interval = longPollMaxInterval
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// 默认的告警检查间隔
+const defaultAlertInterval = 15 * time.Second
+
+// 发送一次通知的超时时间
+const notifyTimeout = 10 * time.Second
+
+// Notifier delivers alert state changes, e.g. to a chat or paging system.
+type Notifier interface {
+	Notify(ctx context.Context, event AlertEvent) error
+}
+
+// NotifierFunc adapts a function to Notifier.
+type NotifierFunc func(ctx context.Context, event AlertEvent) error
+
+func (f NotifierFunc) Notify(ctx context.Context, event AlertEvent) error {
+	return f(ctx, event)
+}
+
+// AlertEvent reports that a rule started firing or resolved.
+type AlertEvent struct {
+	Rule string `json:"rule"`
+	// Firing 为false表示告警已恢复
+	Firing    bool    `json:"firing"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Since 是条件开始满足的时间
+	Since time.Time `json:"since"`
+	At    time.Time `json:"at"`
+}
+
+func (e AlertEvent) String() string {
+	if e.Firing {
+		return fmt.Sprintf("FIRING: %s (value %s since %s)", e.Rule, strconv.FormatFloat(e.Value, 'g', 4, 64), e.Since.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("RESOLVED: %s (value %s)", e.Rule, strconv.FormatFloat(e.Value, 'g', 4, 64))
+}
+
+// WebhookNotifier posts each event as JSON to URL.
+type WebhookNotifier struct {
+	URL string
+	// 附加的请求头，例如认证信息
+	Header http.Header
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, event AlertEvent) error {
+	return postJSON(ctx, n.Client, n.URL, n.Header, event)
+}
+
+// SlackNotifier posts each event as a message to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, event AlertEvent) error {
+	return postJSON(ctx, n.Client, n.WebhookURL, nil, map[string]string{"text": event.String()})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("metrics: notifier %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// alertRule 是解析后的告警规则及其状态
+type alertRule struct {
+	text      string
+	metric    string
+	route     string
+	op        string
+	threshold float64
+	duration  time.Duration
+	notifier  Notifier
+	// pendingSince 是条件开始满足的时间，不满足时为零值
+	pendingSince time.Time
+	firing       bool
+}
+
+// 规则格式：指标[路由] 比较符 阈值 [for 持续时间]
+var alertRulePattern = regexp.MustCompile(`^\s*([a-z0-9_]+)\s*(?:\[([^\]]+)\])?\s*(>=|<=|>|<)\s*([-+0-9.eE]+)\s*(?:for\s+(\S+))?\s*$`)
+
+var alertMetrics = map[string]bool{
+	"requests":            true,
+	"requests_per_second": true,
+	"error_rate":          true,
+	"avg_latency_ms":      true,
+	"p50_latency_ms":      true,
+	"p90_latency_ms":      true,
+	"p99_latency_ms":      true,
+}
+
+func parseAlertRule(rule string) (*alertRule, error) {
+	match := alertRulePattern.FindStringSubmatch(rule)
+	if match == nil {
+		return nil, fmt.Errorf("metrics: invalid alert rule %q", rule)
+	}
+	if !alertMetrics[match[1]] {
+		return nil, fmt.Errorf("metrics: unknown metric %q in alert rule %q", match[1], rule)
+	}
+	threshold, err := strconv.ParseFloat(match[4], 64)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: invalid threshold in alert rule %q: %w", rule, err)
+	}
+	var duration time.Duration
+	if match[5] != "" {
+		if duration, err = time.ParseDuration(match[5]); err != nil {
+			return nil, fmt.Errorf("metrics: invalid duration in alert rule %q: %w", rule, err)
+		}
+	}
+	return &alertRule{text: rule, metric: match[1], route: match[2], op: match[3], threshold: threshold, duration: duration}, nil
+}
+
+// Alert notifies notifier when rule starts to hold and again when it stops,
+// e.g.
+//
+//	m.Alert("error_rate > 0.05 for 5m", &metrics.SlackNotifier{WebhookURL: url})
+//	m.Alert("p99_latency_ms[GET /search] > 500 for 2m", notifier)
+//
+// A rule compares a metric with a threshold using >, >=, < or <=, and may
+// require the condition to hold for a duration before firing. Metrics are
+// computed over Config.HotWindow: requests, requests_per_second,
+// error_rate (share of 5xx responses), avg_latency_ms and
+// p50/p90/p99_latency_ms. They cover all requests, or one route given by
+// its Route.Key in brackets. A window without requests only satisfies
+// rules on requests and requests_per_second. Rules are checked every
+// Config.AlertInterval by a goroutine started with the first rule.
+func (m *Metrics) Alert(rule string, notifier Notifier) error {
+	parsed, err := parseAlertRule(rule)
+	if err != nil {
+		return err
+	}
+	parsed.notifier = notifier
+	m.alertsMu.Lock()
+	defer m.alertsMu.Unlock()
+	m.alerts = append(m.alerts, parsed)
+	if m.alertsStop == nil {
+		interval := m.config.AlertInterval
+		if interval <= 0 {
+			interval = defaultAlertInterval
+		}
+		m.alertsStop = make(chan struct{})
+		go m.runAlerts(interval, m.alertsStop)
+	}
+	return nil
+}
+
+// StopAlerts stops checking the alert rules; a later Alert restarts it.
+func (m *Metrics) StopAlerts() {
+	m.alertsMu.Lock()
+	defer m.alertsMu.Unlock()
+	if m.alertsStop != nil {
+		close(m.alertsStop)
+		m.alertsStop = nil
+	}
+}
+
+func (m *Metrics) runAlerts(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			m.evaluateAlerts(now)
+		}
+	}
+}
+
+type alertNotification struct {
+	notifier Notifier
+	event    AlertEvent
+}
+
+func (m *Metrics) evaluateAlerts(now time.Time) {
+	// 没有请求时也要推进采样，否则窗口一直停留在最后一次请求时
+	m.maybeSample()
+	elapsed, deltas := m.window(now)
+	var notifications []alertNotification
+	m.alertsMu.Lock()
+	for _, rule := range m.alerts {
+		value, ok := alertValue(rule.metric, deltas[rule.route], elapsed)
+		holds := ok && compare(value, rule.op, rule.threshold)
+		event := AlertEvent{Rule: rule.text, Value: value, Threshold: rule.threshold, Since: rule.pendingSince, At: now}
+		switch {
+		case holds && rule.pendingSince.IsZero():
+			rule.pendingSince = now
+			event.Since = now
+			fallthrough
+		case holds:
+			if !rule.firing && now.Sub(rule.pendingSince) >= rule.duration {
+				rule.firing = true
+				event.Firing = true
+				notifications = append(notifications, alertNotification{rule.notifier, event})
+			}
+		default:
+			if rule.firing {
+				notifications = append(notifications, alertNotification{rule.notifier, event})
+			}
+			rule.pendingSince, rule.firing = time.Time{}, false
+		}
+	}
+	m.alertsMu.Unlock()
+	for _, n := range notifications {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		if err := n.notifier.Notify(ctx, n.event); err != nil {
+			log.Printf("Error sending alert %q: %v", n.event.Rule, err)
+		}
+		cancel()
+	}
+}
+
+// alertValue 计算窗口内的指标值，没有请求时比例和延迟类指标没有值
+func alertValue(metric string, delta rawCounts, elapsed float64) (float64, bool) {
+	switch metric {
+	case "requests":
+		return float64(delta.requests), true
+	case "requests_per_second":
+		if elapsed <= 0 {
+			return 0, true
+		}
+		return float64(delta.requests) / elapsed, true
+	}
+	if delta.requests == 0 {
+		return 0, false
+	}
+	switch metric {
+	case "error_rate":
+		return float64(delta.errors) / float64(delta.requests), true
+	case "avg_latency_ms":
+		return durationMs(time.Duration(delta.durationNanos / delta.requests)), true
+	case "p50_latency_ms":
+		return durationMs(percentile(delta.buckets, delta.requests, 0.50)), true
+	case "p90_latency_ms":
+		return durationMs(percentile(delta.buckets, delta.requests, 0.90)), true
+	case "p99_latency_ms":
+		return durationMs(percentile(delta.buckets, delta.requests, 0.99)), true
+	}
+	return 0, false
+}
+
+func compare(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// 默认的统计窗口
+const defaultHotWindow = 5 * time.Minute
+
+// 每个窗口内的采样次数，窗口边界的误差不超过窗口的1/hotSamples
+const hotSamples = 10
+
+// 默认返回的路由数
+const defaultHotN = 10
+
+// 按延迟和错误率排名时路由在窗口内至少需要的请求数，避免个别请求干扰排名
+const defaultHotMinRequests = 10
+
+// HotRoute is a route's activity over the hot window.
+type HotRoute struct {
+	Route             string  `json:"route"`
+	Requests          uint64  `json:"requests"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	// ErrorRate 是5xx响应占请求的比例
+	ErrorRate    float64 `json:"error_rate"`
+	P99LatencyMs float64 `json:"p99_latency_ms"`
+}
+
+// HotRoutes are the top routes over the last Config.HotWindow.
+type HotRoutes struct {
+	// WindowSeconds 是实际统计的时长，启动后不满一个窗口时较短
+	WindowSeconds float64    `json:"window_seconds"`
+	Slowest       []HotRoute `json:"slowest"`
+	Busiest       []HotRoute `json:"busiest"`
+	MostErrors    []HotRoute `json:"most_errors"`
+}
+
+// rawCounts 是路由计数器的累计值，两次采样之差即为期间的请求
+type rawCounts struct {
+	requests      uint64
+	errors        uint64
+	durationNanos uint64
+	buckets       []uint64
+}
+
+// sub 返回c减去采样previous的增量
+func (c rawCounts) sub(previous rawCounts) rawCounts {
+	delta := rawCounts{
+		requests:      c.requests - previous.requests,
+		errors:        c.errors - previous.errors,
+		durationNanos: c.durationNanos - previous.durationNanos,
+		buckets:       make([]uint64, len(c.buckets)),
+	}
+	for i := range delta.buckets {
+		delta.buckets[i] = c.buckets[i]
+		if previous.buckets != nil {
+			delta.buckets[i] -= previous.buckets[i]
+		}
+	}
+	return delta
+}
+
+type hotSample struct {
+	at     time.Time
+	routes map[string]rawCounts
+}
+
+// hotHistory 保存各路由计数器的定期采样，用于计算滑动窗口内的增量
+type hotHistory struct {
+	mu      sync.Mutex
+	samples []hotSample
+}
+
+func (c *counters) raw() rawCounts {
+	counts := rawCounts{buckets: make([]uint64, len(latencyBounds)+1)}
+	for i := range c.shards {
+		s := &c.shards[i]
+		counts.requests += s.requests.Load()
+		counts.errors += s.statusClasses[4].Load()
+		counts.durationNanos += s.durationNanos.Load()
+		for j := range counts.buckets {
+			counts.buckets[j] += s.buckets[j].Load()
+		}
+	}
+	return counts
+}
+
+// routeCounts 返回各路由的累计计数，键""是所有请求的
+func (m *Metrics) routeCounts() map[string]rawCounts {
+	routes := map[string]rawCounts{"": m.total.raw()}
+	m.routes.Range(func(route, c interface{}) bool {
+		routes[route.(string)] = c.(*counters).raw()
+		return true
+	})
+	return routes
+}
+
+// maybeSample 在到达采样时间后、记录请求之前采样。到达采样时间后的第一个请求
+// 就会触发采样，所以此时的计数与计划采样时的相同，采样按计划时间记录；
+// 没有请求时计数器不变，也就不需要采样
+func (m *Metrics) maybeSample() {
+	now := time.Now()
+	next := m.nextSample.Load()
+	if now.UnixNano() < next || !m.nextSample.CompareAndSwap(next, now.UnixNano()+int64(m.hotWindow/hotSamples)) {
+		return
+	}
+	at := now
+	if next != 0 {
+		at = time.Unix(0, next)
+	}
+	sample := hotSample{at: at, routes: m.routeCounts()}
+	m.hot.mu.Lock()
+	defer m.hot.mu.Unlock()
+	m.hot.samples = append(m.hot.samples, sample)
+	// 只保留窗口内的采样和窗口开始前最近的一个
+	cutoff := sample.at.Add(-m.hotWindow)
+	drop := 0
+	for drop+1 < len(m.hot.samples) && !m.hot.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	m.hot.samples = append(m.hot.samples[:0], m.hot.samples[drop:]...)
+}
+
+// baseline 返回窗口开始时的计数，没有足够早的采样时从启动时算起
+func (m *Metrics) baseline(now time.Time) (time.Time, map[string]rawCounts) {
+	cutoff := now.Add(-m.hotWindow)
+	m.hot.mu.Lock()
+	defer m.hot.mu.Unlock()
+	at, routes := m.startTime, map[string]rawCounts(nil)
+	for _, sample := range m.hot.samples {
+		if sample.at.After(cutoff) {
+			break
+		}
+		at, routes = sample.at, sample.routes
+	}
+	return at, routes
+}
+
+// window 返回窗口内各路由的请求增量和实际统计的秒数，键""是所有请求的
+func (m *Metrics) window(now time.Time) (float64, map[string]rawCounts) {
+	since, base := m.baseline(now)
+	deltas := make(map[string]rawCounts)
+	for route, current := range m.routeCounts() {
+		deltas[route] = current.sub(base[route])
+	}
+	return now.Sub(since).Seconds(), deltas
+}
+
+// HotRoutes returns the n slowest routes by p99 latency, the n busiest by
+// throughput and the n with the highest 5xx rate over the last
+// Config.HotWindow. Routes with fewer than minRequests requests in the
+// window aren't ranked by latency or error rate.
+func (m *Metrics) HotRoutes(n, minRequests int) HotRoutes {
+	elapsed, deltas := m.window(time.Now())
+	var routes []HotRoute
+	for route, delta := range deltas {
+		if route == "" || delta.requests == 0 {
+			continue
+		}
+		hot := HotRoute{
+			Route:        route,
+			Requests:     delta.requests,
+			ErrorRate:    float64(delta.errors) / float64(delta.requests),
+			P99LatencyMs: durationMs(percentile(delta.buckets, delta.requests, 0.99)),
+		}
+		if elapsed > 0 {
+			hot.RequestsPerSecond = float64(delta.requests) / elapsed
+		}
+		routes = append(routes, hot)
+	}
+	significant := make([]HotRoute, 0, len(routes))
+	for _, route := range routes {
+		if route.Requests >= uint64(minRequests) {
+			significant = append(significant, route)
+		}
+	}
+	return HotRoutes{
+		WindowSeconds: elapsed,
+		Slowest:       topRoutes(significant, n, func(r HotRoute) float64 { return r.P99LatencyMs }),
+		Busiest:       topRoutes(routes, n, func(r HotRoute) float64 { return r.RequestsPerSecond }),
+		MostErrors:    topRoutes(significant, n, func(r HotRoute) float64 { return r.ErrorRate }),
+	}
+}
+
+// topRoutes 按key从大到小取前n个，key为0的路由不参与排名
+func topRoutes(routes []HotRoute, n int, key func(HotRoute) float64) []HotRoute {
+	top := make([]HotRoute, 0, len(routes))
+	for _, route := range routes {
+		if key(route) > 0 {
+			top = append(top, route)
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if ki, kj := key(top[i]), key(top[j]); ki != kj {
+			return ki > kj
+		}
+		return top[i].Route < top[j].Route
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// HotHandler serves HotRoutes as JSON for on-call dashboards, e.g.
+// app.Get("/admin/hot", m.HotHandler). The query parameters n and
+// min_requests override the defaults of 10 routes per list and 10
+// requests.
+func (m *Metrics) HotHandler(w http.ResponseWriter, r *http.Request) {
+	n, minRequests := defaultHotN, defaultHotMinRequests
+	if v, err := strconv.Atoi(r.URL.Query().Get("n")); err == nil && v > 0 {
+		n = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("min_requests")); err == nil && v >= 0 {
+		minRequests = v
+	}
+	cyber.ServeJSON(w, r, m.HotRoutes(n, minRequests))
+}
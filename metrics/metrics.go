@@ -0,0 +1,406 @@
+// Package metrics records request counts, status classes and latency for an
+// app, in total and per route. Counters are spread over shards updated with
+// atomics only and are summed when read, so recording never serializes
+// concurrent requests.
+package metrics
+
+import (
+	"bufio"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/pathmatch"
+)
+
+type Config struct {
+	// 不统计的路径，例如健康检查；支持通配符、正则和方法限定，见pathmatch.Matcher
+	SkipPaths []string
+	// Connections 返回服务器的连接数，通常是app.Connections
+	Connections func() cyber.ConnectionStats
+	// Streams 返回各路由打开的长连接数，通常是app.Streams
+	Streams func() map[string]int
+	// HotRoutes和告警统计的滑动窗口，默认5分钟
+	HotWindow time.Duration
+	// 告警规则的检查间隔，默认15秒
+	AlertInterval time.Duration
+}
+
+// 延迟直方图的桶上界，最后一个桶没有上界
+var latencyBounds = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// shard 是一组计数器，填充到独占缓存行，避免不同CPU更新相邻分片时伪共享
+type shard struct {
+	requests     atomic.Uint64
+	clientClosed atomic.Uint64
+	// statusClasses[i] 是 (i+1)xx 响应的数量
+	statusClasses [5]atomic.Uint64
+	durationNanos atomic.Uint64
+	maxNanos      atomic.Uint64
+	buckets       [14]atomic.Uint64
+	_             [64]byte
+}
+
+// counters 是一组分片计数器，总计和每个路由各有一组
+type counters struct {
+	shards []shard
+	mask   uint32
+}
+
+func newCounters() *counters {
+	// 分片数取不小于GOMAXPROCS的2的幂，用掩码代替取模
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return &counters{shards: make([]shard, n), mask: uint32(n - 1)}
+}
+
+// Metrics aggregates request metrics. Use New to create one.
+type Metrics struct {
+	config    Config
+	total     *counters
+	routes    sync.Map // 路由 -> *counters
+	startTime time.Time
+	// middlewares 是各中间件自身的耗时，只记录次数和延迟
+	middlewares sync.Map // 中间件名称 -> *counters
+	skip        *pathmatch.Matcher
+	hotWindow   time.Duration
+	hot         hotHistory
+	// nextSample 是下次采样的时间（UnixNano）
+	nextSample atomic.Int64
+	alertsMu   sync.Mutex
+	alerts     []*alertRule
+	// alertsStop 在检查告警的goroutine运行时不为nil
+	alertsStop chan struct{}
+	// handlerRoutes 是提供Handler的路由，Middleware不统计它们，否则每次轮询都会改变ETag
+	handlerRoutes sync.Map // 路由 -> struct{}
+}
+
+// New creates the metrics. It panics if a SkipPaths pattern is invalid.
+func New(config Config) *Metrics {
+	hotWindow := config.HotWindow
+	if hotWindow <= 0 {
+		hotWindow = defaultHotWindow
+	}
+	return &Metrics{
+		config:    config,
+		total:     newCounters(),
+		startTime: time.Now(),
+		skip:      pathmatch.Must(config.SkipPaths...),
+		hotWindow: hotWindow,
+	}
+}
+
+// RecordRequest records one finished request. Requests whose client went
+// away are recorded with cyber.StatusClientClosedRequest.
+func (m *Metrics) RecordRequest(status int, duration time.Duration) {
+	m.maybeSample()
+	m.total.record(status, duration)
+}
+
+// RecordRoute records one finished request in the total and under route,
+// e.g. a Route.Key.
+func (m *Metrics) RecordRoute(route string, status int, duration time.Duration) {
+	m.maybeSample()
+	m.total.record(status, duration)
+	c, ok := m.routes.Load(route)
+	if !ok {
+		c, _ = m.routes.LoadOrStore(route, newCounters())
+	}
+	c.(*counters).record(status, duration)
+}
+
+// ObserveMiddleware is a cyber.MiddlewareObserver recording the time each
+// middleware spends on requests, e.g.
+// app.ObserveMiddlewares(m.ObserveMiddleware).
+func (m *Metrics) ObserveMiddleware(r *http.Request, name string, duration time.Duration) {
+	c, ok := m.middlewares.Load(name)
+	if !ok {
+		c, _ = m.middlewares.LoadOrStore(name, newCounters())
+	}
+	c.(*counters).record(0, duration)
+}
+
+func (c *counters) record(status int, duration time.Duration) {
+	s := &c.shards[rand.Uint32()&c.mask]
+	s.requests.Add(1)
+	if status == cyber.StatusClientClosedRequest {
+		s.clientClosed.Add(1)
+	} else if class := status/100 - 1; class >= 0 && class < len(s.statusClasses) {
+		s.statusClasses[class].Add(1)
+	}
+	nanos := uint64(duration)
+	s.durationNanos.Add(nanos)
+	for {
+		current := s.maxNanos.Load()
+		if nanos <= current || s.maxNanos.CompareAndSwap(current, nanos) {
+			break
+		}
+	}
+	s.buckets[bucketIndex(duration)].Add(1)
+}
+
+func bucketIndex(duration time.Duration) int {
+	for i, bound := range latencyBounds {
+		if duration <= bound {
+			return i
+		}
+	}
+	return len(latencyBounds)
+}
+
+// Middleware records every request passing through it, except SkipPaths.
+func (m *Metrics) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.skip.Match(r) {
+			next(w, r)
+			return
+		}
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		record := m.RecordRequest
+		key := ""
+		if route := cyber.CurrentRoute(r); route != nil {
+			key = route.Key()
+			record = func(status int, duration time.Duration) { m.RecordRoute(key, status, duration) }
+		}
+		defer func() {
+			if _, ok := m.handlerRoutes.Load(key); ok && key != "" {
+				return
+			}
+			if err := recover(); err != nil {
+				record(http.StatusInternalServerError, time.Since(start))
+				panic(err)
+			}
+			status := sw.Status()
+			if cyber.IsClientGone(r) {
+				status = cyber.StatusClientClosedRequest
+			}
+			record(status, time.Since(start))
+		}()
+		next(sw, r)
+	}
+}
+
+// SchemaVersion is the version of the Snapshot JSON format. It changes
+// only when fields are renamed or removed; new fields keep the version.
+const SchemaVersion = 1
+
+// Snapshot is the metrics JSON served by Handler. The request statistics
+// of all routes are inlined at the top level.
+type Snapshot struct {
+	SchemaVersion int   `json:"schema_version"`
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	RequestStats
+	// LatencyBoundsMs 是latency_buckets各桶的上界（毫秒），最后一个桶没有上界
+	LatencyBoundsMs []float64               `json:"latency_bounds_ms"`
+	Routes          map[string]RequestStats `json:"routes"`
+	Connections     *cyber.ConnectionStats  `json:"connections,omitempty"`
+	Streams         map[string]int          `json:"streams,omitempty"`
+	// Middlewares 在使用ObserveMiddleware时按中间件名称给出自身耗时，不含它调用的内层中间件和处理函数
+	Middlewares map[string]MiddlewareStats `json:"middlewares,omitempty"`
+}
+
+// MiddlewareStats is the time one middleware spent on requests, excluding
+// the middlewares and handler it called.
+type MiddlewareStats struct {
+	Calls        uint64  `json:"calls"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+	P50LatencyMs float64 `json:"p50_latency_ms"`
+	P90LatencyMs float64 `json:"p90_latency_ms"`
+	P99LatencyMs float64 `json:"p99_latency_ms"`
+}
+
+// RequestStats are the request counters of the app or of one route.
+type RequestStats struct {
+	Requests     uint64 `json:"requests"`
+	ClientClosed uint64 `json:"client_closed"`
+	// Status 按状态码类别计数，键为 1xx 到 5xx
+	Status         map[string]uint64 `json:"status"`
+	AvgLatencyMs   float64           `json:"avg_latency_ms"`
+	MaxLatencyMs   float64           `json:"max_latency_ms"`
+	P50LatencyMs   float64           `json:"p50_latency_ms"`
+	P90LatencyMs   float64           `json:"p90_latency_ms"`
+	P99LatencyMs   float64           `json:"p99_latency_ms"`
+	LatencyBuckets []uint64          `json:"latency_buckets"`
+}
+
+// Snapshot sums the shards. Counters are read one by one while requests
+// keep being recorded, so totals may be off by in-flight requests.
+func (m *Metrics) Snapshot() Snapshot {
+	bounds := make([]float64, len(latencyBounds))
+	for i, bound := range latencyBounds {
+		bounds[i] = durationMs(bound)
+	}
+	snapshot := Snapshot{
+		SchemaVersion:   SchemaVersion,
+		UptimeSeconds:   int64(time.Since(m.startTime).Seconds()),
+		RequestStats:    m.total.stats(),
+		LatencyBoundsMs: bounds,
+		Routes:          make(map[string]RequestStats),
+	}
+	m.routes.Range(func(route, c interface{}) bool {
+		snapshot.Routes[route.(string)] = c.(*counters).stats()
+		return true
+	})
+	m.middlewares.Range(func(name, c interface{}) bool {
+		if snapshot.Middlewares == nil {
+			snapshot.Middlewares = make(map[string]MiddlewareStats)
+		}
+		stats := c.(*counters).stats()
+		snapshot.Middlewares[name.(string)] = MiddlewareStats{
+			Calls:        stats.Requests,
+			AvgLatencyMs: stats.AvgLatencyMs,
+			MaxLatencyMs: stats.MaxLatencyMs,
+			P50LatencyMs: stats.P50LatencyMs,
+			P90LatencyMs: stats.P90LatencyMs,
+			P99LatencyMs: stats.P99LatencyMs,
+		}
+		return true
+	})
+	if m.config.Connections != nil {
+		connections := m.config.Connections()
+		snapshot.Connections = &connections
+	}
+	if m.config.Streams != nil {
+		snapshot.Streams = m.config.Streams()
+	}
+	return snapshot
+}
+
+func (c *counters) stats() RequestStats {
+	var requests, clientClosed, durationNanos, maxNanos uint64
+	var classes [5]uint64
+	buckets := make([]uint64, len(latencyBounds)+1)
+	for i := range c.shards {
+		s := &c.shards[i]
+		requests += s.requests.Load()
+		clientClosed += s.clientClosed.Load()
+		durationNanos += s.durationNanos.Load()
+		if n := s.maxNanos.Load(); n > maxNanos {
+			maxNanos = n
+		}
+		for j := range classes {
+			classes[j] += s.statusClasses[j].Load()
+		}
+		for j := range buckets {
+			buckets[j] += s.buckets[j].Load()
+		}
+	}
+	statuses := make(map[string]uint64, len(classes))
+	for i, count := range classes {
+		statuses[string(rune('1'+i))+"xx"] = count
+	}
+	var avgMs float64
+	if requests > 0 {
+		avgMs = durationMs(time.Duration(durationNanos / requests))
+	}
+	return RequestStats{
+		Requests:       requests,
+		ClientClosed:   clientClosed,
+		Status:         statuses,
+		AvgLatencyMs:   avgMs,
+		MaxLatencyMs:   durationMs(time.Duration(maxNanos)),
+		P50LatencyMs:   durationMs(percentile(buckets, requests, 0.50)),
+		P90LatencyMs:   durationMs(percentile(buckets, requests, 0.90)),
+		P99LatencyMs:   durationMs(percentile(buckets, requests, 0.99)),
+		LatencyBuckets: buckets,
+	}
+}
+
+// percentile 返回累计数达到q的桶的上界，落在最后一个桶时返回最大的上界
+func percentile(buckets []uint64, total uint64, q float64) time.Duration {
+	if total == 0 {
+		return 0
+	}
+	target := uint64(float64(total)*q + 0.5)
+	var cumulative uint64
+	for i, count := range buckets {
+		cumulative += count
+		if cumulative >= target && i < len(latencyBounds) {
+			return latencyBounds[i]
+		}
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// Handler serves the snapshot as JSON, e.g. app.Get("/metrics", m.Handler).
+// It is gzipped when the client asks for it, and pollers sending the ETag
+// back in If-None-Match get 304 Not Modified while no counter changed.
+// Middleware doesn't count requests to the route serving Handler, since
+// each poll would otherwise change the counters it reports.
+func (m *Metrics) Handler(w http.ResponseWriter, r *http.Request) {
+	if route := cyber.CurrentRoute(r); route != nil {
+		m.handlerRoutes.Store(route.Key(), struct{}{})
+	}
+	snapshot := m.Snapshot()
+	// 运行时间每秒都在变化，不计入ETag，否则轮询永远得不到304
+	uptime := snapshot.UptimeSeconds
+	snapshot.UptimeSeconds = 0
+	if etag, err := cyber.JSONETag(snapshot); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+	snapshot.UptimeSeconds = uptime
+	cyber.ServeJSON(w, r, snapshot)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// statusWriter 透传写入，同时记录响应状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.status == 0 {
+		sw.status = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sw *statusWriter) Status() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+// Hijack 支持WebSocket等协议升级
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if sw.status == 0 {
+		sw.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
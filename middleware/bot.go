@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type BotAction int
+
+const (
+	// BotTag 只记录评分，交给后续handler处理
+	BotTag BotAction = iota
+	// BotChallenge 调用ChallengeFunc要求客户端完成验证
+	BotChallenge
+	// BotBlock 直接返回403
+	BotBlock
+)
+
+type BotConfig struct {
+	// User-Agent中出现即判定为可疑的关键字，不区分大小写
+	BadUserAgents []string
+	// 正常用户不会访问的诱饵路径。这些路径通常没有注册路由，只有通过BotDetectionHandler
+	// 包装整个应用时才能检测到，作为路由中间件时只对注册了的路径生效
+	HoneypotPaths []string
+	// 评分达到Threshold时执行Action
+	Threshold int
+	Action    BotAction
+	// ChallengeFunc 在Action为BotChallenge时调用
+	ChallengeFunc http.HandlerFunc
+	// Policy 不为nil时替代Action，返回false表示请求已被处理
+	Policy func(w http.ResponseWriter, r *http.Request, score int) bool
+}
+
+var defaultBotConfig = BotConfig{
+	BadUserAgents: []string{"sqlmap", "nikto", "masscan", "zgrab", "python-requests", "curl", "wget", "scrapy"},
+	HoneypotPaths: []string{"/wp-login.php", "/wp-admin", "/.env", "/phpmyadmin"},
+	Threshold:     60,
+	Action:        BotTag,
+}
+
+type botScoreKey struct{}
+
+// BotScore returns the score assigned by BotDetection, from 0 (likely human)
+// to 100 (certainly a bot).
+func BotScore(r *http.Request) int {
+	score, _ := r.Context().Value(botScoreKey{}).(int)
+	return score
+}
+
+func BotDetectionMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return BotDetection(defaultBotConfig)(next)
+}
+
+// BotDetection scores requests to registered routes. Honeypot paths only
+// match when they are routes; use BotDetectionHandler to catch scanners
+// probing paths the app doesn't serve.
+func BotDetection(config BotConfig) func(http.HandlerFunc) http.HandlerFunc {
+	check := botCheck(config)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			check(w, r, next)
+		}
+	}
+}
+
+// BotDetectionHandler is BotDetection for the whole app, including requests
+// that match no route, so the honeypot paths work without being registered:
+//
+//	app.UseHandler(middleware.BotDetectionHandler(config))
+func BotDetectionHandler(config BotConfig) func(http.Handler) http.Handler {
+	check := botCheck(config)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			check(w, r, next.ServeHTTP)
+		})
+	}
+}
+
+func botCheck(config BotConfig) func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if config.ChallengeFunc == nil {
+		config.ChallengeFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Bot-Challenge", "required")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		score := botScore(config, r)
+		r = r.WithContext(context.WithValue(r.Context(), botScoreKey{}, score))
+		if config.Policy != nil {
+			if config.Policy(w, r, score) {
+				next(w, r)
+			}
+			return
+		}
+		if config.Threshold > 0 && score >= config.Threshold {
+			switch config.Action {
+			case BotBlock:
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			case BotChallenge:
+				config.ChallengeFunc(w, r)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func botScore(config BotConfig, r *http.Request) int {
+	for _, path := range config.HoneypotPaths {
+		if r.URL.Path == path {
+			return 100
+		}
+	}
+	score := 0
+	userAgent := strings.ToLower(r.UserAgent())
+	if userAgent == "" {
+		score += 40
+	} else {
+		for _, bad := range config.BadUserAgents {
+			if strings.Contains(userAgent, strings.ToLower(bad)) {
+				score += 60
+				break
+			}
+		}
+	}
+	if r.Header.Get("Accept") == "" {
+		score += 20
+	}
+	if r.Header.Get("Accept-Language") == "" {
+		score += 10
+	}
+	if r.Header.Get("Accept-Encoding") == "" {
+		score += 10
+	}
+	if score > 100 {
+		score = 100
+	}
+	return score
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/pathmatch"
+)
+
+type DumpConfig struct {
+	// 只转储匹配的请求，支持通配符、正则和方法限定，见pathmatch.Matcher；为空时不限制
+	Paths []string
+	// 只转储带有该请求头的请求，例如 X-Debug-Dump
+	Header string
+	// 采样比例，0-1，为0时转储所有匹配的请求
+	SampleRate float64
+	// Filter 不为nil时作为额外的匹配条件
+	Filter func(r *http.Request) bool
+	// 请求体和响应体各自的最大转储字节数
+	MaxBodyBytes int64
+	// 需要隐藏的请求头、查询参数和请求体/响应体字段，各项为nil时使用默认值
+	Redact cyber.Redaction
+	// Output 接收每条转储，默认写入标准日志
+	Output func(dump string)
+}
+
+var defaultDumpConfig = DumpConfig{
+	Header:       "X-Debug-Dump",
+	MaxBodyBytes: 16 << 10,
+	Redact: cyber.Redaction{
+		Headers: []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"},
+		Query:   []string{"access_token", "id_token", "refresh_token", "code", "token", "client_secret"},
+		Fields:  []string{"password", "access_token", "id_token", "refresh_token", "token", "client_secret", "secret"},
+	},
+}
+
+func RequestDumpMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return RequestDump(defaultDumpConfig)(next)
+}
+
+// RequestDump logs full request and response dumps for matching requests.
+// It is meant for staging; bodies are capped, and credential headers, query
+// parameters and JSON or form body fields are redacted.
+func RequestDump(config DumpConfig) func(http.HandlerFunc) http.HandlerFunc {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultDumpConfig.MaxBodyBytes
+	}
+	if config.Redact.Headers == nil {
+		config.Redact.Headers = defaultDumpConfig.Redact.Headers
+	}
+	if config.Redact.Query == nil {
+		config.Redact.Query = defaultDumpConfig.Redact.Query
+	}
+	if config.Redact.Fields == nil {
+		config.Redact.Fields = defaultDumpConfig.Redact.Fields
+	}
+	if config.Output == nil {
+		config.Output = func(dump string) { log.Print(dump) }
+	}
+	var paths *pathmatch.Matcher
+	if len(config.Paths) > 0 {
+		paths = pathmatch.Must(config.Paths...)
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !dumpMatches(config, paths, r) {
+				next(w, r)
+				return
+			}
+			reqDump, err := cyber.DumpRequestRedacted(r, true, config.MaxBodyBytes, config.Redact)
+			if err != nil {
+				log.Printf("Error dumping request: %v", err)
+			}
+			tw := &teeWriter{ResponseWriter: w, limit: config.MaxBodyBytes}
+			defer func() {
+				config.Output(fmt.Sprintf("--- request\n%s\n--- response\n%s", reqDump, dumpResponse(tw, config)))
+			}()
+			next(tw, r)
+		}
+	}
+}
+
+// dumpMatches 判断请求是否需要转储，paths 为nil时不限制路径
+func dumpMatches(config DumpConfig, paths *pathmatch.Matcher, r *http.Request) bool {
+	if config.Header != "" && r.Header.Get(config.Header) == "" {
+		return false
+	}
+	if paths != nil && !paths.Match(r) {
+		return false
+	}
+	if config.Filter != nil && !config.Filter(r) {
+		return false
+	}
+	return config.SampleRate <= 0 || rand.Float64() < config.SampleRate
+}
+
+func dumpResponse(tw *teeWriter, config DumpConfig) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP/1.1 %d %s\r\n", tw.Status(), http.StatusText(tw.Status()))
+	config.Redact.Header(tw.Header()).Write(&b)
+	b.WriteString("\r\n")
+	truncated := tw.written > int64(tw.body.Len())
+	b.Write(config.Redact.Body(tw.Header().Get("Content-Type"), tw.body.Bytes(), truncated))
+	if truncated {
+		fmt.Fprintf(&b, "\n[truncated at %d bytes]", config.MaxBodyBytes)
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/pathmatch"
+)
+
+type HTTPSConfig struct {
+	// Reject 为true时HTTP请求一律返回403，否则GET和HEAD重定向到HTTPS；其他方法的请求体已经明文发出，总是拒绝
+	Reject bool
+	// HTTPSPort 是重定向的目标端口，为空时使用443
+	HTTPSPort string
+	// HSTSMaxAge 不为0时在HTTPS响应中设置Strict-Transport-Security，浏览器此后直接使用HTTPS
+	HSTSMaxAge time.Duration
+	// 允许HTTP访问的路径，例如负载均衡器的健康检查；支持通配符、正则和方法限定，见pathmatch.Matcher
+	SkipPaths []string
+}
+
+func RequireHTTPSMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return RequireHTTPS(HTTPSConfig{})(next)
+}
+
+// RequireHTTPS only lets requests the client sent over HTTPS through, as
+// reported by cyber.IsTLS: behind a load balancer terminating TLS, declare
+// it with app.TrustProxies so the protocol header it sets is honored.
+func RequireHTTPS(config HTTPSConfig) func(http.HandlerFunc) http.HandlerFunc {
+	skip := pathmatch.Must(config.SkipPaths...)
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge.Seconds()), 10)
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if cyber.IsTLS(r) {
+				if hsts != "" {
+					w.Header().Set("Strict-Transport-Security", hsts)
+				}
+				next(w, r)
+				return
+			}
+			if skip.Match(r) {
+				next(w, r)
+				return
+			}
+			if config.Reject || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				http.Error(w, "HTTPS Required", http.StatusForbidden)
+				return
+			}
+			http.Redirect(w, r, httpsURL(r, config.HTTPSPort), http.StatusMovedPermanently)
+		}
+	}
+}
+
+func httpsURL(r *http.Request, port string) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+		// IPv6地址去掉端口后需要重新加上方括号
+		host = "[" + host + "]"
+	}
+	return "https://" + host + r.URL.RequestURI()
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+type RequestLimitConfig struct {
+	// 请求头字段的最大数量
+	MaxHeaders int
+	// Cookie的最大数量
+	MaxCookies int
+	// 请求URI的最大长度
+	MaxURLLength int
+}
+
+var defaultRequestLimitConfig = RequestLimitConfig{
+	MaxHeaders:   100,
+	MaxCookies:   50,
+	MaxURLLength: 8192,
+}
+
+func RequestLimitsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return RequestLimits(defaultRequestLimitConfig)(next)
+}
+
+// RequestLimits rejects requests with too many headers or cookies (431) or an
+// overly long URI (414). A zero limit disables that check.
+func RequestLimits(config RequestLimitConfig) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if config.MaxURLLength > 0 && len(r.RequestURI) > config.MaxURLLength {
+				http.Error(w, "URI Too Long", http.StatusRequestURITooLong)
+				return
+			}
+			if config.MaxHeaders > 0 {
+				count := 0
+				for _, values := range r.Header {
+					count += len(values)
+				}
+				if count > config.MaxHeaders {
+					http.Error(w, "Request Header Fields Too Large", http.StatusRequestHeaderFieldsTooLarge)
+					return
+				}
+			}
+			if config.MaxCookies > 0 && len(r.Cookies()) > config.MaxCookies {
+				http.Error(w, "Request Header Fields Too Large", http.StatusRequestHeaderFieldsTooLarge)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -1,42 +1,127 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/logger"
+	"github.com/suonanjiexi/cyber/pathmatch"
 )
 
+type LoggerConfig struct {
+	// 不记录日志的路径，支持通配符、正则和方法限定，见pathmatch.Matcher
+	SkipPaths []string
+	// NotFoundLogger 记录404和405响应，扫描器探测路径时它们会大量出现；为nil时默认每秒最多一条
+	NotFoundLogger *logger.Logger
+}
+
+var defaultLoggerConfig = LoggerConfig{
+	SkipPaths:      []string{"/favicon.ico"},
+	NotFoundLogger: logger.Every(time.Second),
+}
+
+var defaultLoggerSkip = pathmatch.Must(defaultLoggerConfig.SkipPaths...)
+
 func Logger(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ignorePaths := []string{"/favicon.ico"}
-		requestPath := r.URL.Path
-		isIgnored := false
-		for _, path := range ignorePaths {
-			if requestPath == path {
-				isIgnored = true
-				break
+	return logRequests(defaultLoggerSkip, defaultLoggerConfig.NotFoundLogger, next)
+}
+
+// RequestLogger is Logger with its own skip list, e.g. to keep health
+// checks and /internal/* out of the log.
+func RequestLogger(config LoggerConfig) func(http.HandlerFunc) http.HandlerFunc {
+	skip := pathmatch.Must(config.SkipPaths...)
+	notFound := config.NotFoundLogger
+	if notFound == nil {
+		notFound = logger.Every(time.Second)
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return logRequests(skip, notFound, next)
+	}
+}
+
+// LoggerHandler logs the requests that match no route, such as scanners
+// probing /wp-login.php, which the ServeMux answers without running any
+// route middleware. Their 404 and 405 responses go through
+// NotFoundLogger, so a flood can't turn into a log storm. Requests to
+// routes using Logger or RequestLogger are left to them:
+//
+//	app.UseHandler(middleware.LoggerHandler(middleware.LoggerConfig{}))
+func LoggerHandler(config LoggerConfig) func(http.Handler) http.Handler {
+	skip := pathmatch.Must(config.SkipPaths...)
+	notFound := config.NotFoundLogger
+	if notFound == nil {
+		notFound = logger.Every(time.Second)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip.Match(r) {
+				next.ServeHTTP(w, r)
+				return
 			}
-		}
-		// 如果请求不是被忽略的路径，则进行日志记录
-		if !isIgnored {
+			handled := new(bool)
 			startTime := time.Now()
-			defer logRequestDuration(startTime, r)
+			sw := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				if !*handled {
+					logStatus(notFound, startTime, r, responseStatus(sw, r))
+				}
+			}()
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), handledKey{}, handled)))
+		})
+	}
+}
+
+// handledKey 对应的*bool由路由上的Logger置为true，表示请求已由它记录或忽略，LoggerHandler不再记录
+type handledKey struct{}
+
+func logRequests(skip *pathmatch.Matcher, notFound *logger.Logger, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if handled, _ := r.Context().Value(handledKey{}).(*bool); handled != nil {
+			*handled = true
+		}
+		// 被忽略的路径不记录日志
+		if skip.Match(r) {
+			next(w, r)
+			return
 		}
-		// 捕获并处理next函数可能引发的panic
+		startTime := time.Now()
+		sw := &statusRecorder{ResponseWriter: w}
 		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("Recovered from panic: %v", err)
-			}
+			logStatus(notFound, startTime, r, responseStatus(sw, r))
 		}()
-		next(w, r)
+		next(sw, r)
 	}
 }
 
-func logRequestDuration(startTime time.Time, r *http.Request) {
+// logStatus 记录请求，404和405响应通过notFound限制频率
+func logStatus(notFound *logger.Logger, startTime time.Time, r *http.Request, status int) {
+	printf := log.Printf
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		printf = notFound.Printf
+	}
+	logRequestDuration(printf, startTime, r, status)
+}
+
+func logRequestDuration(printf func(format string, v ...interface{}), startTime time.Time, r *http.Request, status int) {
 	duration := time.Since(startTime)
 	durationStr := formatDuration(duration)
-	log.Printf("Duration: %s - Request: %s %s", durationStr, r.Method, r.URL.Path)
+	if variant := cyber.SplitVariant(r); variant != "" {
+		printf("Duration: %s - Status: %d - Request: %s %s - Variant: %s", durationStr, status, r.Method, r.URL.Path, variant)
+		return
+	}
+	printf("Duration: %s - Status: %d - Request: %s %s", durationStr, status, r.Method, r.URL.Path)
+}
+
+// responseStatus 客户端已断开时返回499，而不是handler写出的（或默认的）状态码
+func responseStatus(sw *statusRecorder, r *http.Request) int {
+	if cyber.IsClientGone(r) {
+		return cyber.StatusClientClosedRequest
+	}
+	return sw.Status()
 }
 
 func formatDuration(duration time.Duration) string {
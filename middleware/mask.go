@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type MaskMode int
+
+const (
+	// MaskRedact 整个字段值替换为 "***"
+	MaskRedact MaskMode = iota
+	// MaskPartial 仅保留字符串末尾4个字符，其余替换为 '*'
+	MaskPartial
+)
+
+type MaskRule struct {
+	// JSON字段名，在任意嵌套层级匹配
+	Field string
+	Mode  MaskMode
+	// 可以看到原始值的角色
+	AllowRoles []string
+}
+
+type MaskConfig struct {
+	Rules []MaskRule
+	// RoleFunc 返回调用方的角色，通常取自JWT claims；为nil或返回空串时所有规则生效
+	RoleFunc func(r *http.Request) string
+}
+
+// MaskFields returns a middleware that masks the configured JSON response
+// fields that the caller's role is not allowed to see. JSON bodies are
+// masked as they are written, one value at a time, so large responses and
+// JSON streams aren't held in memory; other responses, such as server-sent
+// events, pass through unchanged. If a JSON body turns out to be malformed,
+// the rest of it is dropped rather than sent unmasked.
+func MaskFields(config MaskConfig) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			role := ""
+			if config.RoleFunc != nil {
+				role = config.RoleFunc(r)
+			}
+			fields := activeMaskFields(config.Rules, role)
+			if len(fields) == 0 {
+				next(w, r)
+				return
+			}
+			mw := &maskWriter{ResponseWriter: w, fields: fields}
+			defer mw.close()
+			next(mw, r)
+		}
+	}
+}
+
+// maskWriter 在写出响应头时根据Content-Type决定是否脱敏：JSON响应通过管道交给
+// 脱敏goroutine边读边写，其他响应直接透传
+type maskWriter struct {
+	http.ResponseWriter
+	fields      map[string]MaskMode
+	wroteHeader bool
+	// pipe 不为nil时响应体写入管道
+	pipe *io.PipeWriter
+	done chan struct{}
+	// mu 串行化脱敏goroutine的写出和handler的Flush
+	mu sync.Mutex
+}
+
+func (mw *maskWriter) WriteHeader(statusCode int) {
+	if mw.wroteHeader {
+		return
+	}
+	mw.wroteHeader = true
+	if strings.Contains(mw.Header().Get("Content-Type"), "json") {
+		mw.Header().Del("Content-Length")
+		pr, pw := io.Pipe()
+		mw.pipe, mw.done = pw, make(chan struct{})
+		go mw.mask(pr)
+	}
+	mw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (mw *maskWriter) Write(b []byte) (int, error) {
+	if !mw.wroteHeader {
+		mw.WriteHeader(http.StatusOK)
+	}
+	if mw.pipe == nil {
+		return mw.ResponseWriter.Write(b)
+	}
+	return mw.pipe.Write(b)
+}
+
+func (mw *maskWriter) Flush() {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	if flusher, ok := mw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close 在handler返回后等待脱敏goroutine写完响应
+func (mw *maskWriter) close() {
+	if mw.pipe != nil {
+		mw.pipe.Close()
+		<-mw.done
+	}
+}
+
+// mask 逐个读取JSON值并写出脱敏后的结果，每个值后换行，支持NDJSON等JSON流
+func (mw *maskWriter) mask(pr *io.PipeReader) {
+	defer close(mw.done)
+	out := bufio.NewWriter(writerFunc(func(b []byte) (int, error) {
+		mw.mu.Lock()
+		defer mw.mu.Unlock()
+		return mw.ResponseWriter.Write(b)
+	}))
+	masker := &jsonMasker{fields: mw.fields, dec: json.NewDecoder(pr), out: out}
+	masker.dec.UseNumber()
+	for {
+		err := masker.value()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error masking response fields: %v", err)
+			// 不再读取，handler后续的写入返回错误而不是阻塞
+			pr.CloseWithError(err)
+			break
+		}
+		out.WriteByte('\n')
+		out.Flush()
+	}
+	out.Flush()
+}
+
+// writerFunc 把函数适配为io.Writer
+type writerFunc func(b []byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
+
+func activeMaskFields(rules []MaskRule, role string) map[string]MaskMode {
+	fields := make(map[string]MaskMode, len(rules))
+	for _, rule := range rules {
+		allowed := false
+		for _, allowRole := range rule.AllowRoles {
+			if role != "" && allowRole == role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			fields[rule.Field] = rule.Mode
+		}
+	}
+	return fields
+}
+
+// jsonMasker 逐个token读取JSON并写出，遇到需要脱敏的字段时替换其值
+type jsonMasker struct {
+	fields map[string]MaskMode
+	dec    *json.Decoder
+	out    *bufio.Writer
+}
+
+func (m *jsonMasker) value() error {
+	tok, err := m.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			return m.object()
+		}
+		return m.array()
+	case string:
+		return m.writeString(t)
+	case json.Number:
+		_, err = m.out.WriteString(t.String())
+	case bool:
+		if t {
+			_, err = m.out.WriteString("true")
+		} else {
+			_, err = m.out.WriteString("false")
+		}
+	case nil:
+		_, err = m.out.WriteString("null")
+	}
+	return err
+}
+
+func (m *jsonMasker) object() error {
+	m.out.WriteByte('{')
+	for first := true; m.dec.More(); first = false {
+		tok, err := m.dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		if !first {
+			m.out.WriteByte(',')
+		}
+		if err := m.writeString(key); err != nil {
+			return err
+		}
+		m.out.WriteByte(':')
+		if mode, ok := m.fields[key]; ok {
+			err = m.masked(mode)
+		} else {
+			err = m.value()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := m.dec.Token(); err != nil {
+		return err
+	}
+	return m.out.WriteByte('}')
+}
+
+func (m *jsonMasker) array() error {
+	m.out.WriteByte('[')
+	for first := true; m.dec.More(); first = false {
+		if !first {
+			m.out.WriteByte(',')
+		}
+		if err := m.value(); err != nil {
+			return err
+		}
+	}
+	if _, err := m.dec.Token(); err != nil {
+		return err
+	}
+	return m.out.WriteByte(']')
+}
+
+func (m *jsonMasker) masked(mode MaskMode) error {
+	var raw json.RawMessage
+	if err := m.dec.Decode(&raw); err != nil {
+		return err
+	}
+	var s string
+	if mode == MaskPartial && json.Unmarshal(raw, &s) == nil {
+		return m.writeString(maskPartial(s))
+	}
+	return m.writeString("***")
+}
+
+func (m *jsonMasker) writeString(s string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = m.out.Write(b)
+	return err
+}
+
+func maskPartial(s string) string {
+	const keep = 4
+	n := utf8.RuneCountInString(s)
+	if n <= keep {
+		return strings.Repeat("*", n)
+	}
+	runes := []rune(s)
+	return strings.Repeat("*", n-keep) + string(runes[n-keep:])
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+type PriorityConfig struct {
+	// Classify 返回请求的优先级类别，默认读取路由元数据中的 "priority"
+	Classify func(r *http.Request) string
+	// Weights 是各类别的调度权重，未列出的类别按DefaultClass处理
+	Weights map[string]int
+	// 未分类请求所属的类别
+	DefaultClass string
+	// 同时处理的请求数，超过后请求进入所属类别的队列
+	MaxConcurrent int
+	// 每个类别队列的最大长度，队列已满时返回503
+	MaxQueue int
+	// 请求在队列中等待的最长时间，超时返回503
+	QueueTimeout time.Duration
+}
+
+// MetaPriority is the route metadata key read by the default classifier,
+// e.g. app.Route("GET", "/health").Meta = map[string]string{"priority": "high"}.
+const MetaPriority = "priority"
+
+var defaultPriorityConfig = PriorityConfig{
+	Weights:       map[string]int{"high": 8, "normal": 4, "low": 1},
+	DefaultClass:  "normal",
+	MaxConcurrent: 64,
+	MaxQueue:      1000,
+	QueueTimeout:  5 * time.Second,
+}
+
+func PriorityMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return Priority(defaultPriorityConfig)(next)
+}
+
+// Priority limits concurrent requests and, once MaxConcurrent are running,
+// admits queued requests by smooth weighted round robin over the classes,
+// so a flood of low priority requests can't starve high priority ones.
+// Use it as an app middleware so the limit covers every route.
+func Priority(config PriorityConfig) func(http.HandlerFunc) http.HandlerFunc {
+	if len(config.Weights) == 0 {
+		config.Weights = defaultPriorityConfig.Weights
+	}
+	if config.DefaultClass == "" {
+		config.DefaultClass = defaultPriorityConfig.DefaultClass
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = defaultPriorityConfig.MaxConcurrent
+	}
+	if config.MaxQueue <= 0 {
+		config.MaxQueue = defaultPriorityConfig.MaxQueue
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = defaultPriorityConfig.QueueTimeout
+	}
+	if config.Classify == nil {
+		config.Classify = routePriority
+	}
+	if _, ok := config.Weights[config.DefaultClass]; !ok {
+		weights := map[string]int{config.DefaultClass: 1}
+		for class, weight := range config.Weights {
+			weights[class] = weight
+		}
+		config.Weights = weights
+	}
+	s := newPriorityScheduler(config)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			class := config.Classify(r)
+			if _, ok := config.Weights[class]; !ok {
+				class = config.DefaultClass
+			}
+			if !s.acquire(r, class) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			defer s.release()
+			next(w, r)
+		}
+	}
+}
+
+func routePriority(r *http.Request) string {
+	if route := cyber.CurrentRoute(r); route != nil {
+		return route.Meta[MetaPriority]
+	}
+	return ""
+}
+
+// priorityScheduler 维护正在处理的请求数和各类别的等待队列
+type priorityScheduler struct {
+	config  PriorityConfig
+	classes []string
+	mu      sync.Mutex
+	running int
+	queues  map[string][]chan struct{}
+	// current 是平滑加权轮询中各类别的当前权重
+	current map[string]int
+}
+
+func newPriorityScheduler(config PriorityConfig) *priorityScheduler {
+	s := &priorityScheduler{config: config, queues: make(map[string][]chan struct{}), current: make(map[string]int)}
+	for class := range config.Weights {
+		s.classes = append(s.classes, class)
+	}
+	sort.Strings(s.classes)
+	return s
+}
+
+// acquire 获取处理名额，排队超时、队列已满或客户端断开时返回false
+func (s *priorityScheduler) acquire(r *http.Request, class string) bool {
+	s.mu.Lock()
+	if s.running < s.config.MaxConcurrent && s.waiting() == 0 {
+		s.running++
+		s.mu.Unlock()
+		return true
+	}
+	if len(s.queues[class]) >= s.config.MaxQueue {
+		s.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	s.queues[class] = append(s.queues[class], ready)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.config.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.queues[class]
+	for i, ch := range queue {
+		if ch == ready {
+			s.queues[class] = append(queue[:i:i], queue[i+1:]...)
+			return false
+		}
+	}
+	// 放弃等待的同时已被调度，把名额交给下一个请求
+	s.handOff()
+	return false
+}
+
+func (s *priorityScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handOff()
+}
+
+// handOff 把一个处理名额交给加权轮询选出的等待请求，没有等待请求时归还名额。调用方需持有s.mu
+func (s *priorityScheduler) handOff() {
+	total, best := 0, ""
+	for _, class := range s.classes {
+		if len(s.queues[class]) == 0 {
+			continue
+		}
+		weight := s.config.Weights[class]
+		s.current[class] += weight
+		total += weight
+		if best == "" || s.current[class] > s.current[best] {
+			best = class
+		}
+	}
+	if best == "" {
+		s.running--
+		return
+	}
+	s.current[best] -= total
+	next := s.queues[best][0]
+	s.queues[best] = s.queues[best][1:]
+	close(next)
+}
+
+func (s *priorityScheduler) waiting() int {
+	n := 0
+	for _, queue := range s.queues {
+		n += len(queue)
+	}
+	return n
+}
@@ -1,16 +1,16 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
+
+	"github.com/suonanjiexi/cyber"
 )
 
 func Recovery(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("panic: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				cyber.RenderPanic(w, r, err)
 			}
 		}()
 		next(w, r)
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+type RecordedPhase struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type RecordedRequest struct {
+	ID         uint64          `json:"id"`
+	Time       time.Time       `json:"time"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	Status     int             `json:"status"`
+	Variant    string          `json:"variant,omitempty"`
+	DurationMs float64         `json:"duration_ms"`
+	Phases     []RecordedPhase `json:"phases,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// RequestRecorder keeps the last N requests in a ring buffer, a lightweight
+// built-in request waterfall intended for debug mode.
+type RequestRecorder struct {
+	mu      sync.Mutex
+	entries []RecordedRequest
+	next    int
+	full    bool
+	seq     uint64
+}
+
+func NewRequestRecorder(size int) *RequestRecorder {
+	if size <= 0 {
+		size = 100
+	}
+	return &RequestRecorder{entries: make([]RecordedRequest, size)}
+}
+
+// Middleware records every request passing through it. Phase timings come
+// from cyber.StartTiming/cyber.AddTiming.
+func (rr *RequestRecorder) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusRecorder{ResponseWriter: w}
+		entry := RecordedRequest{Time: start, Method: r.Method, Path: r.URL.Path}
+		defer func() {
+			if err := recover(); err != nil {
+				entry.Error = fmt.Sprintf("panic: %v", err)
+				entry.Status = http.StatusInternalServerError
+				rr.finish(entry, r, start)
+				panic(err)
+			}
+			entry.Status = responseStatus(sw, r)
+			if entry.Status == cyber.StatusClientClosedRequest {
+				entry.Error = "client closed request"
+			} else if entry.Status >= http.StatusInternalServerError {
+				entry.Error = http.StatusText(entry.Status)
+			}
+			rr.finish(entry, r, start)
+		}()
+		next(sw, r)
+	}
+}
+
+func (rr *RequestRecorder) finish(entry RecordedRequest, r *http.Request, start time.Time) {
+	entry.DurationMs = durationMs(time.Since(start))
+	entry.Variant = cyber.SplitVariant(r)
+	for _, t := range cyber.Timings(r) {
+		entry.Phases = append(entry.Phases, RecordedPhase{Name: t.Name, DurationMs: durationMs(t.Duration)})
+	}
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.seq++
+	entry.ID = rr.seq
+	rr.entries[rr.next] = entry
+	rr.next = (rr.next + 1) % len(rr.entries)
+	if rr.next == 0 {
+		rr.full = true
+	}
+}
+
+// Requests returns the recorded requests, newest first.
+func (rr *RequestRecorder) Requests() []RecordedRequest {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	count := rr.next
+	if rr.full {
+		count = len(rr.entries)
+	}
+	result := make([]RecordedRequest, 0, count)
+	for i := 1; i <= count; i++ {
+		result = append(result, rr.entries[(rr.next-i+len(rr.entries))%len(rr.entries)])
+	}
+	return result
+}
+
+var requestsTemplate = template.Must(template.New("requests").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Recent requests</title>
+<style>body{font-family:monospace}td,th{padding:2px 8px;text-align:left}.err{color:#c00}</style></head>
+<body><h3>Recent requests</h3><table>
+<tr><th>#</th><th>time</th><th>method</th><th>path</th><th>status</th><th>ms</th><th>phases</th><th>error</th></tr>
+{{range .}}<tr><td>{{.ID}}</td><td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Method}}</td><td>{{.Path}}</td>
+<td>{{.Status}}</td><td>{{printf "%.2f" .DurationMs}}</td>
+<td>{{range .Phases}}<span title="{{.Name}} {{printf "%.2f" .DurationMs}}ms">{{.Name}} {{printf "%.2f" .DurationMs}}ms</span> {{end}}</td>
+<td class="err">{{.Error}}</td></tr>{{end}}
+</table></body></html>`))
+
+// Handler serves the recorded requests as JSON or, for browsers, HTML.
+// Register it only in debug mode, e.g. app.Get("/debug/requests", rr.Handler).
+func (rr *RequestRecorder) Handler(w http.ResponseWriter, r *http.Request) {
+	requests := rr.Requests()
+	if cyber.Accepts(r, "application/json", "text/html") == "text/html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		requestsTemplate.Execute(w, requests)
+		return
+	}
+	cyber.ServeJSON(w, r, requests)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
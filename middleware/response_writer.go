@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// bufferedWriter 缓存下游handler写出的状态码和响应体，由中间件在处理后统一写回
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	// streaming 表示handler调用过Flush，之后的写入直接透传
+	streaming bool
+}
+
+func newBufferedWriter(w http.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{ResponseWriter: w}
+}
+
+func (bw *bufferedWriter) WriteHeader(statusCode int) {
+	if bw.status == 0 {
+		bw.status = statusCode
+		if bw.streaming {
+			bw.ResponseWriter.WriteHeader(statusCode)
+		}
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	if bw.streaming {
+		return bw.ResponseWriter.Write(b)
+	}
+	return bw.body.Write(b)
+}
+
+// Flush 结束缓冲：写出已缓存的状态码和响应体，之后的写入直接透传，SSE等流式响应因此可以工作
+func (bw *bufferedWriter) Flush() {
+	if !bw.streaming {
+		bw.streaming = true
+		bw.status = bw.Status()
+		bw.ResponseWriter.WriteHeader(bw.status)
+		bw.ResponseWriter.Write(bw.body.Bytes())
+		bw.body.Reset()
+	}
+	http.NewResponseController(bw.ResponseWriter).Flush()
+}
+
+func (bw *bufferedWriter) Unwrap() http.ResponseWriter { return bw.ResponseWriter }
+
+func (bw *bufferedWriter) Status() int {
+	if bw.status == 0 {
+		return http.StatusOK
+	}
+	return bw.status
+}
+
+// statusRecorder 透传写入，同时记录响应状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(statusCode int) {
+	if sr.status == 0 {
+		sr.status = statusCode
+	}
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sr *statusRecorder) Status() int {
+	if sr.status == 0 {
+		return http.StatusOK
+	}
+	return sr.status
+}
+
+// Hijack 支持WebSocket等协议升级
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if sr.status == 0 {
+		sr.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
@@ -1,9 +1,193 @@
 package middleware
 
-import "net/http"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/suonanjiexi/cyber/pathmatch"
+)
+
+type SanitizeMode int
+
+const (
+	// SanitizeEscape 对HTML特殊字符进行转义
+	SanitizeEscape SanitizeMode = iota
+	// SanitizeStrip 删除script块和所有HTML标签
+	SanitizeStrip
+)
+
+type SafeInputConfig struct {
+	Mode SanitizeMode
+	// Strict 为true时，发现需要清洗的输入直接返回400，而不是清洗后放行
+	Strict bool
+	// 读取并清洗的请求体最大字节数，超过时返回413
+	MaxBodyBytes int64
+	// 不做清洗的路径，支持通配符、正则和方法限定，见pathmatch.Matcher
+	SkipPaths []string
+}
+
+var defaultSafeInputConfig = SafeInputConfig{
+	Mode:         SanitizeEscape,
+	MaxBodyBytes: 10 << 20,
+}
+
+var (
+	scriptPattern = regexp.MustCompile(`(?is)<script[^>]*>.*?</script\s*>`)
+	tagPattern    = regexp.MustCompile(`(?s)<[^>]*>`)
+)
 
 func SafeInputMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		next(w, r)
+	return SafeInput(defaultSafeInputConfig)(next)
+}
+
+// SafeInput sanitizes query parameters, form and JSON string fields and
+// header values, and rejects any input containing null bytes.
+func SafeInput(config SafeInputConfig) func(http.HandlerFunc) http.HandlerFunc {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultSafeInputConfig.MaxBodyBytes
+	}
+	skip := pathmatch.Must(config.SkipPaths...)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if skip.Match(r) {
+				next(w, r)
+				return
+			}
+			s := &sanitizer{mode: config.Mode}
+			if strings.ContainsRune(r.URL.Path, 0) {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			for _, values := range r.Header {
+				for i, value := range values {
+					if strings.ContainsRune(value, 0) {
+						http.Error(w, "Bad Request", http.StatusBadRequest)
+						return
+					}
+					values[i] = strings.TrimSpace(value)
+				}
+			}
+			query := r.URL.Query()
+			if len(query) > 0 {
+				s.values(query)
+				r.URL.RawQuery = query.Encode()
+			}
+			if err := s.body(r, config.MaxBodyBytes); err != nil {
+				if err == errBodyTooLarge {
+					http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			if s.nullByte || (config.Strict && s.changed) {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+type sanitizer struct {
+	mode     SanitizeMode
+	changed  bool
+	nullByte bool
+}
+
+func (s *sanitizer) clean(value string) string {
+	if strings.ContainsRune(value, 0) {
+		s.nullByte = true
+		return value
+	}
+	var cleaned string
+	switch s.mode {
+	case SanitizeStrip:
+		cleaned = tagPattern.ReplaceAllString(scriptPattern.ReplaceAllString(value, ""), "")
+	default:
+		cleaned = html.EscapeString(value)
+	}
+	if cleaned != value {
+		s.changed = true
+	}
+	return cleaned
+}
+
+func (s *sanitizer) values(values url.Values) {
+	for key, vs := range values {
+		for i, v := range vs {
+			vs[i] = s.clean(v)
+		}
+		values[key] = vs
+	}
+}
+
+func (s *sanitizer) body(r *http.Request, limit int64) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	contentType := r.Header.Get("Content-Type")
+	isForm := strings.HasPrefix(contentType, "application/x-www-form-urlencoded")
+	isJSON := strings.Contains(contentType, "json")
+	if !isForm && !isJSON {
+		return nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	if int64(len(raw)) > limit {
+		return errBodyTooLarge
+	}
+	if bytes.IndexByte(raw, 0) >= 0 {
+		s.nullByte = true
+		return nil
+	}
+	if isForm {
+		form, err := url.ParseQuery(string(raw))
+		if err != nil {
+			return err
+		}
+		s.values(form)
+		raw = []byte(form.Encode())
+	} else if len(bytes.TrimSpace(raw)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var data interface{}
+		if err := dec.Decode(&data); err != nil {
+			return err
+		}
+		if raw, err = json.Marshal(s.json(data)); err != nil {
+			return err
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	r.ContentLength = int64(len(raw))
+	return nil
+}
+
+func (s *sanitizer) json(data interface{}) interface{} {
+	switch v := data.(type) {
+	case string:
+		return s.clean(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = s.json(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = s.json(item)
+		}
 	}
+	return data
 }
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// ServerTiming emits a Server-Timing header containing the phases recorded
+// with cyber.StartTiming/cyber.AddTiming plus the total time spent until the
+// response headers were written.
+func ServerTiming(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tw := &timingWriter{ResponseWriter: w, r: r, start: time.Now()}
+		next(tw, r)
+		if !tw.written {
+			tw.setHeader()
+		}
+	}
+}
+
+type timingWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	start   time.Time
+	written bool
+}
+
+func (tw *timingWriter) setHeader() {
+	tw.written = true
+	timings := append(cyber.Timings(tw.r), cyber.Timing{Name: "total", Duration: time.Since(tw.start)})
+	tw.Header().Set("Server-Timing", cyber.FormatServerTiming(timings))
+}
+
+func (tw *timingWriter) WriteHeader(statusCode int) {
+	if !tw.written {
+		tw.setHeader()
+	}
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timingWriter) Write(b []byte) (int, error) {
+	if !tw.written {
+		tw.setHeader()
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timingWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack 支持WebSocket等协议升级
+func (tw *timingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
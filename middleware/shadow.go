@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/logger"
+)
+
+type ShadowConfig struct {
+	// 镜像到影子服务的流量百分比，0-100
+	Percent float64
+	// Handler 是进程内的影子实现，与Upstream二选一
+	Handler http.HandlerFunc
+	// Upstream 是影子服务的地址，例如 http://canary:8080
+	Upstream string
+	Client   *http.Client
+	// 单个影子请求的超时时间
+	Timeout time.Duration
+	// 同时进行的影子请求上限，超出时直接丢弃，避免拖垮主流量
+	MaxInFlight int
+	// 捕获的请求体/响应体的最大字节数，请求体超出时不镜像
+	MaxBodyBytes int64
+	// Compare 不为nil时在影子请求完成后调用，用于比较两个响应
+	Compare func(r *http.Request, primary, shadow *ShadowResponse)
+}
+
+// ShadowResponse is a captured response. Body is truncated to MaxBodyBytes.
+type ShadowResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Err    error
+}
+
+var defaultShadowConfig = ShadowConfig{
+	Timeout:      5 * time.Second,
+	MaxInFlight:  50,
+	MaxBodyBytes: 1 << 20,
+}
+
+// Shadow mirrors Percent of requests to a shadow handler or upstream in the
+// background. The shadow response is discarded and never affects the client;
+// if Compare is set it receives both responses.
+func Shadow(config ShadowConfig) func(http.HandlerFunc) http.HandlerFunc {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultShadowConfig.Timeout
+	}
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = defaultShadowConfig.MaxInFlight
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultShadowConfig.MaxBodyBytes
+	}
+	inFlight := make(chan struct{}, config.MaxInFlight)
+	// 影子服务变慢时每个请求都会被丢弃，限制日志频率
+	dropLog := logger.Every(time.Second)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if config.Percent <= 0 || rand.Float64()*100 >= config.Percent ||
+				(config.Handler == nil && config.Upstream == "") {
+				next(w, r)
+				return
+			}
+			body, ok := readShadowBody(r, config.MaxBodyBytes)
+			if !ok {
+				next(w, r)
+				return
+			}
+			tw := &teeWriter{ResponseWriter: w, limit: config.MaxBodyBytes}
+			next(tw, r)
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				dropLog.Printf("Shadow request dropped: too many in flight")
+				return
+			}
+			primary := &ShadowResponse{Status: tw.Status(), Header: w.Header().Clone(), Body: tw.body.Bytes()}
+			go func() {
+				defer func() { <-inFlight }()
+				defer func() {
+					if err := recover(); err != nil {
+						log.Printf("Panic in shadow request: %v", err)
+					}
+				}()
+				shadow := runShadow(config, r, body)
+				if config.Compare != nil {
+					config.Compare(r, primary, shadow)
+				}
+			}()
+		}
+	}
+}
+
+// readShadowBody 读取请求体供影子请求复用，并为主请求恢复r.Body
+func readShadowBody(r *http.Request, limit int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil || int64(len(body)) > limit {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+func runShadow(config ShadowConfig, r *http.Request, body []byte) *ShadowResponse {
+	// 影子请求不能随主请求结束而取消
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), config.Timeout)
+	defer cancel()
+	if config.Handler != nil {
+		// 影子请求使用独立的请求状态，Abort、Memo等不与主请求互相影响
+		sub := cyber.Fork(ctx, r)
+		sub.Body = io.NopCloser(bytes.NewReader(body))
+		rec := &shadowRecorder{header: make(http.Header), limit: config.MaxBodyBytes}
+		config.Handler(rec, sub)
+		return &ShadowResponse{Status: rec.Status(), Header: rec.header, Body: rec.body.Bytes()}
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, strings.TrimSuffix(config.Upstream, "/")+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return &ShadowResponse{Err: err}
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Set("X-Shadow-Request", "1")
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return &ShadowResponse{Err: err}
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, config.MaxBodyBytes))
+	return &ShadowResponse{Status: resp.StatusCode, Header: resp.Header, Body: respBody, Err: err}
+}
+
+// teeWriter 透传响应，同时保留响应体前limit字节的副本
+type teeWriter struct {
+	http.ResponseWriter
+	status  int
+	limit   int64
+	body    bytes.Buffer
+	written int64
+}
+
+func (tw *teeWriter) WriteHeader(statusCode int) {
+	if tw.status == 0 {
+		tw.status = statusCode
+	}
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *teeWriter) Write(b []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	if remaining := tw.limit - int64(tw.body.Len()); remaining > 0 {
+		tw.body.Write(b[:min(int64(len(b)), remaining)])
+	}
+	tw.written += int64(len(b))
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *teeWriter) Flush() {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	http.NewResponseController(tw.ResponseWriter).Flush()
+}
+
+func (tw *teeWriter) Unwrap() http.ResponseWriter { return tw.ResponseWriter }
+
+func (tw *teeWriter) Status() int {
+	if tw.status == 0 {
+		return http.StatusOK
+	}
+	return tw.status
+}
+
+// shadowRecorder 收集进程内影子handler的响应
+type shadowRecorder struct {
+	header http.Header
+	status int
+	limit  int64
+	body   bytes.Buffer
+}
+
+func (sr *shadowRecorder) Header() http.Header { return sr.header }
+
+func (sr *shadowRecorder) WriteHeader(statusCode int) {
+	if sr.status == 0 {
+		sr.status = statusCode
+	}
+}
+
+func (sr *shadowRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	if remaining := sr.limit - int64(sr.body.Len()); remaining > 0 {
+		sr.body.Write(b[:min(int64(len(b)), remaining)])
+	}
+	return len(b), nil
+}
+
+// Flush 让流式handler在影子请求中也能运行，响应只被收集
+func (sr *shadowRecorder) Flush() {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+}
+
+func (sr *shadowRecorder) Status() int {
+	if sr.status == 0 {
+		return http.StatusOK
+	}
+	return sr.status
+}
@@ -1,53 +1,39 @@
 package middleware
 
 import (
-	"context"
-	"log"
 	"net/http"
-	"sync/atomic"
 	"time"
 )
 
+type TimeoutConfig struct {
+	// 单个请求的处理时限，与服务器WriteTimeout推导出的截止时间取较早者
+	Timeout time.Duration
+	// 超时后返回的响应体
+	Message string
+}
+
+var defaultTimeoutConfig = TimeoutConfig{
+	Timeout: 10 * time.Second,
+	Message: "Request timed out",
+}
+
 func TimeoutMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		maxRetries := uint32(3)
-		retry := uint32(0)
-		timeout := 10 * time.Second
-		for retry < maxRetries {
-			ctx, cancel := context.WithTimeout(r.Context(), timeout)
-			defer cancel()
-			r = r.WithContext(ctx)
-			done := make(chan bool)
-			go func() {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("Recovered in handler: %v", r)
-					}
-				}()
-				next(w, r)
-				done <- true
-			}()
-			select {
-			case <-done:
-				return
-			case <-ctx.Done():
-				retry = atomic.AddUint32(&retry, 1)
-				if retry == maxRetries {
-					log.Printf("Request timed out after maximum retries, last error: %v", ctx.Err())
-					http.Error(w, "Request timed out after maximum retries", http.StatusGatewayTimeout)
-					return
-				}
-				log.Printf("Request timed out, retrying (attempt %d)...", retry)
-				timeout = doubleTimeout(timeout)
-			}
-		}
-	}
+	return Timeout(defaultTimeoutConfig)(next)
 }
 
-func doubleTimeout(timeout time.Duration) time.Duration {
-	const maxTimeout = 60 * time.Second
-	if timeout < maxTimeout {
-		return 2 * timeout
+// Timeout bounds a route's handling time. The request context gets the
+// earlier of its existing deadline and now+Timeout, so cyber.TimeRemaining
+// and context-aware DB or client calls see it; if the handler hasn't
+// finished by then the client receives 503 and later writes are discarded.
+// Handlers are not retried, since a timed-out handler may still be running.
+func Timeout(config TimeoutConfig) func(http.HandlerFunc) http.HandlerFunc {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeoutConfig.Timeout
+	}
+	if config.Message == "" {
+		config.Message = defaultTimeoutConfig.Message
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.TimeoutHandler(next, config.Timeout, config.Message).ServeHTTP
 	}
-	return maxTimeout
 }
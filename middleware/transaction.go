@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/suonanjiexi/cyber/db"
+)
+
+// Transaction opens a transaction per request and stores it on the request
+// context (see db.Conn). It commits when the handler responds with a status
+// below 400 and rolls back on error statuses or panics. The response is
+// buffered so a failed commit can still be reported as 500. A handler that
+// flushes, e.g. for server-sent events, ends the buffering: the response
+// streams from then on and the transaction is still committed or rolled
+// back at the end, but a failed commit can only be logged.
+func Transaction(database *sql.DB) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tx, err := database.BeginTx(r.Context(), nil)
+			if err != nil {
+				log.Printf("Error beginning transaction: %v", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			bw := newBufferedWriter(w)
+			defer func() {
+				if err := recover(); err != nil {
+					tx.Rollback()
+					panic(err)
+				}
+			}()
+			next(bw, r.WithContext(db.WithTx(r.Context(), tx)))
+			if bw.Status() >= http.StatusBadRequest {
+				if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+					log.Printf("Error rolling back transaction: %v", err)
+				}
+			} else if err := tx.Commit(); err != nil && err != sql.ErrTxDone {
+				log.Printf("Error committing transaction: %v", err)
+				if !bw.streaming {
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				}
+				return
+			}
+			if bw.streaming {
+				// 响应已经发出
+				return
+			}
+			w.WriteHeader(bw.Status())
+			w.Write(bw.body.Bytes())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type WatchdogConfig struct {
+	// 请求处理超过该时间时采样处理goroutine的调用栈，之后每隔该时间再采样一次
+	Threshold time.Duration
+	// 每个请求最多采样的次数
+	MaxSamples int
+	// OnSample 接收采样结果，默认写日志
+	OnSample func(r *http.Request, elapsed time.Duration, stack []byte)
+}
+
+var defaultWatchdogConfig = WatchdogConfig{
+	Threshold:  2 * time.Second,
+	MaxSamples: 3,
+	OnSample:   logSample,
+}
+
+func WatchdogMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return Watchdog(defaultWatchdogConfig)(next)
+}
+
+// Watchdog samples the stack of the goroutine handling a request once it
+// runs longer than Threshold, so a hang on a lock or a slow query shows
+// where it waits without a profiler. Each sample dumps all goroutines,
+// which briefly stops the world; MaxSamples bounds the cost per request.
+func Watchdog(config WatchdogConfig) func(http.HandlerFunc) http.HandlerFunc {
+	if config.Threshold <= 0 {
+		config.Threshold = defaultWatchdogConfig.Threshold
+	}
+	if config.MaxSamples <= 0 {
+		config.MaxSamples = defaultWatchdogConfig.MaxSamples
+	}
+	if config.OnSample == nil {
+		config.OnSample = defaultWatchdogConfig.OnSample
+	}
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := goroutineID()
+			var mu sync.Mutex
+			finished, samples := false, 0
+			var timer *time.Timer
+			timer = time.AfterFunc(config.Threshold, func() {
+				stack := goroutineStack(id)
+				mu.Lock()
+				// 请求已经结束时调用栈属于其他请求，丢弃
+				if finished || stack == nil {
+					mu.Unlock()
+					return
+				}
+				samples++
+				if samples < config.MaxSamples {
+					timer.Reset(config.Threshold)
+				}
+				mu.Unlock()
+				config.OnSample(r, time.Since(start), stack)
+			})
+			defer func() {
+				mu.Lock()
+				finished = true
+				sampled := samples > 0
+				mu.Unlock()
+				timer.Stop()
+				if sampled {
+					log.Printf("Slow request finished: %s %s after %s", r.Method, r.URL.Path, formatDuration(time.Since(start)))
+				}
+			}()
+			next(w, r)
+		}
+	}
+}
+
+func logSample(r *http.Request, elapsed time.Duration, stack []byte) {
+	log.Printf("Slow request: %s %s running for %s\n%s", r.Method, r.URL.Path, formatDuration(elapsed), stack)
+}
+
+// goroutineID 从当前goroutine调用栈的第一行 "goroutine 123 [running]:" 解析ID
+func goroutineID() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		if _, err := strconv.ParseUint(string(buf[:i]), 10, 64); err == nil {
+			return buf[:i]
+		}
+	}
+	return nil
+}
+
+// goroutineStack 返回指定goroutine的调用栈，找不到时返回nil
+func goroutineStack(id []byte) []byte {
+	if id == nil {
+		return nil
+	}
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	header := append(append([]byte("goroutine "), id...), ' ')
+	for _, block := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(block, header) {
+			return block
+		}
+	}
+	return nil
+}
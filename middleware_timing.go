@@ -0,0 +1,144 @@
+package cyber
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MiddlewareObserver receives the time a middleware spent on a request,
+// excluding the middlewares and handler it called, e.g. to export it as
+// metrics or spans. name identifies the middleware by its function, such
+// as "middleware.Logger" or "middleware.RateLimit".
+type MiddlewareObserver func(r *http.Request, name string, duration time.Duration)
+
+// middlewareSpan 记录中间件调用next所花的时间，用于从总时间中扣除
+type middlewareSpan struct {
+	inNext atomic.Int64
+}
+
+// ObserveMiddlewares measures every app and group middleware of routes
+// registered afterwards and reports each run to observers. Call it before
+// registering routes. Measuring costs two clock reads per middleware per
+// request; without observers middlewares are not wrapped at all.
+func (app *App) ObserveMiddlewares(observers ...MiddlewareObserver) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.middlewareObservers = append(app.middlewareObservers[:len(app.middlewareObservers):len(app.middlewareObservers)], observers...)
+}
+
+// ServerTimingObserver is a MiddlewareObserver adding each middleware's
+// time to the request's timing phases as "mw.<name>", e.g. for an access
+// log reading Timings. A middleware's time is known only when it returns,
+// so the Server-Timing header, sent with the first write, rarely has it.
+func ServerTimingObserver(r *http.Request, name string, duration time.Duration) {
+	AddTiming(r, "mw."+name, duration, "")
+}
+
+// instrumentMiddlewares 为每个中间件套上计时，没有观察者时原样返回
+func instrumentMiddlewares(middlewares []Middleware, observers []MiddlewareObserver) []Middleware {
+	if len(observers) == 0 || len(middlewares) == 0 {
+		return middlewares
+	}
+	instrumented := make([]Middleware, len(middlewares))
+	for i, m := range middlewares {
+		instrumented[i] = instrumentMiddleware(middlewareName(m), m, observers)
+	}
+	return instrumented
+}
+
+func instrumentMiddleware(name string, m Middleware, observers []MiddlewareObserver) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		handler := m(func(w http.ResponseWriter, r *http.Request) {
+			span := currentSpan(r)
+			start := time.Now()
+			defer func() {
+				if span != nil {
+					span.inNext.Add(int64(time.Since(start)))
+				}
+			}()
+			next(w, r)
+		})
+		return func(w http.ResponseWriter, r *http.Request) {
+			state := stateFromRequest(r)
+			if state == nil {
+				handler(w, r)
+				return
+			}
+			span := &middlewareSpan{}
+			state.mu.Lock()
+			state.middlewareSpans = append(state.middlewareSpans, span)
+			state.mu.Unlock()
+			start := time.Now()
+			defer func() {
+				elapsed := time.Since(start) - time.Duration(span.inNext.Load())
+				state.mu.Lock()
+				// 按身份移除：在其他goroutine中调用next的中间件（例如超时）可能已经先返回
+				for i := len(state.middlewareSpans) - 1; i >= 0; i-- {
+					if state.middlewareSpans[i] == span {
+						state.middlewareSpans = append(state.middlewareSpans[:i], state.middlewareSpans[i+1:]...)
+						break
+					}
+				}
+				state.mu.Unlock()
+				for _, observe := range observers {
+					observe(r, name, elapsed)
+				}
+			}()
+			handler(w, r)
+		}
+	}
+}
+
+// currentSpan 返回最内层正在执行的中间件，即调用当前next的中间件
+func currentSpan(r *http.Request) *middlewareSpan {
+	state := stateFromRequest(r)
+	if state == nil {
+		return nil
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if len(state.middlewareSpans) == 0 {
+		return nil
+	}
+	return state.middlewareSpans[len(state.middlewareSpans)-1]
+}
+
+// middlewareName 从函数名得到中间件名称，例如
+// github.com/suonanjiexi/cyber/middleware.RateLimit.func1 得到 middleware.RateLimit
+func middlewareName(m Middleware) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(m).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, "-fm")
+	// 去掉闭包后缀 .func1、.func1.2 等
+	for {
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 || !isClosureSuffix(name[i+1:]) {
+			break
+		}
+		name = name[:i]
+	}
+	return name
+}
+
+func isClosureSuffix(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package cyber
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+type Mode int
+
+const (
+	// DebugMode 打印路由表、显示带堆栈的错误页并在每次渲染时重新加载模板
+	DebugMode Mode = iota
+	// ReleaseMode 关闭上述调试功能
+	ReleaseMode
+)
+
+// ModeEnv selects the initial mode of new apps: "release" or "debug".
+const ModeEnv = "CYBER_MODE"
+
+// 调试模式会向客户端显示堆栈和请求转储，必须显式开启
+func defaultMode() Mode {
+	if strings.EqualFold(os.Getenv(ModeEnv), "debug") {
+		return DebugMode
+	}
+	return ReleaseMode
+}
+
+// SetMode switches debug features on or off. Apps start in ReleaseMode
+// unless CYBER_MODE=debug; only enable DebugMode during development, since
+// debug panic pages expose stack traces.
+func (app *App) SetMode(mode Mode) {
+	app.mode = mode
+}
+
+func (app *App) Mode() Mode {
+	return app.mode
+}
+
+func (app *App) IsDebug() bool {
+	return app.mode == DebugMode
+}
+
+// debugf 只在调试模式下输出日志
+func (app *App) debugf(format string, args ...interface{}) {
+	if app.IsDebug() {
+		log.Printf(format, args...)
+	}
+}
+
+var panicTemplate = template.Must(template.New("panic").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>panic: {{.Error}}</title>
+<style>body{font-family:monospace;margin:2em}h2{color:#c00}pre{background:#f6f6f6;padding:1em;overflow:auto}</style></head>
+<body><h2>panic: {{.Error}}</h2><h3>Stack</h3><pre>{{.Stack}}</pre><h3>Request</h3><pre>{{.Request}}</pre></body></html>`))
+
+// RenderPanic answers a recovered panic. In debug mode it renders a page with
+// the stack trace and a request dump (credentials redacted); otherwise, or
+// outside an App, a plain 500. http.ErrAbortHandler is re-panicked so the
+// server aborts the response.
+func RenderPanic(w http.ResponseWriter, r *http.Request, err interface{}) {
+	// http.ErrAbortHandler 表示有意中断已经开始的响应，交回net/http关闭连接且不记录
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	stack := debug.Stack()
+	log.Printf("Panic occurred in handler: %v\n%s", err, stack)
+	state := stateFromRequest(r)
+	if state == nil || state.app == nil || !state.app.IsDebug() {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	dump, _ := DumpRequest(r, false)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	panicTemplate.Execute(w, map[string]string{
+		"Error":   fmt.Sprint(err),
+		"Stack":   string(stack),
+		"Request": string(dump),
+	})
+}
@@ -0,0 +1,141 @@
+package cyber
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrNotMultipart     = errors.New("cyber: request is not multipart")
+	ErrPartTooLarge     = errors.New("cyber: multipart part exceeds size limit")
+	ErrTooManyParts     = errors.New("cyber: too many multipart parts")
+	ErrPartTypeRejected = errors.New("cyber: multipart part content type not allowed")
+)
+
+type MultipartConfig struct {
+	// 单个part的最大字节数，0表示不限制
+	MaxPartSize int64
+	// 最多处理的part数量，0表示不限制
+	MaxParts int
+	// 允许的文件类型（根据内容嗅探），支持 "image/*" 形式；为空时不校验
+	AllowedTypes []string
+}
+
+// Part is a single multipart part. Reads are limited to MaxPartSize and
+// return ErrPartTooLarge once it is exceeded.
+type Part struct {
+	FormName string
+	FileName string
+	// ContentType 是根据内容嗅探得到的类型，而不是客户端声明的类型
+	ContentType string
+	Header      map[string][]string
+	reader      io.Reader
+}
+
+func (p *Part) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
+
+// IsFile reports whether the part carries a file upload.
+func (p *Part) IsFile() bool {
+	return p.FileName != ""
+}
+
+type limitedPart struct {
+	r       io.Reader
+	remain  int64
+	limited bool
+}
+
+func (l *limitedPart) Read(b []byte) (int, error) {
+	if !l.limited {
+		return l.r.Read(b)
+	}
+	if l.remain < 0 {
+		return 0, ErrPartTooLarge
+	}
+	if int64(len(b)) > l.remain+1 {
+		b = b[:l.remain+1]
+	}
+	n, err := l.r.Read(b)
+	l.remain -= int64(n)
+	if l.remain < 0 {
+		return n + int(l.remain), ErrPartTooLarge
+	}
+	return n, err
+}
+
+// MultipartReader streams the parts of a multipart request to fn one by one,
+// without buffering them in memory or temp files like ParseMultipartForm.
+func MultipartReader(r *http.Request, config *MultipartConfig, fn func(part *Part) error) error {
+	if config == nil {
+		config = &MultipartConfig{}
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return ErrNotMultipart
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for count := 0; ; count++ {
+		raw, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if config.MaxParts > 0 && count >= config.MaxParts {
+			raw.Close()
+			return ErrTooManyParts
+		}
+		part, err := newPart(raw, config)
+		if err == nil {
+			err = fn(part)
+			// 丢弃回调未读完的数据，以便继续读取下一个part
+			if err == nil {
+				_, err = io.Copy(io.Discard, part)
+			}
+		}
+		raw.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func newPart(raw *multipart.Part, config *MultipartConfig) (*Part, error) {
+	limited := &limitedPart{r: raw, remain: config.MaxPartSize, limited: config.MaxPartSize > 0}
+	buffered := bufio.NewReaderSize(limited, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head)
+	part := &Part{
+		FormName:    raw.FormName(),
+		FileName:    raw.FileName(),
+		ContentType: contentType,
+		Header:      raw.Header,
+		reader:      buffered,
+	}
+	if part.IsFile() && len(config.AllowedTypes) > 0 && !allowedType(contentType, config.AllowedTypes) {
+		return nil, fmt.Errorf("%w: %s", ErrPartTypeRejected, contentType)
+	}
+	return part, nil
+}
+
+func allowedType(contentType string, allowed []string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	for _, a := range allowed {
+		if matchMediaType(strings.ToLower(a), mediaType) > 0 {
+			return true
+		}
+	}
+	return false
+}
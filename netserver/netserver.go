@@ -0,0 +1,244 @@
+// Package netserver runs raw TCP and UDP listeners alongside an App. They
+// open when the app starts, are drained when it shuts down, log through the
+// standard logger and can report to the app's metrics.
+package netserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/metrics"
+)
+
+// TCPHandler serves one connection. ctx is cancelled when the app shuts
+// down; the connection is closed after the handler returns.
+type TCPHandler func(ctx context.Context, conn net.Conn)
+
+// UDPHandler handles one packet. Replies are sent with conn.WriteTo(addr).
+type UDPHandler func(ctx context.Context, conn net.PacketConn, addr net.Addr, packet []byte)
+
+type Config struct {
+	// Metrics 记录每个连接或数据包，处理时panic记为500
+	Metrics *metrics.Metrics
+	// UDP数据包的最大长度，默认64KiB
+	MaxPacketSize int
+}
+
+const defaultMaxPacketSize = 64 << 10
+
+type tcpListener struct {
+	addr    string
+	handler TCPHandler
+	ln      net.Listener
+}
+
+type udpListener struct {
+	addr    string
+	handler UDPHandler
+	conn    net.PacketConn
+}
+
+// Server holds the raw listeners of an app. Use New to create one.
+type Server struct {
+	config Config
+	mu     sync.Mutex
+	tcp    []*tcpListener
+	udp    []*udpListener
+	// conns 是正在处理的TCP连接，关闭超时后强制断开
+	conns  map[net.Conn]struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Server whose listeners open in app's start hooks and are
+// drained in its stop hooks.
+func New(app *cyber.App, config Config) *Server {
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = defaultMaxPacketSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{config: config, conns: make(map[net.Conn]struct{}), ctx: ctx, cancel: cancel}
+	app.OnStart(s.Start)
+	app.OnStop(s.Shutdown)
+	return s
+}
+
+// HandleTCP serves TCP connections accepted on addr with handler.
+func (s *Server) HandleTCP(addr string, handler TCPHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tcp = append(s.tcp, &tcpListener{addr: addr, handler: handler})
+}
+
+// HandleUDP serves UDP packets received on addr with handler.
+func (s *Server) HandleUDP(addr string, handler UDPHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.udp = append(s.udp, &udpListener{addr: addr, handler: handler})
+}
+
+// Start opens every listener. It is registered as a start hook by New; if
+// any listener fails, the ones already opened are closed.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.tcp {
+		ln, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("netserver: %w", err)
+		}
+		l.ln = ln
+	}
+	for _, l := range s.udp {
+		conn, err := net.ListenPacket("udp", l.addr)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("netserver: %w", err)
+		}
+		l.conn = conn
+	}
+	for _, l := range s.tcp {
+		log.Printf("TCP server starting on %s", l.ln.Addr())
+		s.wg.Add(1)
+		go s.serveTCP(l)
+	}
+	for _, l := range s.udp {
+		log.Printf("UDP server starting on %s", l.conn.LocalAddr())
+		s.wg.Add(1)
+		go s.serveUDP(l)
+	}
+	return nil
+}
+
+// Addrs returns the addresses being listened on, including ports chosen by
+// the system for port 0.
+func (s *Server) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var addrs []net.Addr
+	for _, l := range s.tcp {
+		if l.ln != nil {
+			addrs = append(addrs, l.ln.Addr())
+		}
+	}
+	for _, l := range s.udp {
+		if l.conn != nil {
+			addrs = append(addrs, l.conn.LocalAddr())
+		}
+	}
+	return addrs
+}
+
+// Shutdown stops accepting connections and packets, cancels the handlers'
+// context and waits for them to return. Connections still open when ctx
+// expires are closed. It is registered as a stop hook by New.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closeListeners()
+	s.mu.Unlock()
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		log.Printf("Closing %d raw connection(s) still open at shutdown", len(s.conns))
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// closeListeners 关闭所有已打开的监听，调用方需持有s.mu
+func (s *Server) closeListeners() {
+	for _, l := range s.tcp {
+		if l.ln != nil {
+			l.ln.Close()
+		}
+	}
+	for _, l := range s.udp {
+		if l.conn != nil {
+			l.conn.Close()
+		}
+	}
+}
+
+func (s *Server) serveTCP(l *tcpListener) {
+	defer s.wg.Done()
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("TCP accept error on %s: %v", l.ln.Addr(), err)
+			}
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				conn.Close()
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+			s.run(fmt.Sprintf("TCP %s", conn.RemoteAddr()), func() { l.handler(s.ctx, conn) })
+		}()
+	}
+}
+
+func (s *Server) serveUDP(l *udpListener) {
+	defer s.wg.Done()
+	for {
+		buf := make([]byte, s.config.MaxPacketSize)
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("UDP read error on %s: %v", l.conn.LocalAddr(), err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(fmt.Sprintf("UDP %s", addr), func() { l.handler(s.ctx, l.conn, addr, buf[:n]) })
+		}()
+	}
+}
+
+// run 执行handler，恢复panic并记录指标
+func (s *Server) run(source string, handler func()) {
+	start := time.Now()
+	status := http.StatusOK
+	defer func() {
+		if err := recover(); err != nil {
+			status = http.StatusInternalServerError
+			log.Printf("Panic occurred in %s handler: %v\n%s", source, err, debug.Stack())
+		}
+		if s.config.Metrics != nil {
+			s.config.Metrics.RecordRequest(status, time.Since(start))
+		}
+	}()
+	handler()
+}
@@ -0,0 +1,167 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidIDToken = errors.New("oauth: invalid id_token")
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// 遇到未知kid时重新拉取JWKS的最短间隔，防止客户端用伪造的kid触发大量外部请求
+const minKeyRefreshInterval = time.Minute
+
+// keySet 缓存提供方的JWKS，遇到未知kid时重新拉取
+type keySet struct {
+	url    string
+	client *http.Client
+	mu     sync.Mutex
+	keys   map[string]*rsa.PublicKey
+	// refreshedAt 是上次拉取的时间，拉取失败也计入
+	refreshedAt time.Time
+}
+
+func (ks *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(ks.refreshedAt) < minKeyRefreshInterval {
+		return nil, fmt.Errorf("oauth: unknown key id %q", kid)
+	}
+	ks.refreshedAt = time.Now()
+	if err := ks.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("oauth: unknown key id %q", kid)
+}
+
+func (ks *keySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth: jwks endpoint returned %s", resp.Status)
+	}
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	ks.keys = keys
+	return nil
+}
+
+// VerifyIDToken checks the RS256 signature, issuer, audience, expiry and
+// nonce of an ID token and returns its claims.
+func (c *Client) VerifyIDToken(ctx context.Context, idToken, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("oauth: unsupported id_token alg %q", header.Alg)
+	}
+	key, err := c.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if c.config.Issuer != "" && strings.TrimSuffix(fmt.Sprint(claims["iss"]), "/") != strings.TrimSuffix(c.config.Issuer, "/") {
+		return nil, ErrInvalidIDToken
+	}
+	if !hasAudience(claims["aud"], c.config.ClientID) {
+		return nil, ErrInvalidIDToken
+	}
+	exp, _ := claims["exp"].(float64)
+	if time.Now().After(time.Unix(int64(exp), 0)) {
+		return nil, ErrInvalidIDToken
+	}
+	if claims["nonce"] != nonce {
+		return nil, ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func hasAudience(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, item := range v {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,421 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/securecookie"
+)
+
+var (
+	ErrInvalidState = errors.New("oauth: invalid state")
+	ErrNoIDToken    = errors.New("oauth: token response has no id_token")
+)
+
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// Issuer 不为空时通过 /.well-known/openid-configuration 发现各个端点
+	Issuer string
+	// 手动指定的端点，优先于发现文档
+	AuthURL  string
+	TokenURL string
+	JWKSURL  string
+	// 未登录时跳转的登录地址，默认 /auth/login
+	LoginPath string
+	// 登录成功后的默认跳转地址，默认 /
+	AfterLoginPath string
+	CookieName     string
+	SessionTTL     time.Duration
+	Store          Store
+	HTTPClient     *http.Client
+	// Cookies 不为nil时登录中的状态加密保存在Cookie中而不是进程内存，多实例部署时回调可以由任意实例处理；
+	// 会话Cookie也用它签名。应使用Encrypt为true的Codec
+	Cookies *securecookie.Codec
+}
+
+// ProviderMetadata is the subset of the OIDC discovery document used here.
+type ProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Expiry       time.Time `json:"-"`
+}
+
+const (
+	defaultLoginPath      = "/auth/login"
+	defaultAfterLoginPath = "/"
+	defaultCookieName     = "cyber_session"
+	defaultSessionTTL     = 24 * time.Hour
+	stateCookieName       = "cyber_oauth_state"
+	pendingTTL            = 10 * time.Minute
+)
+
+type pendingLogin struct {
+	nonce    string
+	verifier string
+	returnTo string
+	expires  time.Time
+}
+
+// pendingCookie 是使用Config.Cookies时保存在state Cookie中的登录状态
+type pendingCookie struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	ReturnTo string `json:"r"`
+	Expires  int64  `json:"e"`
+}
+
+// Client implements the OAuth2 authorization-code flow with PKCE and OIDC
+// ID token validation.
+type Client struct {
+	config  Config
+	keys    *keySet
+	mu      sync.Mutex
+	pending map[string]pendingLogin
+	// pendingSweepAt 是下次清理过期登录状态时的状态数，至少为minSweep
+	pendingSweepAt int
+}
+
+// Discover fetches the OIDC discovery document of issuer.
+func Discover(ctx context.Context, client *http.Client, issuer string) (*ProviderMetadata, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth: discovery returned %s", resp.Status)
+	}
+	var metadata ProviderMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("oauth: issuer mismatch: %q != %q", metadata.Issuer, issuer)
+	}
+	return &metadata, nil
+}
+
+func New(ctx context.Context, config Config) (*Client, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if config.Issuer != "" && (config.AuthURL == "" || config.TokenURL == "" || config.JWKSURL == "") {
+		metadata, err := Discover(ctx, config.HTTPClient, config.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		if config.AuthURL == "" {
+			config.AuthURL = metadata.AuthorizationEndpoint
+		}
+		if config.TokenURL == "" {
+			config.TokenURL = metadata.TokenEndpoint
+		}
+		if config.JWKSURL == "" {
+			config.JWKSURL = metadata.JWKSURI
+		}
+	}
+	if config.AuthURL == "" || config.TokenURL == "" {
+		return nil, errors.New("oauth: AuthURL and TokenURL are required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+	if config.LoginPath == "" {
+		config.LoginPath = defaultLoginPath
+	}
+	if config.AfterLoginPath == "" {
+		config.AfterLoginPath = defaultAfterLoginPath
+	}
+	if config.CookieName == "" {
+		config.CookieName = defaultCookieName
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = defaultSessionTTL
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	return &Client{
+		config:  config,
+		keys:    &keySet{url: config.JWKSURL, client: config.HTTPClient},
+		pending: make(map[string]pendingLogin),
+	}, nil
+}
+
+// LoginHandler redirects the user agent to the provider's authorization
+// endpoint. An optional return_to query parameter (relative path only) is
+// honored after the callback.
+func (c *Client) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	returnTo := r.URL.Query().Get("return_to")
+	if !isRelativePath(returnTo) {
+		returnTo = c.config.AfterLoginPath
+	}
+	stateValue, err := c.savePending(state, pendingLogin{nonce: nonce, verifier: verifier, returnTo: returnTo, expires: time.Now().Add(pendingTTL)})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    stateValue,
+		Path:     "/",
+		MaxAge:   int(pendingTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   cyber.IsTLS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.config.ClientID},
+		"redirect_uri":          {c.config.RedirectURL},
+		"scope":                 {strings.Join(c.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(c.config.AuthURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, c.config.AuthURL+sep+params.Encode(), http.StatusFound)
+}
+
+// CallbackHandler validates state, exchanges the code for tokens, verifies
+// the ID token and starts a session.
+func (c *Client) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, "Authorization Failed: "+errCode, http.StatusUnauthorized)
+		return
+	}
+	pending, ok := c.takePending(r, query.Get("state"))
+	if !ok {
+		http.Error(w, ErrInvalidState.Error(), http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookieName, Path: "/", MaxAge: -1})
+
+	token, err := c.Exchange(r.Context(), query.Get("code"), pending.verifier)
+	if err != nil {
+		http.Error(w, "Token Exchange Failed", http.StatusBadGateway)
+		return
+	}
+	var claims map[string]interface{}
+	if c.config.JWKSURL != "" {
+		if token.IDToken == "" {
+			http.Error(w, ErrNoIDToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		claims, err = c.VerifyIDToken(r.Context(), token.IDToken, pending.nonce)
+		if err != nil {
+			http.Error(w, "Invalid ID Token", http.StatusUnauthorized)
+			return
+		}
+	}
+	sessionID := randomString()
+	session := &Session{Token: token, Claims: claims, Expires: time.Now().Add(c.config.SessionTTL)}
+	c.config.Store.Set(sessionID, session)
+	sessionCookie := &http.Cookie{
+		Name:     c.config.CookieName,
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   cyber.IsTLS(r),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if c.config.Cookies == nil {
+		http.SetCookie(w, sessionCookie)
+	} else if err := c.config.Cookies.SetCookie(w, sessionCookie); err != nil {
+		c.config.Store.Delete(sessionID)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, pending.returnTo, http.StatusFound)
+}
+
+// savePending 保存登录状态，返回写入state Cookie的值
+func (c *Client) savePending(state string, pending pendingLogin) (string, error) {
+	if c.config.Cookies != nil {
+		data, err := json.Marshal(pendingCookie{
+			State:    state,
+			Nonce:    pending.nonce,
+			Verifier: pending.verifier,
+			ReturnTo: pending.returnTo,
+			Expires:  pending.expires.Unix(),
+		})
+		if err != nil {
+			return "", err
+		}
+		return c.config.Cookies.Encode(stateCookieName, data)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[state] = pending
+	if len(c.pending) >= max(c.pendingSweepAt, minSweep) {
+		now := time.Now()
+		for key, p := range c.pending {
+			if now.After(p.expires) {
+				delete(c.pending, key)
+			}
+		}
+		c.pendingSweepAt = nextSweep(len(c.pending))
+	}
+	return state, nil
+}
+
+// takePending 取出与请求的state参数和state Cookie都一致且未过期的登录状态。
+// 保存在内存中的状态只能使用一次；保存在Cookie中的状态随Cookie删除，授权码本身也只能兑换一次
+func (c *Client) takePending(r *http.Request, state string) (pendingLogin, bool) {
+	cookie, err := r.Cookie(stateCookieName)
+	if err != nil || state == "" {
+		return pendingLogin{}, false
+	}
+	if c.config.Cookies != nil {
+		var p pendingCookie
+		data, err := c.config.Cookies.Decode(stateCookieName, cookie.Value)
+		if err != nil || json.Unmarshal(data, &p) != nil || p.State != state || time.Now().Unix() > p.Expires {
+			return pendingLogin{}, false
+		}
+		return pendingLogin{nonce: p.Nonce, verifier: p.Verifier, returnTo: p.ReturnTo, expires: time.Unix(p.Expires, 0)}, true
+	}
+	if cookie.Value != state {
+		return pendingLogin{}, false
+	}
+	c.mu.Lock()
+	pending, ok := c.pending[state]
+	delete(c.pending, state)
+	c.mu.Unlock()
+	if !ok || time.Now().After(pending.expires) {
+		return pendingLogin{}, false
+	}
+	return pending, true
+}
+
+// sessionID 返回会话Cookie中的会话ID，签名无效时视为没有会话
+func (c *Client) sessionID(r *http.Request) (string, bool) {
+	if c.config.Cookies != nil {
+		id, err := c.config.Cookies.Cookie(r, c.config.CookieName)
+		return id, err == nil
+	}
+	cookie, err := r.Cookie(c.config.CookieName)
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+func (c *Client) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if id, ok := c.sessionID(r); ok {
+		c.config.Store.Delete(id)
+	}
+	http.SetCookie(w, &http.Cookie{Name: c.config.CookieName, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, c.config.AfterLoginPath, http.StatusFound)
+}
+
+// Exchange trades an authorization code for tokens at the token endpoint.
+func (c *Client) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.RedirectURL},
+		"client_id":     {c.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth: token endpoint returned %s", resp.Status)
+	}
+	var token Token
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("oauth: token response has no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+// Require returns a middleware that only lets requests with a valid session
+// through. GET requests without one are redirected to the login path, other
+// methods get 401.
+func (c *Client) Require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := c.sessionID(r); ok {
+			if session, ok := c.config.Store.Get(id); ok && time.Now().Before(session.Expires) {
+				next(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, session)))
+				return
+			}
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, c.config.LoginPath+"?return_to="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+	}
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func isRelativePath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "/\\")
+}
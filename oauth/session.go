@@ -0,0 +1,91 @@
+package oauth
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Session holds the tokens and ID token claims of a logged-in user.
+type Session struct {
+	Token   *Token
+	Claims  map[string]interface{}
+	Expires time.Time
+}
+
+// Store keeps sessions by ID. MemoryStore is local to one instance: behind
+// a load balancer, either pin clients with cyber.StickySession or use a
+// Store shared by all instances.
+type Store interface {
+	Get(id string) (*Session, bool)
+	Set(id string, session *Session)
+	Delete(id string)
+}
+
+// MemoryStore keeps sessions in process. Expired sessions are reported as
+// missing and swept on Set.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+	// sweepAt 是下次清理过期会话时的会话数
+	sweepAt int
+}
+
+// 会话数达到该值后才开始清理
+const minSweep = 64
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*Session), sweepAt: minSweep}
+}
+
+func (s *MemoryStore) Get(id string) (*Session, bool) {
+	s.mu.RLock()
+	session, ok := s.sessions[id]
+	s.mu.RUnlock()
+	if !ok || expired(session.Expires, time.Now()) {
+		return nil, false
+	}
+	return session, true
+}
+
+func (s *MemoryStore) Set(id string, session *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = session
+	if len(s.sessions) >= s.sweepAt {
+		now := time.Now()
+		for key, session := range s.sessions {
+			if expired(session.Expires, now) {
+				delete(s.sessions, key)
+			}
+		}
+		s.sweepAt = nextSweep(len(s.sessions))
+	}
+}
+
+func (s *MemoryStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// expired 判断过期时间是否已过，零值表示不过期
+func expired(expires, now time.Time) bool {
+	return !expires.IsZero() && now.After(expires)
+}
+
+// nextSweep 把下次清理的阈值设为剩余条目数的两倍，使清理的开销均摊到每次保存
+func nextSweep(remaining int) int {
+	if 2*remaining < minSweep {
+		return minSweep
+	}
+	return 2 * remaining
+}
+
+type sessionKey struct{}
+
+// SessionFromRequest returns the session stored by Client.Require.
+func SessionFromRequest(r *http.Request) (*Session, bool) {
+	session, ok := r.Context().Value(sessionKey{}).(*Session)
+	return session, ok
+}
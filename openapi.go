@@ -0,0 +1,384 @@
+package cyber
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 路由Meta中用于OpenAPI的键
+const (
+	// MetaResponses 是逗号分隔的状态码列表，例如 "200,404"；未设置时只声明default响应
+	MetaResponses = "responses"
+	// MetaProduces 是响应的Content-Type，默认application/json
+	MetaProduces = "produces"
+)
+
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components *Components                      `json:"components,omitempty"`
+}
+
+// Components holds the schemas of named struct types used by Typed routes.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string            `json:"name"`
+	In       string            `json:"in"`
+	Required bool              `json:"required"`
+	Schema   map[string]string `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes a body of one Content-Type; Schema is set for the
+// JSON bodies of Typed routes.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+var wildcardPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// OpenAPIPath converts a ServeMux pattern to an OpenAPI path; {name...}
+// wildcards become {name}.
+func OpenAPIPath(pattern string) string {
+	return wildcardPattern.ReplaceAllString(pattern, "{$1}")
+}
+
+// BuildOpenAPI derives an OpenAPI 3 document from a route table. Routes
+// without a method are skipped. Response codes come from the route's
+// "responses" meta entry. For routes whose handler was made by Typed, the
+// request body and responses get schemas generated from Req and Resp, and
+// their codes default to "200,default".
+func BuildOpenAPI(title, version string, routes []*Route) *OpenAPI {
+	return buildOpenAPI(title, version, routes, nil, false)
+}
+
+// buildOpenAPI 生成文档；envelope非空时响应schema按信封格式包装，customErrors表示错误响应格式由应用自定义
+func buildOpenAPI(title, version string, routes []*Route, envelope *EnvelopeConfig, customErrors bool) *OpenAPI {
+	spec := &OpenAPI{
+		OpenAPI: "3.0.3",
+		Info:    OpenAPIInfo{Title: title, Version: version},
+		Paths:   make(map[string]map[string]*Operation),
+	}
+	schemas := newSchemaBuilder()
+	for _, route := range routes {
+		if route.Method == "" {
+			continue
+		}
+		path := OpenAPIPath(route.Pattern)
+		if spec.Paths[path] == nil {
+			spec.Paths[path] = make(map[string]*Operation)
+		}
+		operation := &Operation{OperationID: route.Name, Responses: make(map[string]*Response)}
+		for _, match := range wildcardPattern.FindAllStringSubmatch(route.Pattern, -1) {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name: match[1], In: "path", Required: true, Schema: map[string]string{"type": "string"},
+			})
+		}
+		produces := route.Meta[MetaProduces]
+		if produces == "" {
+			produces = "application/json"
+		}
+		typed := route.types.Load()
+		if typed != nil {
+			operation.Parameters = append(operation.Parameters, headerParameters(typed.req)...)
+			if route.Method != http.MethodGet && route.Method != http.MethodDelete {
+				if schema := schemas.requestSchema(typed.req); schema != nil {
+					operation.RequestBody = &RequestBody{
+						Required: len(schema.Required) > 0,
+						Content:  map[string]*MediaType{"application/json": {Schema: schema}},
+					}
+				}
+			}
+		}
+		codes := strings.Split(route.Meta[MetaResponses], ",")
+		if route.Meta[MetaResponses] == "" {
+			codes = []string{"default"}
+			if typed != nil {
+				codes = []string{"200", "default"}
+			}
+		}
+		for _, code := range codes {
+			code = strings.TrimSpace(code)
+			status, _ := strconv.Atoi(code)
+			description := http.StatusText(status)
+			if description == "" {
+				description = "response"
+			}
+			media := &MediaType{}
+			if typed != nil && strings.HasSuffix(produces, "json") {
+				media.Schema = responseSchema(schemas, typed.resp, status, envelope, customErrors)
+			}
+			operation.Responses[code] = &Response{
+				Description: description,
+				Content:     map[string]*MediaType{produces: media},
+			}
+		}
+		spec.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+	if len(schemas.schemas) > 0 {
+		spec.Components = &Components{Schemas: schemas.schemas}
+	}
+	return spec
+}
+
+// responseSchema 返回Typed路由某个状态码的响应schema：2xx是Resp，其余是错误响应
+func responseSchema(schemas *schemaBuilder, resp reflect.Type, status int, envelope *EnvelopeConfig, customErrors bool) *Schema {
+	success := status >= 200 && status < 300
+	if !success && customErrors {
+		return nil
+	}
+	if envelope != nil {
+		data := &Schema{Nullable: true}
+		if success {
+			data = schemas.schema(resp)
+		}
+		return &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"code":      {Type: "string"},
+				"message":   {Type: "string"},
+				"data":      data,
+				"trace_id":  {Type: "string"},
+				"timestamp": {Type: "integer", Format: "int64"},
+			},
+			Required: []string{"code", "message", "data", "timestamp"},
+		}
+	}
+	if success {
+		return schemas.schema(resp)
+	}
+	return schemas.schema(reflect.TypeOf(ErrorResponse{}))
+}
+
+// headerParameters 返回Req中header标签的字段对应的请求头参数
+func headerParameters(req reflect.Type) []Parameter {
+	for req.Kind() == reflect.Pointer {
+		req = req.Elem()
+	}
+	if req.Kind() != reflect.Struct {
+		return nil
+	}
+	var parameters []Parameter
+	for _, field := range boundFields(req, "header") {
+		parameters = append(parameters, Parameter{
+			Name: field.name, In: "header", Required: field.required, Schema: map[string]string{"type": "string"},
+		})
+	}
+	return parameters
+}
+
+// OpenAPI returns the OpenAPI document of the app's routes.
+func (app *App) OpenAPI(title, version string) *OpenAPI {
+	return buildOpenAPI(title, version, app.Routes(), app.envelope, app.errorHandler != nil)
+}
+
+// Handler returns the handler serving the app's routes: Server.Handler or
+// the mux routes are registered on, AppConfig.Mux or the default mux.
+func (app *App) Handler() http.Handler {
+	if app.Server.Handler != nil {
+		return app.Server.Handler
+	}
+	return app.mux
+}
+
+// Schema is the subset of the OpenAPI schema object generated from Go
+// types. Named struct types are emitted once under components and
+// referenced with Ref.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+var (
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	schemaNameInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// schemaBuilder 把Go类型转换为schema，具名结构体收集到schemas中
+type schemaBuilder struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// requestSchema 返回请求体的schema；uri和header标签的字段不从请求体读取，不计入
+func (b *schemaBuilder) requestSchema(typ reflect.Type) *Schema {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType || implementsMarshaler(typ) {
+		return b.schema(typ)
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.structFields(typ, schema, true)
+	if len(schema.Properties) == 0 {
+		return nil
+	}
+	return schema
+}
+
+func (b *schemaBuilder) schema(typ reflect.Type) *Schema {
+	if typ.Kind() == reflect.Pointer {
+		elem := b.schema(typ.Elem())
+		if elem.Ref != "" {
+			// $ref的同级字段会被忽略，可空的引用只能保持原样
+			return elem
+		}
+		elem.Nullable = true
+		return elem
+	}
+	switch {
+	case typ == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typ == rawMessageType:
+		return &Schema{}
+	case typ.Implements(jsonMarshalerType) || reflect.PointerTo(typ).Implements(jsonMarshalerType):
+		// 自定义JSON编码的类型无法推断结构
+		return &Schema{}
+	case typ.Implements(textMarshalerType) || reflect.PointerTo(typ).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: b.schema(typ.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(typ.Elem()), Nullable: true}
+	case reflect.Struct:
+		return b.structSchema(typ)
+	}
+	// interface{}等任意值
+	return &Schema{}
+}
+
+func implementsMarshaler(typ reflect.Type) bool {
+	ptr := reflect.PointerTo(typ)
+	return typ.Implements(jsonMarshalerType) || ptr.Implements(jsonMarshalerType) ||
+		typ.Implements(textMarshalerType) || ptr.Implements(textMarshalerType)
+}
+
+// structSchema 返回结构体的schema，具名结构体放入components并返回引用
+func (b *schemaBuilder) structSchema(typ reflect.Type) *Schema {
+	if typ.Name() == "" {
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		b.structFields(typ, schema, false)
+		return schema
+	}
+	if name, ok := b.names[typ]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	name := schemaNameInvalid.ReplaceAllString(typ.Name(), "_")
+	for i := 2; b.schemas[name] != nil; i++ {
+		// 不同包的同名类型
+		name = schemaNameInvalid.ReplaceAllString(typ.Name(), "_") + strconv.Itoa(i)
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	// 先登记再展开字段，递归类型引用自身
+	b.names[typ] = name
+	b.schemas[name] = schema
+	b.structFields(typ, schema, false)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// structFields 按encoding/json的规则收集字段；binding:"required"的字段为必填
+func (b *schemaBuilder) structFields(typ reflect.Type, schema *Schema, request bool) {
+	var embedded []reflect.Type
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" && tag == "-" {
+			continue
+		}
+		if request && tag == "" && (field.Tag.Get("uri") != "" || field.Tag.Get("header") != "") {
+			continue
+		}
+		if name == "" && field.Anonymous {
+			inner := field.Type
+			if inner.Kind() == reflect.Pointer {
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct {
+				embedded = append(embedded, inner)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := schema.Properties[name]; ok {
+			continue
+		}
+		schema.Properties[name] = b.schema(field.Type)
+		for _, option := range strings.Split(field.Tag.Get("binding"), ",") {
+			if strings.TrimSpace(option) == "required" {
+				schema.Required = append(schema.Required, name)
+				break
+			}
+		}
+	}
+	// 内嵌结构体的字段最后展开，外层的同名字段优先
+	for _, inner := range embedded {
+		b.structFields(inner, schema, request)
+	}
+}
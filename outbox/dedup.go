@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/suonanjiexi/cyber/queue"
+)
+
+// DedupStore remembers the dedup keys of messages being handled or
+// handled. Claim must be atomic, so that of two concurrent deliveries of
+// one message only one is handled.
+type DedupStore interface {
+	// Claim 记录key并返回true；key已被记录时返回false
+	Claim(key string) bool
+	// Release 在处理失败后删除key，消息重新投递时可以再次处理
+	Release(key string)
+}
+
+// MemoryDedup keeps the most recent dedup keys of one process.
+type MemoryDedup struct {
+	mu    sync.Mutex
+	size  int
+	keys  map[string]*list.Element
+	order *list.List
+}
+
+// 未指定大小时记住的键数
+const defaultDedupSize = 10000
+
+// NewMemoryDedup remembers up to size keys, evicting the oldest. A size of
+// 0 or less uses the default of 10000.
+func NewMemoryDedup(size int) *MemoryDedup {
+	if size <= 0 {
+		size = defaultDedupSize
+	}
+	return &MemoryDedup{size: size, keys: make(map[string]*list.Element), order: list.New()}
+}
+
+func (d *MemoryDedup) Claim(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.keys[key]; ok {
+		return false
+	}
+	d.keys[key] = d.order.PushBack(key)
+	for d.order.Len() > d.size {
+		oldest := d.order.Front()
+		d.order.Remove(oldest)
+		delete(d.keys, oldest.Value.(string))
+	}
+	return true
+}
+
+func (d *MemoryDedup) Release(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if element, ok := d.keys[key]; ok {
+		d.order.Remove(element)
+		delete(d.keys, key)
+	}
+}
+
+// Dedup is a queue middleware that acks messages whose dedup key was
+// already claimed without calling the handler. The key is claimed before
+// the handler runs, so a repeat delivered while the first is still being
+// handled is dropped too; it is released if the handler fails, letting the
+// broker's redelivery retry it. Messages without a key are always handled.
+func Dedup(store DedupStore) queue.Middleware {
+	return func(next queue.Handler) queue.Handler {
+		return func(ctx context.Context, msg *queue.Message) error {
+			key := msg.Header[HeaderDedupKey]
+			if key == "" {
+				return next(ctx, msg)
+			}
+			if !store.Claim(key) {
+				return nil
+			}
+			handled := false
+			defer func() {
+				// 处理失败或panic时释放，消息重新投递时再次处理
+				if !handled {
+					store.Release(key)
+				}
+			}()
+			if err := next(ctx, msg); err != nil {
+				return err
+			}
+			handled = true
+			return nil
+		}
+	}
+}
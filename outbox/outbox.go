@@ -0,0 +1,406 @@
+// Package outbox implements the transactional outbox: events are inserted
+// in the same database transaction as the business data and a background
+// relay publishes them to a queue broker afterwards. Delivery is at least
+// once; every event carries a dedup key so consumers can drop repeats with
+// Dedup. Several instances may relay the same table: each claims a batch of
+// events with a lease before publishing it.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+	"github.com/suonanjiexi/cyber/db"
+	"github.com/suonanjiexi/cyber/id"
+	"github.com/suonanjiexi/cyber/queue"
+)
+
+// HeaderDedupKey is the message header carrying an event's dedup key.
+const HeaderDedupKey = "Dedup-Key"
+
+// Publisher sends a message to a topic. queue.MemoryBroker implements it.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg *queue.Message) error
+}
+
+// PublisherFunc adapts a function to Publisher, e.g. to publish to a hub.
+type PublisherFunc func(ctx context.Context, topic string, msg *queue.Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, topic string, msg *queue.Message) error {
+	return f(ctx, topic, msg)
+}
+
+type Config struct {
+	// 表名，默认 cyber_outbox
+	Table string
+	// 建表语句，默认按sqlite编写；其他数据库需要提供等价的语句，%s 会替换为表名
+	Schema string
+	// Placeholder 返回第n个参数（从1开始）的占位符，默认 "?"；PostgreSQL 使用 Dollar
+	Placeholder func(n int) string
+	// 轮询未发布事件的间隔，默认1秒
+	Interval time.Duration
+	// 每次轮询最多发布的事件数，默认100
+	BatchSize int
+	// 事件发布失败的最大次数，达到后转入死信，默认10
+	MaxAttempts int
+	// 认领一批事件的租约时长，实例在租约内未发布完时其他实例可以重新认领，默认30秒
+	Lease time.Duration
+	// Claim 是认领事件的UPDATE语句模板，默认适用于sqlite和PostgreSQL。
+	// %[1]s 是表名，%[5]d 是批量大小；%[2]s、%[3]s、%[4]s 分别是认领标识、租约到期时间和当前时间（Unix毫秒），
+	// 可以重复出现，按出现顺序替换为占位符
+	Claim string
+	// OnDead 在事件转入死信时调用，例如用于告警
+	OnDead func(event *Event, err error)
+}
+
+const defaultSchema = `CREATE TABLE IF NOT EXISTS %s (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	dedup_key  TEXT NOT NULL UNIQUE,
+	topic      TEXT NOT NULL,
+	msg_key    TEXT NOT NULL,
+	body       BLOB,
+	header     TEXT NOT NULL,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL,
+	locked_by    TEXT,
+	locked_until INTEGER NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	dead_at      TIMESTAMP
+)`
+
+// 认领可发布的事件：未转入死信、不在租约或重试等待中，并且同一消息键没有更早的事件在租约或重试等待中，保证同键事件按顺序发布
+const defaultClaim = `UPDATE %[1]s SET locked_by = %[2]s, locked_until = %[3]s
+WHERE dead_at IS NULL AND locked_until < %[4]s AND id IN (
+	SELECT id FROM %[1]s o WHERE dead_at IS NULL AND locked_until < %[4]s AND (msg_key = '' OR NOT EXISTS (
+		SELECT 1 FROM %[1]s e WHERE e.msg_key = o.msg_key AND e.id < o.id AND e.dead_at IS NULL AND e.locked_until >= %[4]s
+	)) ORDER BY id LIMIT %[5]d
+)`
+
+var defaultConfig = Config{
+	Table:       "cyber_outbox",
+	Schema:      defaultSchema,
+	Placeholder: func(int) string { return "?" },
+	Interval:    time.Second,
+	BatchSize:   100,
+	MaxAttempts: 10,
+	Lease:       30 * time.Second,
+	Claim:       defaultClaim,
+}
+
+var claimVerb = regexp.MustCompile(`%\[([1-5])\][sd]`)
+
+// 重试等待的上限
+const maxBackoff = 10 * time.Minute
+
+// Dollar is the PostgreSQL placeholder style: $1, $2, ...
+func Dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Event is a message waiting in the outbox.
+type Event struct {
+	ID       int64
+	Topic    string
+	Key      string
+	Body     []byte
+	Header   map[string]string
+	DedupKey string
+	Attempts int
+	Created  time.Time
+	// LastError 是最近一次发布失败的错误
+	LastError string
+}
+
+// Outbox writes events and relays them. Use New to create one.
+type Outbox struct {
+	db        *sql.DB
+	publisher Publisher
+	config    Config
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// New creates an Outbox whose relay starts with app and is stopped in its
+// stop hooks. Stop hooks run in reverse order, so with database from
+// db.Open the relay stops before the database is closed.
+func New(app *cyber.App, database *sql.DB, publisher Publisher, config Config) *Outbox {
+	if config.Table == "" {
+		config.Table = defaultConfig.Table
+	}
+	if config.Schema == "" {
+		config.Schema = defaultConfig.Schema
+	}
+	if config.Placeholder == nil {
+		config.Placeholder = defaultConfig.Placeholder
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultConfig.Interval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultConfig.BatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultConfig.MaxAttempts
+	}
+	if config.Lease <= 0 {
+		config.Lease = defaultConfig.Lease
+	}
+	if config.Claim == "" {
+		config.Claim = defaultConfig.Claim
+	}
+	o := &Outbox{db: database, publisher: publisher, config: config}
+	app.OnStart(o.Start)
+	app.OnStop(o.Shutdown)
+	return o
+}
+
+// Migrate creates the outbox table if it doesn't exist. Tables created by
+// earlier versions need the locked_by, locked_until, last_error and
+// dead_at columns added by hand.
+func (o *Outbox) Migrate(ctx context.Context) error {
+	_, err := o.db.ExecContext(ctx, fmt.Sprintf(o.config.Schema, o.config.Table))
+	return err
+}
+
+// Add inserts msg for topic through q, which should be the transaction
+// that writes the business data (db.Conn inside middleware.Transaction),
+// so the event exists only if that transaction commits. A dedup key is
+// generated unless msg.Header already has one; adding the same key twice
+// fails on the table's unique constraint.
+func (o *Outbox) Add(ctx context.Context, q db.Querier, topic string, msg *queue.Message) error {
+	header := make(map[string]string, len(msg.Header)+1)
+	for key, value := range msg.Header {
+		header[key] = value
+	}
+	if header[HeaderDedupKey] == "" {
+		header[HeaderDedupKey] = id.NewUUIDString()
+	}
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (dedup_key, topic, msg_key, body, header, created_at) VALUES (%s)`,
+		o.config.Table, o.placeholders(1, 6))
+	_, err = q.ExecContext(ctx, query, header[HeaderDedupKey], topic, msg.Key, msg.Body, string(encoded), time.Now())
+	return err
+}
+
+// AddRequest is Add using the request's transaction.
+func (o *Outbox) AddRequest(r *http.Request, topic string, msg *queue.Message) error {
+	return o.Add(r.Context(), db.Conn(r, o.db), topic, msg)
+}
+
+// Pending returns up to limit unpublished events that are not dead
+// lettered, oldest first.
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]*Event, error) {
+	return o.query(ctx, fmt.Sprintf(`WHERE dead_at IS NULL ORDER BY id LIMIT %d`, limit))
+}
+
+// Dead returns up to limit events that failed MaxAttempts times, oldest
+// first. They stay in the table until Requeue or deleted by hand.
+func (o *Outbox) Dead(ctx context.Context, limit int) ([]*Event, error) {
+	return o.query(ctx, fmt.Sprintf(`WHERE dead_at IS NOT NULL ORDER BY id LIMIT %d`, limit))
+}
+
+// Requeue moves a dead lettered event back to the pending events with its
+// attempts reset.
+func (o *Outbox) Requeue(ctx context.Context, id int64) error {
+	return o.exec(ctx, `UPDATE %s SET dead_at = NULL, attempts = 0, locked_by = NULL, locked_until = 0 WHERE id = %s`, id)
+}
+
+func (o *Outbox) query(ctx context.Context, where string, args ...interface{}) ([]*Event, error) {
+	query := fmt.Sprintf(`SELECT id, dedup_key, topic, msg_key, body, header, attempts, created_at, last_error FROM %s `, o.config.Table) + where
+	rows, err := o.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []*Event
+	for rows.Next() {
+		event := &Event{}
+		var header string
+		var lastError sql.NullString
+		if err := rows.Scan(&event.ID, &event.DedupKey, &event.Topic, &event.Key, &event.Body, &header, &event.Attempts, &event.Created, &lastError); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(header), &event.Header); err != nil {
+			return nil, fmt.Errorf("outbox: event %d: %w", event.ID, err)
+		}
+		event.LastError = lastError.String
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// claim 以新的认领标识为本实例租下一批事件并返回它们
+func (o *Outbox) claim(ctx context.Context) ([]*Event, error) {
+	owner := id.NewUUIDString()
+	now := time.Now()
+	values := map[string]interface{}{"2": owner, "3": now.Add(o.config.Lease).UnixMilli(), "4": now.UnixMilli()}
+	// 按出现顺序编号占位符，"?"风格的占位符不能重复引用同一个参数
+	var args []interface{}
+	query := claimVerb.ReplaceAllStringFunc(o.config.Claim, func(verb string) string {
+		switch n := claimVerb.FindStringSubmatch(verb)[1]; n {
+		case "1":
+			return o.config.Table
+		case "5":
+			return strconv.Itoa(o.config.BatchSize)
+		default:
+			args = append(args, values[n])
+			return o.config.Placeholder(len(args))
+		}
+	})
+	result, err := o.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, nil
+	}
+	return o.query(ctx, fmt.Sprintf(`WHERE locked_by = %s ORDER BY id`, o.config.Placeholder(1)), owner)
+}
+
+// Relay claims one batch of pending events, publishes them in order and
+// deletes the published ones. A failed event is retried after a backoff
+// that doubles with every attempt; after MaxAttempts it is dead lettered
+// and OnDead is called. Later events with the same message key wait for
+// it, so events of one key keep their order, while events of other keys
+// go on. It returns how many events were claimed and the publish errors.
+func (o *Outbox) Relay(ctx context.Context) (int, error) {
+	events, err := o.claim(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: claim events: %w", err)
+	}
+	var errs []error
+	// failedKeys 是本批中发布失败的消息键，后续同键事件释放租约留待下次
+	failedKeys := make(map[string]bool)
+	for _, event := range events {
+		if event.Key != "" && failedKeys[event.Key] {
+			o.exec(ctx, `UPDATE %s SET locked_by = NULL, locked_until = 0 WHERE id = %s`, event.ID)
+			continue
+		}
+		msg := &queue.Message{Topic: event.Topic, Key: event.Key, Body: event.Body, Header: event.Header}
+		if err := o.publisher.Publish(ctx, event.Topic, msg); err != nil {
+			if ctx.Err() != nil {
+				// 停止中：租约到期后由下一次轮询重新发布，不计入失败次数
+				return len(events), errors.Join(append(errs, ctx.Err())...)
+			}
+			failedKeys[event.Key] = true
+			errs = append(errs, fmt.Errorf("outbox: publish event %d to %s: %w", event.ID, event.Topic, err))
+			if err := o.fail(ctx, event, err); err != nil {
+				errs = append(errs, fmt.Errorf("outbox: record failure of event %d: %w", event.ID, err))
+			}
+			continue
+		}
+		// 删除失败时事件会被再次发布，由消费者按去重键丢弃
+		if err := o.exec(ctx, `DELETE FROM %s WHERE id = %s`, event.ID); err != nil {
+			errs = append(errs, fmt.Errorf("outbox: delete event %d: %w", event.ID, err))
+		}
+	}
+	return len(events), errors.Join(errs...)
+}
+
+// fail 记录一次发布失败：达到MaxAttempts时转入死信，否则把租约延长为重试等待时间
+func (o *Outbox) fail(ctx context.Context, event *Event, cause error) error {
+	event.Attempts++
+	event.LastError = cause.Error()
+	p := o.config.Placeholder
+	if event.Attempts >= o.config.MaxAttempts {
+		query := fmt.Sprintf(`UPDATE %s SET attempts = %s, last_error = %s, dead_at = %s, locked_by = NULL WHERE id = %s`,
+			o.config.Table, p(1), p(2), p(3), p(4))
+		if _, err := o.db.ExecContext(ctx, query, event.Attempts, event.LastError, time.Now(), event.ID); err != nil {
+			return err
+		}
+		log.Printf("Outbox event %d dead lettered after %d attempts: %v", event.ID, event.Attempts, cause)
+		if o.config.OnDead != nil {
+			o.config.OnDead(event, cause)
+		}
+		return nil
+	}
+	backoff := min(o.config.Interval<<min(event.Attempts-1, 16), maxBackoff)
+	query := fmt.Sprintf(`UPDATE %s SET attempts = %s, last_error = %s, locked_by = NULL, locked_until = %s WHERE id = %s`,
+		o.config.Table, p(1), p(2), p(3), p(4))
+	_, err := o.db.ExecContext(ctx, query, event.Attempts, event.LastError, time.Now().Add(backoff).UnixMilli(), event.ID)
+	return err
+}
+
+func (o *Outbox) exec(ctx context.Context, query string, id int64) error {
+	_, err := o.db.ExecContext(ctx, fmt.Sprintf(query, o.config.Table, o.config.Placeholder(1)), id)
+	return err
+}
+
+func (o *Outbox) placeholders(from, n int) string {
+	marks := make([]string, n)
+	for i := range marks {
+		marks[i] = o.config.Placeholder(from + i)
+	}
+	return strings.Join(marks, ", ")
+}
+
+// Start runs the relay in the background every Interval. It is registered
+// as a start hook by New.
+func (o *Outbox) Start(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.cancel != nil {
+		return nil
+	}
+	relayCtx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+	o.done = make(chan struct{})
+	go o.run(relayCtx)
+	return nil
+}
+
+func (o *Outbox) run(ctx context.Context) {
+	defer close(o.done)
+	ticker := time.NewTicker(o.config.Interval)
+	defer ticker.Stop()
+	for {
+		// 一批发满时立即继续，积压的事件不必等下一个周期
+		n, err := o.Relay(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Outbox relay error: %v", err)
+		}
+		if err == nil && n == o.config.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops the relay, waiting for the batch in flight. Events still
+// pending are published on the next start. It is registered as a stop hook
+// by New.
+func (o *Outbox) Shutdown(ctx context.Context) error {
+	o.mu.Lock()
+	cancel, done := o.cancel, o.done
+	o.cancel = nil
+	o.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,135 @@
+// Package pathmatch matches requests against path patterns, as used by the
+// SkipPaths options of middlewares.
+package pathmatch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches requests against a list of patterns. A pattern is one of:
+//
+//	/health          the exact path
+//	/internal/*      a glob: * matches within a path segment, ** and a
+//	                 trailing * match across segments, ? matches one character
+//	~^/v[0-9]+/debug a regular expression, prefixed with ~
+//
+// and may start with methods to limit it to, e.g. "GET /health" or
+// "GET,HEAD /static/*". A nil Matcher matches nothing.
+type Matcher struct {
+	rules []pathRule
+}
+
+type pathRule struct {
+	methods []string
+	exact   string
+	pattern *regexp.Regexp
+}
+
+// New compiles patterns, returning an error for an invalid one.
+func New(patterns ...string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, p := range patterns {
+		rule, err := parsePathRule(p)
+		if err != nil {
+			return nil, err
+		}
+		m.rules = append(m.rules, rule)
+	}
+	return m, nil
+}
+
+// Must is New panicking on an invalid pattern, for patterns fixed in the
+// configuration.
+func Must(patterns ...string) *Matcher {
+	m, err := New(patterns...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func parsePathRule(p string) (pathRule, error) {
+	var rule pathRule
+	p = strings.TrimSpace(p)
+	// 方法与路径以空格分隔，路径总是以 / 或 ~ 开头
+	if methods, rest, ok := strings.Cut(p, " "); ok && !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "~") {
+		rule.methods = strings.Split(strings.ToUpper(methods), ",")
+		p = strings.TrimSpace(rest)
+	}
+	switch {
+	case strings.HasPrefix(p, "~"):
+		re, err := regexp.Compile(p[1:])
+		if err != nil {
+			return rule, fmt.Errorf("pathmatch: invalid pattern %q: %w", p, err)
+		}
+		rule.pattern = re
+	case strings.ContainsAny(p, "*?"):
+		rule.pattern = regexp.MustCompile(globRegexp(p))
+	case p == "":
+		return rule, errors.New("pathmatch: empty pattern")
+	default:
+		rule.exact = p
+	}
+	return rule, nil
+}
+
+// globRegexp 把通配符模式转换为锚定的正则表达式
+func globRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*' && i == len(glob)-1:
+			b.WriteString(".*")
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// Match reports whether any pattern matches the request's method and path.
+func (m *Matcher) Match(r *http.Request) bool {
+	return m.MatchPath(r.Method, r.URL.Path)
+}
+
+// MatchPath reports whether any pattern matches method and path.
+func (m *Matcher) MatchPath(method, path string) bool {
+	if m == nil {
+		return false
+	}
+	for _, rule := range m.rules {
+		if len(rule.methods) > 0 && !containsMethod(rule.methods, method) {
+			continue
+		}
+		if rule.pattern != nil {
+			if rule.pattern.MatchString(path) {
+				return true
+			}
+		} else if rule.exact == path {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package cyber
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 逐跳请求头只对单个连接有效，不能转发
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+var proxyBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 32<<10)
+		return &b
+	},
+}
+
+// ProxyBody streams an upstream response to the client: status and headers
+// minus hop-by-hop ones, then the body in chunks, flushing after each one so
+// large files are never buffered whole. Copying stops when the client goes
+// away. resp.Body is always closed.
+func ProxyBody(w http.ResponseWriter, r *http.Request, resp *http.Response) error {
+	defer resp.Body.Close()
+	header := w.Header()
+	for key, values := range resp.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	removeHopHeaders(header, resp.Header)
+	w.WriteHeader(resp.StatusCode)
+
+	rc := http.NewResponseController(w)
+	bufp := proxyBufferPool.Get().(*[]byte)
+	defer proxyBufferPool.Put(bufp)
+	buf := *bufp
+	for {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return nil
+			}
+			return readErr
+		}
+	}
+}
+
+// removeHopHeaders 删除逐跳请求头以及上游Connection头中列出的请求头
+func removeHopHeaders(header, upstream http.Header) {
+	for _, value := range upstream.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
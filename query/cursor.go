@@ -0,0 +1,210 @@
+package query
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// Pagination issues and checks the opaque cursors of keyset-paginated list
+// endpoints. A cursor carries the sort key of the last item of a page,
+// e.g. struct{ CreatedAt time.Time; ID int64 }, signed with HMAC-SHA256
+// so clients can't forge positions, and is bound to the endpoint's path
+// and other query parameters so it can't be replayed with a different
+// filter or sort.
+type Pagination struct {
+	// 签名密钥；为空时每个进程随机生成，游标在重启后或其他实例上失效
+	Secret []byte
+	// 游标的有效期，0表示不过期
+	TTL time.Duration
+	// limit 参数的默认值和最大值，默认20和100
+	DefaultLimit int
+	MaxLimit     int
+
+	once sync.Once
+	key  []byte
+}
+
+// Page is the response envelope of a paginated list. NextCursor is empty
+// on the last page.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// cursorPayload 是游标签名前的内容
+type cursorPayload struct {
+	Scope   string          `json:"s"`
+	Expires int64           `json:"e,omitempty"`
+	Value   json.RawMessage `json:"v"`
+}
+
+func (p *Pagination) secret() []byte {
+	p.once.Do(func() {
+		p.key = p.Secret
+		if len(p.key) == 0 {
+			p.key = make([]byte, 32)
+			rand.Read(p.key)
+		}
+	})
+	return p.key
+}
+
+// Limit returns the page size requested by ?limit, DefaultLimit when
+// absent and at most MaxLimit.
+func (p *Pagination) Limit(r *http.Request) (int, error) {
+	defaultLimit, maxLimit := p.DefaultLimit, p.MaxLimit
+	if defaultLimit <= 0 {
+		defaultLimit = 20
+	}
+	if maxLimit <= 0 {
+		maxLimit = 100
+	}
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return min(defaultLimit, maxLimit), nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return 0, cyber.NewHTTPError(http.StatusBadRequest, "invalid_query", "limit must be a positive integer")
+	}
+	return min(limit, maxLimit), nil
+}
+
+// Encode returns a cursor for the position v on the endpoint of r.
+func (p *Pagination) Encode(r *http.Request, v interface{}) (string, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := cursorPayload{Scope: scope(r), Value: value}
+	if p.TTL > 0 {
+		payload.Expires = time.Now().Add(p.TTL).Unix()
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(p.sign(data)), nil
+}
+
+// Decode reads ?cursor into v and reports whether one was given. A
+// forged, expired or foreign cursor is a 400 *cyber.HTTPError.
+func (p *Pagination) Decode(r *http.Request, v interface{}) (bool, error) {
+	token := r.URL.Query().Get("cursor")
+	if token == "" {
+		return false, nil
+	}
+	invalid := cyber.NewHTTPError(http.StatusBadRequest, "invalid_cursor", "cursor is invalid or expired")
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false, invalid
+	}
+	data, err1 := base64.RawURLEncoding.DecodeString(encoded)
+	mac, err2 := base64.RawURLEncoding.DecodeString(signature)
+	if err1 != nil || err2 != nil || !hmac.Equal(mac, p.sign(data)) {
+		return false, invalid
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false, invalid
+	}
+	if payload.Scope != scope(r) || (payload.Expires != 0 && time.Now().Unix() > payload.Expires) {
+		return false, invalid
+	}
+	if err := json.Unmarshal(payload.Value, v); err != nil {
+		return false, invalid
+	}
+	return true, nil
+}
+
+func (p *Pagination) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret())
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// scope 是路径加上除cursor和limit以外的查询参数的摘要，换了过滤或排序的游标无效
+func scope(r *http.Request) string {
+	values := r.URL.Query()
+	values.Del("cursor")
+	values.Del("limit")
+	sum := sha256.Sum256([]byte(r.URL.Path + "?" + values.Encode()))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// NewPage builds the page for items, which the handler fetched with
+// LIMIT limit+1: a surplus item means there is a next page, whose cursor
+// is key of the last item kept. The next page's URL is also set as a Link
+// header with rel="next".
+func NewPage[T any](w http.ResponseWriter, r *http.Request, p *Pagination, items []T, limit int, key func(item T) interface{}) (Page[T], error) {
+	page := Page[T]{Data: items}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(items) <= limit || limit <= 0 {
+		return page, nil
+	}
+	page.Data, page.HasMore = items[:limit], true
+	cursor, err := p.Encode(r, key(items[limit-1]))
+	if err != nil {
+		return page, err
+	}
+	page.NextCursor = cursor
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, cursor)))
+	return page, nil
+}
+
+func pageURL(r *http.Request, cursor string) string {
+	values := r.URL.Query()
+	values.Set("cursor", cursor)
+	u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+	return u.String()
+}
+
+// Seek returns the keyset condition selecting the rows after the position
+// values, which holds the cursor's values in the order of q.Sort. The
+// last sort field must be unique, e.g. the primary key, so no row is
+// skipped or repeated between pages. Placeholders are numbered as in
+// Where.
+func (q *Query) Seek(values []interface{}, placeholder func(n int) string, start int) (string, []interface{}, error) {
+	if len(values) != len(q.Sort) {
+		return "", nil, fmt.Errorf("query: seek needs %d values, got %d", len(q.Sort), len(values))
+	}
+	if placeholder == nil {
+		placeholder = func(int) string { return "?" }
+	}
+	var args []interface{}
+	next := func(value interface{}) string {
+		args = append(args, value)
+		return placeholder(start + len(args) - 1)
+	}
+	// (a > ?) OR (a = ? AND b > ?) OR ...，升降序混合时行比较 (a, b) > (?, ?) 不适用
+	var clauses []string
+	for i, order := range q.Sort {
+		var parts bytes.Buffer
+		for j := 0; j < i; j++ {
+			fmt.Fprintf(&parts, "%s = %s AND ", q.Sort[j].Column, next(values[j]))
+		}
+		op := ">"
+		if order.Desc {
+			op = "<"
+		}
+		fmt.Fprintf(&parts, "%s %s %s", order.Column, op, next(values[i]))
+		clauses = append(clauses, "("+parts.String()+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args, nil
+}
@@ -0,0 +1,332 @@
+// Package query parses the filtering and sorting parameters of list
+// endpoints, e.g.
+//
+//	?filter[status]=active&filter[age][gte]=18&sort=-created_at,name
+//
+// into a Query validated against a Schema, an allowlist of fields and
+// operators, and translates it to SQL for repositories. Pagination adds
+// keyset pagination with signed cursors on top of the sort.
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// Op is a filter operator, written as filter[field][op]=value. A filter
+// without an operator uses Eq.
+type Op string
+
+const (
+	Eq  Op = "eq"
+	Ne  Op = "ne"
+	Gt  Op = "gt"
+	Gte Op = "gte"
+	Lt  Op = "lt"
+	Lte Op = "lte"
+	// In 的值以逗号分隔，例如 filter[status][in]=active,pending
+	In Op = "in"
+	// Like 匹配包含该值的字符串
+	Like Op = "like"
+	// Null 的值为 true 或 false，匹配字段为空或非空
+	Null Op = "null"
+)
+
+// Type is the type filter values of a field are converted to.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	// Time 接受 RFC 3339 时间或 2006-01-02 格式的日期
+	Time
+)
+
+// Field declares a field that may be filtered or sorted on.
+type Field struct {
+	// 数据库列名，默认与字段名相同
+	Column string
+	Type   Type
+	// 允许的操作符，默认按类型：字符串 eq、ne、in、like；数字和时间另加比较；布尔 eq、ne
+	Ops      []Op
+	Sortable bool
+}
+
+// Schema is the allowlist a Query is validated against.
+type Schema struct {
+	Fields map[string]Field
+	// 没有 sort 参数时的排序
+	DefaultSort []Order
+	// 最多的过滤条件数，默认10
+	MaxFilters int
+}
+
+// Condition is one validated filter.
+type Condition struct {
+	Field  string
+	Column string
+	Op     Op
+	// Value 已按字段类型转换；In 为 []interface{}，Null 为 bool
+	Value interface{}
+}
+
+// Order is one sort key.
+type Order struct {
+	Field  string
+	Column string
+	Desc   bool
+}
+
+// Query is a parsed and validated filter and sort.
+type Query struct {
+	Filters []Condition
+	Sort    []Order
+}
+
+const defaultMaxFilters = 10
+
+// ParseRequest parses the query string of r. See Parse.
+func (s Schema) ParseRequest(r *http.Request) (*Query, error) {
+	return s.Parse(r.URL.Query())
+}
+
+// Parse reads filter[...] and sort parameters from values. Other
+// parameters are ignored. Unknown fields, disallowed operators and values
+// that don't convert to the field's type are reported as a 400
+// *cyber.HTTPError, ready for cyber.Abort.
+func (s Schema) Parse(values url.Values) (*Query, error) {
+	q := &Query{}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if strings.HasPrefix(key, "filter[") {
+			keys = append(keys, key)
+		}
+	}
+	// 按参数名排序，生成的SQL和参数顺序稳定
+	sort.Strings(keys)
+	maxFilters := s.MaxFilters
+	if maxFilters <= 0 {
+		maxFilters = defaultMaxFilters
+	}
+	for _, key := range keys {
+		name, op, ok := parseFilterKey(key)
+		if !ok {
+			return nil, invalid("malformed filter parameter %q", key)
+		}
+		field, ok := s.Fields[name]
+		if !ok {
+			return nil, invalid("unknown filter field %q", name)
+		}
+		if !allowed(field, op) {
+			return nil, invalid("operator %q is not allowed on %q", op, name)
+		}
+		for _, raw := range values[key] {
+			if len(q.Filters) == maxFilters {
+				return nil, invalid("at most %d filters are allowed", maxFilters)
+			}
+			value, err := convert(field.Type, op, raw)
+			if err != nil {
+				return nil, invalid("filter %q: %v", name, err)
+			}
+			q.Filters = append(q.Filters, Condition{Field: name, Column: column(name, field), Op: op, Value: value})
+		}
+	}
+	if raw := values.Get("sort"); raw != "" {
+		seen := make(map[string]bool)
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.TrimSpace(part)
+			order := Order{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+			field, ok := s.Fields[order.Field]
+			if !ok || !field.Sortable {
+				return nil, invalid("cannot sort by %q", order.Field)
+			}
+			if seen[order.Field] {
+				return nil, invalid("duplicate sort field %q", order.Field)
+			}
+			seen[order.Field] = true
+			order.Column = column(order.Field, field)
+			q.Sort = append(q.Sort, order)
+		}
+	} else {
+		for _, order := range s.DefaultSort {
+			if order.Column == "" {
+				order.Column = column(order.Field, s.Fields[order.Field])
+			}
+			q.Sort = append(q.Sort, order)
+		}
+	}
+	return q, nil
+}
+
+// parseFilterKey 解析 filter[field] 和 filter[field][op]
+func parseFilterKey(key string) (string, Op, bool) {
+	rest := strings.TrimPrefix(key, "filter[")
+	name, rest, ok := strings.Cut(rest, "]")
+	if !ok || name == "" {
+		return "", "", false
+	}
+	if rest == "" {
+		return name, Eq, true
+	}
+	if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") || len(rest) < 3 {
+		return "", "", false
+	}
+	return name, Op(rest[1 : len(rest)-1]), true
+}
+
+func allowed(field Field, op Op) bool {
+	ops := field.Ops
+	if ops == nil {
+		ops = defaultOps(field.Type)
+	}
+	for _, allowed := range ops {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultOps(typ Type) []Op {
+	switch typ {
+	case String:
+		return []Op{Eq, Ne, In, Like}
+	case Bool:
+		return []Op{Eq, Ne}
+	default:
+		return []Op{Eq, Ne, Gt, Gte, Lt, Lte, In}
+	}
+}
+
+func convert(typ Type, op Op, raw string) (interface{}, error) {
+	switch op {
+	case Null:
+		return strconv.ParseBool(raw)
+	case In:
+		parts := strings.Split(raw, ",")
+		values := make([]interface{}, len(parts))
+		for i, part := range parts {
+			value, err := convertValue(typ, strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	return convertValue(typ, raw)
+}
+
+func convertValue(typ Type, raw string) (interface{}, error) {
+	switch typ {
+	case Int:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return n, nil
+	case Float:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return f, nil
+	case Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return b, nil
+	case Time:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a time", raw)
+		}
+		return t, nil
+	}
+	return raw, nil
+}
+
+func column(name string, field Field) string {
+	if field.Column != "" {
+		return field.Column
+	}
+	return name
+}
+
+func invalid(format string, args ...interface{}) error {
+	return cyber.NewHTTPError(http.StatusBadRequest, "invalid_query", fmt.Sprintf(format, args...))
+}
+
+// Dollar is the PostgreSQL placeholder style: $1, $2, ...
+func Dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Where returns the filters as an SQL condition joined with AND, "" when
+// there are none, and its arguments. placeholder returns the placeholder
+// of the nth argument, counting from start; nil means "?". Columns come
+// from the Schema, never from the request.
+func (q *Query) Where(placeholder func(n int) string, start int) (string, []interface{}) {
+	if placeholder == nil {
+		placeholder = func(int) string { return "?" }
+	}
+	var clauses []string
+	var args []interface{}
+	next := func(value interface{}) string {
+		args = append(args, value)
+		return placeholder(start + len(args) - 1)
+	}
+	for _, c := range q.Filters {
+		switch c.Op {
+		case Null:
+			if c.Value.(bool) {
+				clauses = append(clauses, c.Column+" IS NULL")
+			} else {
+				clauses = append(clauses, c.Column+" IS NOT NULL")
+			}
+		case In:
+			values := c.Value.([]interface{})
+			marks := make([]string, len(values))
+			for i, value := range values {
+				marks[i] = next(value)
+			}
+			clauses = append(clauses, c.Column+" IN ("+strings.Join(marks, ", ")+")")
+		case Like:
+			pattern := "%" + likeEscaper.Replace(fmt.Sprint(c.Value)) + "%"
+			clauses = append(clauses, c.Column+" LIKE "+next(pattern)+` ESCAPE '\'`)
+		default:
+			clauses = append(clauses, c.Column+" "+sqlOps[c.Op]+" "+next(c.Value))
+		}
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+var sqlOps = map[Op]string{Eq: "=", Ne: "<>", Gt: ">", Gte: ">=", Lt: "<", Lte: "<="}
+
+// OrderBy returns the sort as an SQL ORDER BY list, e.g.
+// "created_at DESC, name", or "" when unsorted.
+func (q *Query) OrderBy() string {
+	parts := make([]string, len(q.Sort))
+	for i, order := range q.Sort {
+		parts[i] = order.Column
+		if order.Desc {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,75 @@
+//go:build amqp
+
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPBroker consumes RabbitMQ queues; the topic passed to Consume is the
+// queue name. Nacked messages are requeued. Build with -tags amqp.
+type AMQPBroker struct {
+	conn *amqp.Connection
+	// Prefetch 限制每个订阅未确认消息的数量，0表示不限制
+	Prefetch int
+}
+
+func NewAMQPBroker(url string) (*AMQPBroker, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &AMQPBroker{conn: conn}, nil
+}
+
+func (b *AMQPBroker) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if b.Prefetch > 0 {
+		if err := ch.Qos(b.Prefetch, 0, false); err != nil {
+			ch.Close()
+			return nil, err
+		}
+	}
+	// 消费被取消后deliveries会被关闭
+	deliveries, err := ch.ConsumeWithContext(ctx, topic, "", false, false, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+	out := make(chan *Message)
+	go func() {
+		defer close(out)
+		defer ch.Close()
+		for d := range deliveries {
+			header := make(map[string]string, len(d.Headers))
+			for key, value := range d.Headers {
+				header[key] = fmt.Sprint(value)
+			}
+			d := d
+			msg := &Message{
+				Topic:  topic,
+				Key:    d.RoutingKey,
+				Body:   d.Body,
+				Header: header,
+				Ack:    func() error { return d.Ack(false) },
+				Nack:   func() error { return d.Nack(false, true) },
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				d.Nack(false, true)
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *AMQPBroker) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,73 @@
+//go:build kafka
+
+package queue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaBroker consumes Kafka topics as a consumer group. Build with
+// -tags kafka.
+//
+// Acked messages are committed. Kafka has no per-message redelivery, so a
+// nacked message is left uncommitted and is delivered again only after a
+// restart or rebalance, once no later message of its partition committed.
+type KafkaBroker struct {
+	brokers []string
+	groupID string
+	mu      sync.Mutex
+	readers []*kafka.Reader
+}
+
+func NewKafkaBroker(brokers []string, groupID string) *KafkaBroker {
+	return &KafkaBroker{brokers: brokers, groupID: groupID}
+}
+
+func (b *KafkaBroker) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: b.brokers, GroupID: b.groupID, Topic: topic})
+	b.mu.Lock()
+	b.readers = append(b.readers, reader)
+	b.mu.Unlock()
+	out := make(chan *Message)
+	go func() {
+		defer close(out)
+		for {
+			m, err := reader.FetchMessage(ctx)
+			if err != nil {
+				return
+			}
+			header := make(map[string]string, len(m.Headers))
+			for _, h := range m.Headers {
+				header[h.Key] = string(h.Value)
+			}
+			msg := &Message{
+				Topic:  m.Topic,
+				Key:    string(m.Key),
+				Body:   m.Value,
+				Header: header,
+				Ack:    func() error { return reader.CommitMessages(context.Background(), m) },
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *KafkaBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var err error
+	for _, reader := range b.readers {
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
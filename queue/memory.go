@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MemoryBroker is an in-process Broker for development and tests.
+// Subscribers of a topic share its messages; nacked messages are requeued.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]chan *Message
+	buffer int
+	closed bool
+}
+
+func NewMemoryBroker(buffer int) *MemoryBroker {
+	return &MemoryBroker{topics: make(map[string]chan *Message), buffer: buffer}
+}
+
+func (b *MemoryBroker) topic(name string) chan *Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch, ok := b.topics[name]
+	if !ok {
+		ch = make(chan *Message, b.buffer)
+		b.topics[name] = ch
+	}
+	return ch
+}
+
+// Publish queues a message, blocking while the topic's buffer is full.
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return errors.New("queue: broker closed")
+	}
+	msg.Topic = topic
+	msg.Ack = func() error { return nil }
+	msg.Nack = func() error {
+		// 重新入队不能阻塞处理消息的worker
+		go b.Publish(context.Background(), topic, &Message{Topic: topic, Key: msg.Key, Body: msg.Body, Header: msg.Header})
+		return nil
+	}
+	select {
+	case b.topic(topic) <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	in := b.topic(topic)
+	out := make(chan *Message)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-in:
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					// 订阅已取消，消息放回主题
+					go func() { in <- msg }()
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build mqtt
+
+package queue
+
+import (
+	"context"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTBroker subscribes to MQTT topics. Messages are acknowledged after
+// the handler when the client options disable auto ack
+// (SetAutoAckDisabled); MQTT has no nack, so failed messages are not
+// redelivered. Build with -tags mqtt.
+type MQTTBroker struct {
+	client mqtt.Client
+	// QoS 是订阅使用的服务质量等级
+	QoS byte
+}
+
+func NewMQTTBroker(options *mqtt.ClientOptions) (*MQTTBroker, error) {
+	client := mqtt.NewClient(options)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+	return &MQTTBroker{client: client, QoS: 1}, nil
+}
+
+func (b *MQTTBroker) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	out := make(chan *Message)
+	// mu 保证out关闭后不会再有回调向其发送
+	var mu sync.RWMutex
+	closed := false
+	token := b.client.Subscribe(topic, b.QoS, func(_ mqtt.Client, m mqtt.Message) {
+		mu.RLock()
+		defer mu.RUnlock()
+		if closed {
+			return
+		}
+		msg := &Message{Topic: m.Topic(), Body: m.Payload(), Ack: func() error { m.Ack(); return nil }}
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+		}
+	})
+	if token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+	go func() {
+		<-ctx.Done()
+		b.client.Unsubscribe(topic).Wait()
+		mu.Lock()
+		closed = true
+		close(out)
+		mu.Unlock()
+	}()
+	return out, nil
+}
+
+func (b *MQTTBroker) Close() error {
+	b.client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,65 @@
+//go:build nats
+
+package queue
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSBroker subscribes to NATS subjects. With a queue group, the app's
+// instances share each subject's messages. Core NATS delivers at most once,
+// so Ack and Nack are no-ops. Build with -tags nats.
+type NATSBroker struct {
+	conn  *nats.Conn
+	group string
+}
+
+func NewNATSBroker(url, queueGroup string) (*NATSBroker, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSBroker{conn: conn, group: queueGroup}, nil
+}
+
+func (b *NATSBroker) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	in := make(chan *nats.Msg, nats.DefaultSubPendingMsgsLimit)
+	var sub *nats.Subscription
+	var err error
+	if b.group != "" {
+		sub, err = b.conn.ChanQueueSubscribe(topic, b.group, in)
+	} else {
+		sub, err = b.conn.ChanSubscribe(topic, in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *Message)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m := <-in:
+				header := make(map[string]string, len(m.Header))
+				for key := range m.Header {
+					header[key] = m.Header.Get(key)
+				}
+				select {
+				case out <- &Message{Topic: m.Subject, Body: m.Data, Header: header}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *NATSBroker) Close() error {
+	return b.conn.Drain()
+}
@@ -0,0 +1,210 @@
+// Package queue consumes messages from brokers such as Kafka, RabbitMQ,
+// NATS or MQTT with per-message middleware, bounded concurrency and
+// graceful drain. App.Consume registers consumers bound to the app's
+// lifecycle; broker adapters are built with the kafka, amqp, nats and mqtt
+// tags.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Message is a message delivered by a Broker.
+type Message struct {
+	Topic  string
+	Key    string
+	Body   []byte
+	Header map[string]string
+	// Ack 和 Nack 由Broker设置：处理成功后调用Ack，失败后调用Nack让Broker重新投递
+	Ack  func() error
+	Nack func() error
+}
+
+// Broker delivers messages of a topic. Subscribe returns a channel that is
+// closed once ctx is cancelled and the broker stopped delivering.
+type Broker interface {
+	Subscribe(ctx context.Context, topic string) (<-chan *Message, error)
+	Close() error
+}
+
+// Handler processes one message. Returning an error nacks it.
+type Handler func(ctx context.Context, msg *Message) error
+
+type Middleware func(Handler) Handler
+
+// Subscription is a handler registered for a topic.
+type Subscription struct {
+	topic       string
+	handler     Handler
+	concurrency int
+	timeout     time.Duration
+}
+
+// Concurrency sets how many messages of the topic are handled at once.
+// The default is 1, which keeps the broker's order.
+func (s *Subscription) Concurrency(n int) *Subscription {
+	if n > 0 {
+		s.concurrency = n
+	}
+	return s
+}
+
+// Timeout bounds the context each message is handled with.
+func (s *Subscription) Timeout(d time.Duration) *Subscription {
+	s.timeout = d
+	return s
+}
+
+// Consumers runs subscriptions against one broker.
+type Consumers struct {
+	mu          sync.Mutex
+	broker      Broker
+	middlewares []Middleware
+	subs        []*Subscription
+	// cancel 停止接收新消息；handlerCancel 在关闭超时后取消正在处理的消息
+	cancel        context.CancelFunc
+	handlerCtx    context.Context
+	handlerCancel context.CancelFunc
+	wg            sync.WaitGroup
+}
+
+// SetBroker sets the broker. It must be called before Start.
+func (c *Consumers) SetBroker(broker Broker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.broker = broker
+}
+
+// Use adds middlewares to subscriptions registered afterwards.
+func (c *Consumers) Use(middlewares ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// Consume registers handler for topic.
+func (c *Consumers) Consume(topic string, handler Handler) *Subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.middlewares {
+		handler = c.middlewares[len(c.middlewares)-1-i](handler)
+	}
+	sub := &Subscription{topic: topic, handler: handler, concurrency: 1}
+	c.subs = append(c.subs, sub)
+	return sub
+}
+
+// Start subscribes to every topic and starts the workers.
+func (c *Consumers) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.subs) == 0 {
+		return nil
+	}
+	if c.broker == nil {
+		return errors.New("queue: no broker set")
+	}
+	subCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.handlerCtx, c.handlerCancel = context.WithCancel(context.Background())
+	for _, sub := range c.subs {
+		messages, err := c.broker.Subscribe(subCtx, sub.topic)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("queue: subscribe %s: %w", sub.topic, err)
+		}
+		log.Printf("Consuming %s with %d worker(s)", sub.topic, sub.concurrency)
+		for i := 0; i < sub.concurrency; i++ {
+			c.wg.Add(1)
+			go c.work(sub, messages)
+		}
+	}
+	return nil
+}
+
+func (c *Consumers) work(sub *Subscription, messages <-chan *Message) {
+	defer c.wg.Done()
+	for msg := range messages {
+		ctx, cancel := c.handlerCtx, context.CancelFunc(func() {})
+		if sub.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, sub.timeout)
+		}
+		err := sub.handler(ctx, msg)
+		cancel()
+		settle := msg.Ack
+		if err != nil {
+			settle = msg.Nack
+		}
+		if settle != nil {
+			if err := settle(); err != nil {
+				log.Printf("Queue %s: settle message: %v", sub.topic, err)
+			}
+		}
+	}
+}
+
+// Shutdown stops receiving, lets the workers finish the messages already
+// delivered and closes the broker. Handlers still running when ctx expires
+// have their context cancelled.
+func (c *Consumers) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	cancel, handlerCancel, broker := c.cancel, c.handlerCancel, c.broker
+	c.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Queue drain timed out, cancelling running handlers")
+		handlerCancel()
+		<-done
+		err = ctx.Err()
+	}
+	handlerCancel()
+	if closeErr := broker.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Recovery turns a panic in the handler into an error, so the message is
+// nacked instead of crashing the worker.
+func Recovery(next Handler) Handler {
+	return func(ctx context.Context, msg *Message) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("Panic occurred in %s consumer: %v\n%s", msg.Topic, p, debug.Stack())
+				err = fmt.Errorf("queue: panic: %v", p)
+			}
+		}()
+		return next(ctx, msg)
+	}
+}
+
+// Logger logs each message with its duration and error.
+func Logger(next Handler) Handler {
+	return func(ctx context.Context, msg *Message) error {
+		start := time.Now()
+		err := next(ctx, msg)
+		if err != nil {
+			log.Printf("Duration: %s - Message: %s %s - Error: %v", time.Since(start), msg.Topic, msg.Key, err)
+		} else {
+			log.Printf("Duration: %s - Message: %s %s", time.Since(start), msg.Topic, msg.Key)
+		}
+		return err
+	}
+}
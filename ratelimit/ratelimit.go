@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber/logger"
+)
+
+type Config struct {
+	// 每秒补充的令牌数
+	Rate float64
+	// 令牌桶容量，即允许的突发请求数
+	Burst int
+	// KeyFunc 返回限流的维度，默认按客户端IP
+	KeyFunc func(r *http.Request) string
+	// Logger 记录被拒绝的请求，默认每秒最多一条，避免攻击时日志泛滥
+	Logger *logger.Logger
+}
+
+var defaultConfig = Config{
+	Rate:  10,
+	Burst: 20,
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket rate limiter keyed per client.
+type Limiter struct {
+	config  Config
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func New(config Config) *Limiter {
+	if config.Rate <= 0 {
+		config.Rate = defaultConfig.Rate
+	}
+	if config.Burst <= 0 {
+		config.Burst = defaultConfig.Burst
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = remoteIP
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Every(time.Second)
+	}
+	return &Limiter{config: config, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token for key. When none is available it reports how long
+// until the next one.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[key] = b
+		l.sweep(now)
+	}
+	b.tokens = math.Min(float64(l.config.Burst), b.tokens+now.Sub(b.last).Seconds()*l.config.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.config.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep 删除已经补满的桶，避免按IP限流时map无限增长
+func (l *Limiter) sweep(now time.Time) {
+	if len(l.buckets) < 10000 {
+		return
+	}
+	full := time.Duration(float64(l.config.Burst) / l.config.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware rejects requests over the limit with 429 and Retry-After.
+func (l *Limiter) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := l.config.KeyFunc(r)
+		if ok, wait := l.Allow(key); !ok {
+			l.config.Logger.Printf("Rate limited: %s %s - Key: %s", r.Method, r.URL.Path, key)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func Middleware(config Config) func(http.HandlerFunc) http.HandlerFunc {
+	return New(config).Middleware
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
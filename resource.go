@@ -0,0 +1,131 @@
+package cyber
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 资源路由的动作名，用于ResourceMiddlewares
+const (
+	ActionIndex  = "index"
+	ActionShow   = "show"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionPatch  = "patch"
+	ActionDelete = "delete"
+)
+
+// Resource controllers implement any subset of these interfaces; only the
+// implemented actions are registered.
+type (
+	Indexer interface {
+		Index(w http.ResponseWriter, r *http.Request)
+	}
+	Shower interface {
+		Show(w http.ResponseWriter, r *http.Request)
+	}
+	Creator interface {
+		Create(w http.ResponseWriter, r *http.Request)
+	}
+	Updater interface {
+		Update(w http.ResponseWriter, r *http.Request)
+	}
+	Patcher interface {
+		Patch(w http.ResponseWriter, r *http.Request)
+	}
+	Deleter interface {
+		Delete(w http.ResponseWriter, r *http.Request)
+	}
+)
+
+// ResourceController implements every REST action.
+type ResourceController interface {
+	Indexer
+	Shower
+	Creator
+	Updater
+	Patcher
+	Deleter
+}
+
+// ResourceMiddlewares maps an action name to middlewares applied only to it.
+type ResourceMiddlewares map[string][]Middleware
+
+type resourceRoute struct {
+	action  string
+	method  string
+	member  bool
+	handler func(controller interface{}) (http.HandlerFunc, bool)
+}
+
+var resourceRoutes = []resourceRoute{
+	{ActionIndex, http.MethodGet, false, func(c interface{}) (http.HandlerFunc, bool) {
+		if v, ok := c.(Indexer); ok {
+			return v.Index, true
+		}
+		return nil, false
+	}},
+	{ActionCreate, http.MethodPost, false, func(c interface{}) (http.HandlerFunc, bool) {
+		if v, ok := c.(Creator); ok {
+			return v.Create, true
+		}
+		return nil, false
+	}},
+	{ActionShow, http.MethodGet, true, func(c interface{}) (http.HandlerFunc, bool) {
+		if v, ok := c.(Shower); ok {
+			return v.Show, true
+		}
+		return nil, false
+	}},
+	{ActionUpdate, http.MethodPut, true, func(c interface{}) (http.HandlerFunc, bool) {
+		if v, ok := c.(Updater); ok {
+			return v.Update, true
+		}
+		return nil, false
+	}},
+	{ActionPatch, http.MethodPatch, true, func(c interface{}) (http.HandlerFunc, bool) {
+		if v, ok := c.(Patcher); ok {
+			return v.Patch, true
+		}
+		return nil, false
+	}},
+	{ActionDelete, http.MethodDelete, true, func(c interface{}) (http.HandlerFunc, bool) {
+		if v, ok := c.(Deleter); ok {
+			return v.Delete, true
+		}
+		return nil, false
+	}},
+}
+
+// Resource registers conventional REST routes for controller:
+//
+//	GET    prefix       Index
+//	POST   prefix       Create
+//	GET    prefix/{id}  Show
+//	PUT    prefix/{id}  Update
+//	PATCH  prefix/{id}  Patch
+//	DELETE prefix/{id}  Delete
+//
+// The id is available through r.PathValue("id").
+func (app *App) Resource(prefix string, controller interface{}, middlewares ResourceMiddlewares) {
+	registerResource(app.Handle, prefix, controller, middlewares)
+}
+
+func (rg *RouteGroup) Resource(prefix string, controller interface{}, middlewares ResourceMiddlewares) {
+	registerResource(rg.Handle, prefix, controller, middlewares)
+}
+
+func registerResource(handle func(pattern, method string, handler http.HandlerFunc), prefix string, controller interface{}, middlewares ResourceMiddlewares) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	for _, route := range resourceRoutes {
+		handler, ok := route.handler(controller)
+		if !ok {
+			continue
+		}
+		pattern := prefix
+		if route.member {
+			pattern = strings.TrimSuffix(prefix, "/") + "/{id}"
+		}
+		handle(pattern, route.method, applyMiddlewares(handler, middlewares[route.action]))
+	}
+}
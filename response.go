@@ -1,9 +1,11 @@
 package cyber
 
 import (
+	"bytes"
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 )
 
@@ -14,37 +16,76 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-var jsonEncoderPool = &sync.Pool{
+var bufferPool = &sync.Pool{
 	New: func() interface{} {
-		return json.NewEncoder(nil)
+		return new(bytes.Buffer)
 	},
 }
 
-func respondWithJSON(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
-	enc := jsonEncoderPool.Get().(*json.Encoder)
+// 超过该大小的缓冲区不放回池中，避免偶发的大响应长期占用内存
+const maxPooledBufferSize = 64 << 10
+
+// respondWithJSON 编码并写出data，etag非空时在成功响应中设置ETag
+func respondWithJSON(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}, etag string) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
 	defer func() {
-		err := enc.Encode(data)
-		if err != nil {
-			log.Printf("Error JSONResponse: %v", err)
-			return
+		if buf.Cap() <= maxPooledBufferSize {
+			bufferPool.Put(buf)
 		}
-		jsonEncoderPool.Put(enc)
 	}()
+	// 先编码到缓冲区，成功后才写响应头，编码失败时仍可以返回完整的错误响应
+	if err := jsonCodec.NewEncoder(buf).Encode(data); err != nil {
+		renderError(w, r, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	// 处理器已经设置的ETag优先
+	if etag != "" && statusCode >= 200 && statusCode < 300 && w.Header().Get("ETag") == "" {
+		w.Header().Set("ETag", etag)
+	}
 	w.WriteHeader(statusCode)
-	if err := enc.Encode(data); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Error JSONResponse: %v", err)
+	if state := stateFromRequest(r); state != nil && state.app != nil && state.app.renderErrorHandler != nil {
+		state.app.renderErrorHandler(w, r, err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	if envelope := envelopeFor(r); envelope != nil {
+		// 信封中只有字符串和数字，标准库编码不会失败
+		body, _ := json.Marshal(envelope.wrap(r, "render_error", "Internal Server Error", nil))
+		w.Write(append(body, '\n'))
+		return
+	}
+	w.Write([]byte(`{"code":"render_error","message":"Internal Server Error"}` + "\n"))
 }
 
 func Success(w http.ResponseWriter, r *http.Request, StatusCode int, data interface{}) {
-	respondWithJSON(w, r, StatusCode, data)
+	var etag string
+	if tagger, ok := data.(ETagger); ok {
+		etag = tagger.ETag()
+	}
+	if envelope := envelopeFor(r); envelope != nil {
+		data = envelope.wrap(r, envelope.SuccessCode, envelope.SuccessMessage, data)
+	}
+	respondWithJSON(w, r, StatusCode, data, etag)
 }
 func Error(w http.ResponseWriter, r *http.Request, StatusCode int, code string, message string) {
+	if envelope := envelopeFor(r); envelope != nil {
+		respondWithJSON(w, r, StatusCode, envelope.wrap(r, code, message, nil), "")
+		return
+	}
 	response := ErrorResponse{
 		Code:    code,
 		Message: message,
 	}
-	respondWithJSON(w, r, StatusCode, response)
+	respondWithJSON(w, r, StatusCode, response, "")
 }
@@ -0,0 +1,225 @@
+package cyber
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Route is a registered route. Its handler can be swapped at runtime with
+// App.ReplaceHandler.
+type Route struct {
+	// 空字符串表示HandleFunc注册的不限方法的路由
+	Method  string `json:"method" yaml:"method"`
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Handler 是路由清单中绑定的handler工厂名称
+	Handler string            `json:"handler,omitempty" yaml:"handler,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty" yaml:"meta,omitempty"`
+	// middlewares 是分组中间件，替换handler时重新套用
+	middlewares []Middleware
+	// appMiddlewares 是注册时已有的应用中间件数量，之后Use添加的中间件不作用于该路由
+	appMiddlewares int
+	handler        atomic.Value
+	// types 是Typed handler的请求和响应类型，其他handler为nil
+	types atomic.Pointer[handlerTypes]
+}
+
+// MiddlewareCount returns the number of middlewares wrapping the route: the
+// app's middlewares at registration time plus its group's.
+func (route *Route) MiddlewareCount() int {
+	return route.appMiddlewares + len(route.middlewares)
+}
+
+// Key identifies the route as "METHOD pattern", or just the pattern for
+// routes that accept any method. Metrics and stream counts use it as label.
+func (route *Route) Key() string {
+	return strings.TrimSpace(routeKey(route.Method, route.Pattern))
+}
+
+func (route *Route) serve(w http.ResponseWriter, r *http.Request) {
+	route.handler.Load().(http.HandlerFunc)(w, r)
+}
+
+func (route *Route) setHandler(handler http.HandlerFunc) {
+	route.types.Store(typesOf(handler))
+	route.handler.Store(applyMiddlewares(handler, route.middlewares))
+}
+
+type routeTable struct {
+	mu     sync.RWMutex
+	routes []*Route
+	byKey  map[string]*Route
+	groups []*RouteGroup
+}
+
+func routeKey(method, pattern string) string {
+	return method + " " + pattern
+}
+
+// addRoute 记录路由，返回路由和套用了当前应用中间件的最终handler
+func (app *App) addRoute(method, pattern string, handler http.HandlerFunc, middlewares []Middleware) (*Route, http.HandlerFunc) {
+	app.mu.RLock()
+	appMiddlewares := instrumentMiddlewares(app.Middlewares, app.middlewareObservers)
+	middlewares = instrumentMiddlewares(middlewares, app.middlewareObservers)
+	app.mu.RUnlock()
+	route := &Route{Method: method, Pattern: pattern, middlewares: middlewares, appMiddlewares: len(appMiddlewares)}
+	route.setHandler(handler)
+	finalHandler := applyMiddlewares(route.serve, appMiddlewares)
+	app.routes.mu.Lock()
+	defer app.routes.mu.Unlock()
+	if app.routes.byKey == nil {
+		app.routes.byKey = make(map[string]*Route)
+	}
+	app.routes.routes = append(app.routes.routes, route)
+	app.routes.byKey[routeKey(method, pattern)] = route
+	return route, finalHandler
+}
+
+// ReplaceHandler atomically swaps the handler of a registered route. The
+// app and group middlewares of the route keep applying; requests already
+// running finish on the old handler. Use method "" for routes registered
+// with HandleFunc.
+func (app *App) ReplaceHandler(method, pattern string, handler http.HandlerFunc) error {
+	app.routes.mu.RLock()
+	route, ok := app.routes.byKey[routeKey(method, pattern)]
+	app.routes.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("cyber: no route registered for %s %s", method, pattern)
+	}
+	route.setHandler(handler)
+	return nil
+}
+
+// Route returns the registered route, or nil. Name and Meta may be set on it
+// for documentation and export.
+func (app *App) Route(method, pattern string) *Route {
+	app.routes.mu.RLock()
+	defer app.routes.mu.RUnlock()
+	return app.routes.byKey[routeKey(method, pattern)]
+}
+
+// Routes returns the registered routes sorted by pattern and method.
+func (app *App) Routes() []*Route {
+	app.routes.mu.RLock()
+	routes := append([]*Route(nil), app.routes.routes...)
+	app.routes.mu.RUnlock()
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// ExportRoutes writes the route table as indented JSON in a stable order,
+// suitable for committing and diffing in CI.
+func (app *App) ExportRoutes(w io.Writer) error {
+	data, err := json.MarshalIndent(RouteManifest{Routes: app.Routes()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// RouteManifest is a declarative route table. ExportRoutes writes one and
+// LoadRoutes registers one.
+type RouteManifest struct {
+	Routes []*Route `json:"routes" yaml:"routes"`
+}
+
+// HandlerFactory builds the handler of a manifest route from its metadata.
+type HandlerFactory func(meta map[string]string) (http.HandlerFunc, error)
+
+// LoadRoutes reads a JSON RouteManifest and registers each route with the
+// factory named by its handler field. Every route is validated before any
+// is registered, so a bad manifest registers nothing.
+func (app *App) LoadRoutes(r io.Reader, factories map[string]HandlerFactory) error {
+	var manifest RouteManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return fmt.Errorf("cyber: invalid route manifest: %w", err)
+	}
+	return app.RegisterManifest(manifest, factories)
+}
+
+// RegisterManifest registers the routes of manifest, see LoadRoutes. Before
+// registering, the patterns are tried on a scratch ServeMux together with
+// the app's routes, so an invalid pattern or a conflict is returned as an
+// error instead of panicking halfway through. Handlers registered directly
+// on the app's mux, outside the App, aren't part of that check.
+func (app *App) RegisterManifest(manifest RouteManifest, factories map[string]HandlerFactory) error {
+	handlers := make([]http.HandlerFunc, len(manifest.Routes))
+	for i, route := range manifest.Routes {
+		route.Pattern = convertPathParams(route.Pattern)
+		if route.Method != "" && !isValidHTTPMethod(route.Method) {
+			return fmt.Errorf("cyber: route %s %s: unsupported method", route.Method, route.Pattern)
+		}
+		factory, ok := factories[route.Handler]
+		if !ok {
+			return fmt.Errorf("cyber: route %s %s: unknown handler %q", route.Method, route.Pattern, route.Handler)
+		}
+		handler, err := factory(route.Meta)
+		if err != nil {
+			return fmt.Errorf("cyber: route %s %s: %w", route.Method, route.Pattern, err)
+		}
+		handlers[i] = handler
+	}
+	if err := app.checkPatterns(manifest.Routes); err != nil {
+		return err
+	}
+	for i, route := range manifest.Routes {
+		if route.Method == "" {
+			app.HandleFunc(route.Pattern, handlers[i])
+		} else {
+			app.Handle(route.Pattern, route.Method, handlers[i])
+		}
+		if registered := app.Route(route.Method, route.Pattern); registered != nil {
+			registered.Name = route.Name
+			registered.Handler = route.Handler
+			registered.Meta = route.Meta
+		}
+	}
+	return nil
+}
+
+// checkPatterns 在临时的ServeMux上依次注册应用已有的路由和新路由，
+// 把ServeMux对无效模式和冲突的panic转换为错误
+func (app *App) checkPatterns(routes []*Route) error {
+	scratch := http.NewServeMux()
+	for _, route := range app.Routes() {
+		tryPattern(scratch, route.Method, route.Pattern)
+	}
+	for _, route := range routes {
+		if err := tryPattern(scratch, route.Method, route.Pattern); err != nil {
+			return fmt.Errorf("cyber: route %s %s: %v", route.Method, route.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// registeredAt 是ServeMux冲突信息中的注册位置，指向的是临时ServeMux，没有意义
+var registeredAt = regexp.MustCompile(` \(registered at [^)]*\)`)
+
+// tryPattern 以与 handle/HandleFunc 相同的模式注册空handler
+func tryPattern(mux *http.ServeMux, method, pattern string) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			message := registeredAt.ReplaceAllString(fmt.Sprint(v), "")
+			err = errors.New(strings.ReplaceAll(message, ":\n", ": "))
+		}
+	}()
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+	mux.HandleFunc(pattern, func(http.ResponseWriter, *http.Request) {})
+	return nil
+}
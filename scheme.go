@@ -0,0 +1,115 @@
+package cyber
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type ProxyConfig struct {
+	// 可信代理的网络，例如 10.0.0.0/8，或单个地址 127.0.0.1
+	Networks []string
+	// Header 是代理报告客户端协议的请求头：X-Forwarded-Proto（默认）或 Forwarded。
+	// 只读取这一个请求头：代理不设置的另一个请求头会原样透传，可能是客户端伪造的
+	Header string
+}
+
+// TrustProxies makes Scheme and IsTLS honor the protocol reported in
+// config.Header on requests coming from config.Networks, typically the
+// load balancers terminating TLS. Headers from other peers are ignored,
+// since any client can send them. Set Header to the one the proxy
+// overwrites or appends to, e.g. X-Forwarded-Proto for AWS ALB and most
+// nginx setups. Call it before the app starts serving.
+func (app *App) TrustProxies(config ProxyConfig) error {
+	header := http.CanonicalHeaderKey(config.Header)
+	switch header {
+	case "":
+		header = "X-Forwarded-Proto"
+	case "X-Forwarded-Proto", "Forwarded":
+	default:
+		return fmt.Errorf("cyber: unsupported proxy header %q", config.Header)
+	}
+	networks := make([]*net.IPNet, 0, len(config.Networks))
+	for _, cidr := range config.Networks {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("cyber: invalid proxy address %q", cidr)
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("cyber: invalid proxy network %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	app.trustedProxies = networks
+	app.proxyHeader = header
+	return nil
+}
+
+// Scheme returns "https" or "http", the scheme the client used: the
+// request's own when the connection is TLS, otherwise the one reported by
+// a trusted proxy (see TrustProxies).
+func Scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if app := fromTrustedProxy(r); app != nil {
+		if proto := forwardedProto(r, app.proxyHeader); proto == "https" || proto == "http" {
+			return proto
+		}
+	}
+	return "http"
+}
+
+// IsTLS reports whether the client connected over HTTPS, e.g. to set the
+// Secure flag of cookies.
+func IsTLS(r *http.Request) bool {
+	return Scheme(r) == "https"
+}
+
+// fromTrustedProxy 请求来自可信代理时返回应用，否则返回nil
+func fromTrustedProxy(r *http.Request) *App {
+	state := stateFromRequest(r)
+	if state == nil || state.app == nil || len(state.app.trustedProxies) == 0 {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	for _, network := range state.app.trustedProxies {
+		if network.Contains(ip) {
+			return state.app
+		}
+	}
+	return nil
+}
+
+// forwardedProto 只读取配置的请求头并取最后一个值，即离本服务最近的可信代理写入的值；
+// 客户端伪造的值在前面，代理覆盖或追加该请求头时都不会被采用
+func forwardedProto(r *http.Request, header string) string {
+	values := r.Header.Values(header)
+	if len(values) == 0 {
+		return ""
+	}
+	if header == "Forwarded" {
+		elements := strings.Split(values[len(values)-1], ",")
+		for _, pair := range strings.Split(elements[len(elements)-1], ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(name, "proto") {
+				return strings.ToLower(strings.Trim(value, `"`))
+			}
+		}
+		return ""
+	}
+	protos := strings.Split(values[len(values)-1], ",")
+	return strings.ToLower(strings.TrimSpace(protos[len(protos)-1]))
+}
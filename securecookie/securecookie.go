@@ -0,0 +1,179 @@
+// Package securecookie signs or encrypts cookie values so clients can't
+// forge or read them. Values carry their creation time, and keys can be
+// rotated: the first key creates values and all keys are accepted.
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("securecookie: invalid value")
+	ErrExpired = errors.New("securecookie: value expired")
+	// ErrShortKey 表示密钥短于MinKeyLength
+	ErrShortKey = errors.New("securecookie: key too short")
+)
+
+// MinKeyLength is the minimum length of a key in bytes.
+const MinKeyLength = 32
+
+type Config struct {
+	// Keys 是密钥，每个至少MinKeyLength字节；第一个用于生成，全部用于校验，轮换时把新密钥放在最前面
+	Keys [][]byte
+	// Encrypt 为true时用AES-GCM加密，否则只用HMAC-SHA256签名，值以明文可见
+	Encrypt bool
+	// MaxAge 是值的有效期，为0时不过期；Cookie自身的过期时间由客户端控制，不能代替它
+	MaxAge time.Duration
+}
+
+// Codec encodes and decodes cookie values. Use New to create one; it is
+// safe for concurrent use.
+type Codec struct {
+	config Config
+	keys   []derivedKey
+}
+
+// derivedKey 是从一个密钥派生的签名密钥和加密算法，两种用途互不复用同一密钥
+type derivedKey struct {
+	mac  []byte
+	aead cipher.AEAD
+}
+
+func New(config Config) (*Codec, error) {
+	if len(config.Keys) == 0 {
+		return nil, errors.New("securecookie: no keys")
+	}
+	c := &Codec{config: config}
+	for _, key := range config.Keys {
+		if len(key) < MinKeyLength {
+			return nil, ErrShortKey
+		}
+		block, err := aes.NewCipher(derive(key, "encrypt"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = append(c.keys, derivedKey{mac: derive(key, "sign"), aead: aead})
+	}
+	return c, nil
+}
+
+func derive(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("cyber/securecookie " + purpose))
+	return mac.Sum(nil)
+}
+
+// Encode returns value, bound to the cookie name so it can't be moved to
+// another cookie, in a form safe for a cookie value.
+func (c *Codec) Encode(name string, value []byte) (string, error) {
+	payload := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Unix()))
+	payload = append(payload, value...)
+	key := c.keys[0]
+	if c.config.Encrypt {
+		nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(payload)+key.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(key.aead.Seal(nonce, nonce, payload, []byte(name))), nil
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(sign(key.mac, name, payload)), nil
+}
+
+func sign(key []byte, name string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Decode returns the value Encode encoded for name, ErrExpired if it is
+// older than MaxAge and ErrInvalid if it was modified, made with an unknown
+// key or for another cookie.
+func (c *Codec) Decode(name, encoded string) ([]byte, error) {
+	payload, err := c.open(name, encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < 8 {
+		return nil, ErrInvalid
+	}
+	created := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if c.config.MaxAge > 0 && time.Since(created) > c.config.MaxAge {
+		return nil, ErrExpired
+	}
+	return payload[8:], nil
+}
+
+func (c *Codec) open(name, encoded string) ([]byte, error) {
+	if c.config.Encrypt {
+		data, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		for _, key := range c.keys {
+			size := key.aead.NonceSize()
+			if len(data) < size {
+				return nil, ErrInvalid
+			}
+			if payload, err := key.aead.Open(nil, data[:size], data[size:], []byte(name)); err == nil {
+				return payload, nil
+			}
+		}
+		return nil, ErrInvalid
+	}
+	encodedPayload, encodedMAC, ok := strings.Cut(encoded, ".")
+	payload, err1 := base64.RawURLEncoding.DecodeString(encodedPayload)
+	mac, err2 := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if !ok || err1 != nil || err2 != nil {
+		return nil, ErrInvalid
+	}
+	for _, key := range c.keys {
+		if hmac.Equal(mac, sign(key.mac, name, payload)) {
+			return payload, nil
+		}
+	}
+	return nil, ErrInvalid
+}
+
+// SetCookie sets cookie on w with its Value encoded.
+func (c *Codec) SetCookie(w http.ResponseWriter, cookie *http.Cookie) error {
+	value, err := c.Encode(cookie.Name, []byte(cookie.Value))
+	if err != nil {
+		return err
+	}
+	encoded := *cookie
+	encoded.Value = value
+	http.SetCookie(w, &encoded)
+	return nil
+}
+
+// Cookie returns the decoded value of the named request cookie. The error
+// is http.ErrNoCookie when it is missing.
+func (c *Codec) Cookie(r *http.Request, name string) (string, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := c.Decode(name, cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
@@ -0,0 +1,87 @@
+package cyber
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// 小于该大小的响应不压缩，gzip的头部和CPU开销不划算
+const minGzipSize = 1 << 10
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// JSONETag returns a weak entity tag computed from v's JSON encoding, for
+// responses without a version to derive one from.
+func JSONETag(v interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := jsonCodec.NewEncoder(buf).Encode(v); err != nil {
+		return "", err
+	}
+	return bodyETag(buf.Bytes()), nil
+}
+
+func bodyETag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`W/"%016x"`, h.Sum64())
+}
+
+// ServeJSON writes v as a 200 JSON response for endpoints that clients
+// poll, such as metrics: it sets an ETag, the one already in w's header or
+// one computed from the body, answers a matching If-None-Match with 304
+// Not Modified, and gzips bodies of 1 KiB and more when the client accepts
+// it.
+func ServeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			bufferPool.Put(buf)
+		}
+	}()
+	if err := jsonCodec.NewEncoder(buf).Encode(v); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	header := w.Header()
+	etag := header.Get("ETag")
+	if etag == "" {
+		etag = bodyETag(buf.Bytes())
+		header.Set("ETag", etag)
+	}
+	header.Add("Vary", "Accept-Encoding")
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && IfNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", "application/json")
+	body := buf.Bytes()
+	if len(body) >= minGzipSize && AcceptsGzip(r) {
+		compressed := new(bytes.Buffer)
+		gz := gzipWriterPool.Get().(*gzip.Writer)
+		gz.Reset(compressed)
+		gz.Write(body)
+		gz.Close()
+		gzipWriterPool.Put(gz)
+		body = compressed.Bytes()
+		header.Set("Content-Encoding", "gzip")
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
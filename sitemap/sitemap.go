@@ -0,0 +1,250 @@
+// Package sitemap generates sitemap.xml from an app's named routes and
+// from providers listing dynamic pages, such as articles or products.
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suonanjiexi/cyber"
+)
+
+// 路由Meta中设置站点地图属性的键
+const (
+	// MetaChangeFreq 是页面的更新频率：always、hourly、daily、weekly、monthly、yearly、never
+	MetaChangeFreq = "sitemap_changefreq"
+	// MetaPriority 是页面的优先级，0.0到1.0
+	MetaPriority = "sitemap_priority"
+)
+
+// MaxURLs is the most URLs a sitemap file may list; larger sitemaps are
+// split into files listed by a sitemap index.
+const MaxURLs = 50000
+
+const xmlns = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a sitemap entry. Loc may be a path, which is resolved against
+// Config.BaseURL.
+type URL struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	// 为0时不输出
+	Priority float64
+}
+
+// Provider lists dynamic pages by calling add for each one, e.g. while
+// paging through a table.
+type Provider func(ctx context.Context, add func(URL)) error
+
+type Config struct {
+	// 站点的根URL，例如https://example.com，站点地图中的地址必须是绝对地址
+	BaseURL string
+	// 不为nil时包含其中已命名、不含路径参数的GET路由
+	App *cyber.App
+	// 动态页面
+	Providers []Provider
+	// 生成结果的缓存时间，默认1小时
+	TTL time.Duration
+}
+
+const defaultTTL = time.Hour
+
+// Sitemap serves the generated sitemap, regenerating it when the cache
+// expires.
+type Sitemap struct {
+	config Config
+	mu     sync.Mutex
+	files  []*file
+	built  time.Time
+}
+
+// file 是生成好的一个站点地图文件，同时保存gzip压缩后的内容
+type file struct {
+	plain   []byte
+	gzipped []byte
+	etag    string
+}
+
+func New(config Config) *Sitemap {
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	return &Sitemap{config: config}
+}
+
+// Register serves the sitemap at /sitemap.xml, and the files of a split
+// sitemap at /sitemaps/{n}.xml.
+func (s *Sitemap) Register(app *cyber.App) {
+	app.Get("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, 0)
+	})
+	app.Get("/sitemaps/{file}", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimSuffix(r.PathValue("file"), ".xml"))
+		if err != nil || n < 1 || !strings.HasSuffix(r.PathValue("file"), ".xml") {
+			http.NotFound(w, r)
+			return
+		}
+		s.serve(w, r, n)
+	})
+}
+
+// Invalidate discards the cached sitemap, e.g. after publishing content.
+func (s *Sitemap) Invalidate() {
+	s.mu.Lock()
+	s.files = nil
+	s.mu.Unlock()
+}
+
+func (s *Sitemap) serve(w http.ResponseWriter, r *http.Request, n int) {
+	files, err := s.generate(r.Context())
+	if err != nil {
+		log.Printf("Error generating sitemap: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if n >= len(files) {
+		http.NotFound(w, r)
+		return
+	}
+	f := files[n]
+	header := w.Header()
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.config.TTL.Seconds())))
+	header.Set("ETag", f.etag)
+	header.Add("Vary", "Accept-Encoding")
+	if cyber.IfNoneMatch(r, f.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	body := f.plain
+	if cyber.AcceptsGzip(r) {
+		header.Set("Content-Encoding", "gzip")
+		body = f.gzipped
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// generate 返回缓存的文件，过期时重新生成；files[0]是sitemap.xml，拆分时是索引文件
+func (s *Sitemap) generate(ctx context.Context) ([]*file, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files != nil && time.Since(s.built) < s.config.TTL {
+		return s.files, nil
+	}
+	urls, err := s.collect(ctx)
+	if err != nil {
+		// 重新生成失败时继续使用过期的结果
+		if s.files != nil {
+			log.Printf("Error regenerating sitemap, serving stale copy: %v", err)
+			return s.files, nil
+		}
+		return nil, err
+	}
+	var files []*file
+	if len(urls) <= MaxURLs {
+		files = []*file{newFile(s.urlset(urls))}
+	} else {
+		files = []*file{nil}
+		for start := 0; start < len(urls); start += MaxURLs {
+			end := min(start+MaxURLs, len(urls))
+			files = append(files, newFile(s.urlset(urls[start:end])))
+		}
+		files[0] = newFile(s.index(len(files) - 1))
+	}
+	s.files, s.built = files, time.Now()
+	return files, nil
+}
+
+func (s *Sitemap) collect(ctx context.Context) ([]URL, error) {
+	var urls []URL
+	if s.config.App != nil {
+		for _, route := range s.config.App.Routes() {
+			if route.Name == "" || route.Method != http.MethodGet || strings.Contains(strings.TrimSuffix(route.Pattern, "{$}"), "{") {
+				continue
+			}
+			u := URL{Loc: strings.TrimSuffix(route.Pattern, "{$}"), ChangeFreq: route.Meta[MetaChangeFreq]}
+			u.Priority, _ = strconv.ParseFloat(route.Meta[MetaPriority], 64)
+			urls = append(urls, u)
+		}
+	}
+	for _, provider := range s.config.Providers {
+		if err := provider(ctx, func(u URL) { urls = append(urls, u) }); err != nil {
+			return nil, fmt.Errorf("sitemap: %w", err)
+		}
+	}
+	return urls, nil
+}
+
+func (s *Sitemap) absolute(loc string) string {
+	if strings.HasPrefix(loc, "http://") || strings.HasPrefix(loc, "https://") {
+		return loc
+	}
+	if !strings.HasPrefix(loc, "/") {
+		loc = "/" + loc
+	}
+	return s.config.BaseURL + loc
+}
+
+func (s *Sitemap) urlset(urls []URL) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<urlset xmlns="` + xmlns + `">` + "\n")
+	for _, u := range urls {
+		b.WriteString("<url><loc>")
+		xml.EscapeText(&b, []byte(s.absolute(u.Loc)))
+		b.WriteString("</loc>")
+		if !u.LastMod.IsZero() {
+			b.WriteString("<lastmod>" + u.LastMod.UTC().Format(time.RFC3339) + "</lastmod>")
+		}
+		if u.ChangeFreq != "" {
+			b.WriteString("<changefreq>")
+			xml.EscapeText(&b, []byte(u.ChangeFreq))
+			b.WriteString("</changefreq>")
+		}
+		if u.Priority > 0 {
+			b.WriteString("<priority>" + strconv.FormatFloat(u.Priority, 'f', -1, 64) + "</priority>")
+		}
+		b.WriteString("</url>\n")
+	}
+	b.WriteString("</urlset>\n")
+	return b.Bytes()
+}
+
+func (s *Sitemap) index(files int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<sitemapindex xmlns="` + xmlns + `">` + "\n")
+	for n := 1; n <= files; n++ {
+		b.WriteString("<sitemap><loc>")
+		xml.EscapeText(&b, []byte(s.absolute("/sitemaps/"+strconv.Itoa(n)+".xml")))
+		b.WriteString("</loc></sitemap>\n")
+	}
+	b.WriteString("</sitemapindex>\n")
+	return b.Bytes()
+}
+
+func newFile(plain []byte) *file {
+	var gz bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	zw.Write(plain)
+	zw.Close()
+	h := fnv.New64a()
+	h.Write(plain)
+	return &file{plain: plain, gzipped: gz.Bytes(), etag: fmt.Sprintf(`"%016x"`, h.Sum64())}
+}
@@ -0,0 +1,151 @@
+package cyber
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/suonanjiexi/cyber/securecookie"
+)
+
+// Variant is one arm of a traffic split.
+type Variant struct {
+	Name    string
+	Weight  int
+	Handler http.HandlerFunc
+}
+
+type SplitConfig struct {
+	Variants []Variant
+	// 记录分配结果的Cookie名，为空时不保持粘性
+	Cookie string
+	// Cookie的有效期，默认30天
+	MaxAge time.Duration
+	// Selector 不为nil时代替按权重随机，返回变体名称；返回未知名称时回退到权重
+	Selector func(r *http.Request) string
+	// Cookies 不为nil时签名记录分配结果的Cookie，客户端无法自行指定变体
+	Cookies *securecookie.Codec
+}
+
+// Split returns a handler that sends each client to one variant, chosen by
+// Selector or by weight and then kept sticky through the cookie. The chosen
+// variant is available to logging through SplitVariant.
+func Split(config SplitConfig) http.HandlerFunc {
+	if config.MaxAge <= 0 {
+		config.MaxAge = 30 * 24 * time.Hour
+	}
+	total := 0
+	for _, v := range config.Variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	if total == 0 {
+		log.Printf("Split has no variant with a positive weight")
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		variant, sticky := pickVariant(config, total, r)
+		if variant == nil {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if config.Cookie != "" && !sticky {
+			cookie := &http.Cookie{
+				Name:     config.Cookie,
+				Value:    variant.Name,
+				Path:     "/",
+				MaxAge:   int(config.MaxAge.Seconds()),
+				HttpOnly: true,
+				Secure:   IsTLS(r),
+				SameSite: http.SameSiteLaxMode,
+			}
+			if config.Cookies == nil {
+				http.SetCookie(w, cookie)
+			} else if err := config.Cookies.SetCookie(w, cookie); err != nil {
+				log.Printf("Split cookie error: %v", err)
+			}
+		}
+		if state := stateFromRequest(r); state != nil {
+			state.mu.Lock()
+			state.variant = variant.Name
+			state.mu.Unlock()
+		}
+		variant.Handler(w, r)
+	}
+}
+
+// pickVariant 依次使用Cookie、Selector和权重选择变体，sticky表示来自已有的Cookie
+func pickVariant(config SplitConfig, total int, r *http.Request) (*Variant, bool) {
+	if config.Cookie != "" {
+		if name, err := splitCookie(config, r); err == nil {
+			if v := findVariant(config.Variants, name); v != nil {
+				return v, true
+			}
+		}
+	}
+	if config.Selector != nil {
+		if v := findVariant(config.Variants, config.Selector(r)); v != nil {
+			return v, false
+		}
+	}
+	if total == 0 {
+		return nil, false
+	}
+	n := rand.Intn(total)
+	for i := range config.Variants {
+		if config.Variants[i].Weight <= 0 {
+			continue
+		}
+		if n < config.Variants[i].Weight {
+			return &config.Variants[i], false
+		}
+		n -= config.Variants[i].Weight
+	}
+	return nil, false
+}
+
+// splitCookie 读取记录的变体名称，签名无效的Cookie视为不存在，重新分配
+func splitCookie(config SplitConfig, r *http.Request) (string, error) {
+	if config.Cookies != nil {
+		return config.Cookies.Cookie(r, config.Cookie)
+	}
+	cookie, err := r.Cookie(config.Cookie)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+func findVariant(variants []Variant, name string) *Variant {
+	for i := range variants {
+		if variants[i].Name == name {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+// Split registers a traffic split on pattern. Without a cookie name the
+// assignment is kept sticky in a cookie derived from the route.
+func (app *App) Split(method, pattern string, config SplitConfig) {
+	if config.Cookie == "" {
+		h := fnv.New32a()
+		h.Write([]byte(method + " " + pattern))
+		config.Cookie = fmt.Sprintf("split_%x", h.Sum32())
+	}
+	app.Handle(pattern, method, Split(config))
+}
+
+// SplitVariant returns the variant Split chose for the request, or "".
+func SplitVariant(r *http.Request) string {
+	state := stateFromRequest(r)
+	if state == nil {
+		return ""
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.variant
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorage stores objects as files under Dir. Presigned URLs point to
+// BaseURL and are verified by Handler using SigningKey.
+type LocalStorage struct {
+	Dir        string
+	BaseURL    string
+	SigningKey []byte
+}
+
+func NewLocalStorage(dir, baseURL string, signingKey []byte) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/"), SigningKey: signingKey}, nil
+}
+
+// path 把key映射到Dir下的文件路径，防止通过 ".." 逃逸出根目录
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" {
+		return "", errors.New("storage: empty key")
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	object, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	name, _ := s.path(key)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, object, nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*Object, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &Object{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(name)),
+		ModTime:     fi.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if len(s.SigningKey) == 0 {
+		return "", errors.New("storage: LocalStorage has no signing key")
+	}
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{"expires": {expiresAt}, "signature": {s.sign(key, expiresAt)}}
+	return s.BaseURL + "/" + key + "?" + query.Encode(), nil
+}
+
+func (s *LocalStorage) sign(key, expiresAt string) string {
+	mac := hmac.New(sha256.New, s.SigningKey)
+	mac.Write([]byte(key + "\n" + expiresAt))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves objects requested through presigned URLs. Mount it under
+// the path of BaseURL with http.StripPrefix.
+func (s *LocalStorage) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		expiresAt := r.URL.Query().Get("expires")
+		expires, err := strconv.ParseInt(expiresAt, 10, 64)
+		if err != nil || time.Now().Unix() > expires ||
+			!hmac.Equal([]byte(s.sign(key, expiresAt)), []byte(r.URL.Query().Get("signature"))) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		Serve(w, r, s, key)
+	})
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage abstracts where files live so file handlers aren't tied to the
+// local filesystem.
+type Storage interface {
+	// Put stores r under key. size may be -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL granting temporary read access to key.
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Copy streams an object from src to dst without buffering it in memory.
+func Copy(ctx context.Context, dst Storage, dstKey string, src Storage, srcKey string) error {
+	reader, object, err := src.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return dst.Put(ctx, dstKey, reader, object.Size, object.ContentType)
+}
+
+// Serve streams the object stored under key to the client.
+func Serve(w http.ResponseWriter, r *http.Request, s Storage, key string) {
+	reader, object, err := s.Get(r.Context(), key)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading object %s: %v", key, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+	header := w.Header()
+	if object.ContentType != "" {
+		header.Set("Content-Type", object.ContentType)
+	}
+	if object.Size >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	}
+	if !object.ModTime.IsZero() {
+		header.Set("Last-Modified", object.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("Error streaming object %s: %v", key, err)
+	}
+}
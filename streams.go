@@ -0,0 +1,114 @@
+package cyber
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// stream 是一个长连接（WebSocket、SSE），关闭时通过close通知处理函数结束
+type stream struct {
+	route string
+	close func(reason string)
+}
+
+// streamRegistry 记录应用的长连接，关闭服务器时通知它们
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[*stream]struct{}
+	closing string
+}
+
+// TrackStream registers a long-lived connection such as a WebSocket or SSE
+// stream with the app serving r. On Shutdown, close is called with a reason
+// and must make the handler finish, e.g. by sending a WebSocket close frame;
+// it may run concurrently with the handler. Call the returned function when
+// the connection ends. Outside of an App-dispatched request nothing is
+// tracked.
+func TrackStream(r *http.Request, close func(reason string)) (done func()) {
+	state := stateFromRequest(r)
+	if state == nil || state.app == nil {
+		return func() {}
+	}
+	s := &stream{route: "unknown", close: close}
+	if route := CurrentRoute(r); route != nil {
+		s.route = route.Key()
+	}
+	registry := &state.app.streams
+	registry.mu.Lock()
+	if registry.streams == nil {
+		registry.streams = make(map[*stream]struct{})
+	}
+	registry.streams[s] = struct{}{}
+	closing := registry.closing
+	registry.mu.Unlock()
+	if closing != "" {
+		// 已经在关闭，新连接立即结束
+		close(closing)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			registry.mu.Lock()
+			delete(registry.streams, s)
+			registry.mu.Unlock()
+		})
+	}
+}
+
+// Streams returns the number of open tracked streams per route.
+func (app *App) Streams() map[string]int {
+	app.streams.mu.Lock()
+	defer app.streams.mu.Unlock()
+	counts := make(map[string]int)
+	for s := range app.streams.streams {
+		counts[s.route]++
+	}
+	return counts
+}
+
+// ShutdownReason is the reason passed to tracked streams on Shutdown.
+const ShutdownReason = "server shutting down"
+
+// closeStreams 通知所有长连接关闭，之后建立的长连接会立即被关闭
+func (app *App) closeStreams() {
+	registry := &app.streams
+	registry.mu.Lock()
+	registry.closing = ShutdownReason
+	streams := make([]*stream, 0, len(registry.streams))
+	for s := range registry.streams {
+		streams = append(streams, s)
+	}
+	registry.mu.Unlock()
+	if len(streams) > 0 {
+		log.Printf("Closing %d stream(s)", len(streams))
+	}
+	for _, s := range streams {
+		s.close(ShutdownReason)
+	}
+}
+
+// 等待长连接结束时检查的间隔
+const streamPollInterval = 50 * time.Millisecond
+
+// waitStreams 等待所有长连接结束或ctx到期
+func (app *App) waitStreams(ctx context.Context) error {
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+	for {
+		app.streams.mu.Lock()
+		open := len(app.streams.streams)
+		app.streams.mu.Unlock()
+		if open == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("Shutdown stopped with %d stream(s) open", open)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cyber
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// templateSet 保存解析好的模板，调试模式下每次渲染前重新解析
+type templateSet struct {
+	mu      sync.RWMutex
+	pattern string
+	funcs   template.FuncMap
+	tmpl    *template.Template
+}
+
+func (ts *templateSet) parse() (*template.Template, error) {
+	return template.New("").Funcs(csrfFuncs()).Funcs(flashFuncs()).Funcs(ts.funcs).ParseGlob(ts.pattern)
+}
+
+// templateMarker 生成模板函数输出的占位符，HTML渲染完成后替换为请求的值；
+// 模板函数在加载模板时就已确定，无法直接访问当前请求
+func templateMarker(name string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "cyber-" + name + "-" + hex.EncodeToString(b)
+}
+
+// LoadTemplates parses the templates matching the glob pattern for HTML. In
+// debug mode they are re-parsed on every render, so edits show up without a
+// restart. Besides funcs, templates can call csrfField, which outputs a
+// hidden input with the CSRF token, csrfToken (see CSRF), and flashes,
+// which shows the pending flash messages (see Flash).
+func (app *App) LoadTemplates(pattern string, funcs template.FuncMap) error {
+	ts := &templateSet{pattern: pattern, funcs: funcs}
+	tmpl, err := ts.parse()
+	if err != nil {
+		return err
+	}
+	ts.tmpl = tmpl
+	app.templates = ts
+	return nil
+}
+
+func (app *App) template() (*template.Template, error) {
+	ts := app.templates
+	if app.IsDebug() {
+		tmpl, err := ts.parse()
+		if err != nil {
+			return nil, err
+		}
+		ts.mu.Lock()
+		ts.tmpl = tmpl
+		ts.mu.Unlock()
+		return tmpl, nil
+	}
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.tmpl, nil
+}
+
+// HTML renders the named template loaded with App.LoadTemplates.
+func HTML(w http.ResponseWriter, r *http.Request, statusCode int, name string, data interface{}) {
+	state := stateFromRequest(r)
+	if state == nil || state.app == nil || state.app.templates == nil {
+		log.Printf("HTML called without templates loaded: %s", name)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	tmpl, err := state.app.template()
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			bufferPool.Put(buf)
+		}
+	}()
+	if err := tmpl.ExecuteTemplate(buf, name, data); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	body := injectCSRF(r, injectFlashes(w, r, buf.Bytes()))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing HTML response: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package cyber
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timing is a named phase duration reported in the Server-Timing header.
+type Timing struct {
+	Name        string
+	Duration    time.Duration
+	Description string
+}
+
+// AddTiming records a phase duration for the current request.
+func AddTiming(r *http.Request, name string, duration time.Duration, description string) {
+	state := stateFromRequest(r)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	state.timings = append(state.timings, Timing{Name: name, Duration: duration, Description: description})
+	state.mu.Unlock()
+}
+
+// StartTiming starts measuring a phase and returns the function that stops
+// it, e.g. defer cyber.StartTiming(r, "db")().
+func StartTiming(r *http.Request, name string) func() {
+	start := time.Now()
+	return func() {
+		AddTiming(r, name, time.Since(start), "")
+	}
+}
+
+// Timings returns the phases recorded for the current request so far.
+func Timings(r *http.Request) []Timing {
+	state := stateFromRequest(r)
+	if state == nil {
+		return nil
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return append([]Timing(nil), state.timings...)
+}
+
+// FormatServerTiming renders timings as a Server-Timing header value.
+func FormatServerTiming(timings []Timing) string {
+	parts := make([]string, 0, len(timings))
+	for _, t := range timings {
+		part := fmt.Sprintf("%s;dur=%.3f", sanitizeTimingName(t.Name), float64(t.Duration.Microseconds())/1000)
+		if t.Description != "" {
+			part += fmt.Sprintf(";desc=%q", t.Description)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sanitizeTimingName 指标名必须是HTTP token，非法字符替换为 '_'
+func sanitizeTimingName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,99 @@
+package cyber
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// Validator is implemented by request types that validate themselves after
+// binding.
+type Validator interface {
+	Validate() error
+}
+
+// Typed adapts a function taking a decoded request value to a handler. The
+// JSON body, the path parameters named by uri tags and the headers named
+// by header tags are bound into Req, which is validated when it implements
+// Validator; the returned Resp is rendered with Success and errors with
+// Abort.
+func Typed[Req, Resp any](fn func(r *http.Request, req Req) (Resp, error)) http.HandlerFunc {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if probe, ok := w.(*typeProbe); ok {
+			probe.types = &handlerTypes{req: reflect.TypeOf((*Req)(nil)).Elem(), resp: reflect.TypeOf((*Resp)(nil)).Elem()}
+			return
+		}
+		var req Req
+		if r.Body != nil && r.ContentLength != 0 {
+			// 空请求体不视为错误，Req保持零值
+			if err := BindJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+				Error(w, r, http.StatusBadRequest, "invalid_body", err.Error())
+				return
+			}
+		} else if err := applyDefaults(&req); err != nil {
+			// 默认值无法转换是代码错误，不是客户端的错误
+			Abort(w, r, err)
+			return
+		}
+		// 路径参数在请求体之后绑定，与请求体中的同名字段冲突时以路径为准
+		if hasTagged(&req, "uri") {
+			if err := bindTagged(&req, "uri", pathValues(r)); err != nil {
+				Error(w, r, http.StatusBadRequest, "invalid_path", err.Error())
+				return
+			}
+		}
+		if hasTagged(&req, "header") {
+			if err := bindTagged(&req, "header", r.Header.Values); err != nil {
+				Error(w, r, http.StatusBadRequest, "invalid_header", err.Error())
+				return
+			}
+		}
+		if v, ok := interface{}(&req).(Validator); ok {
+			if err := v.Validate(); err != nil {
+				Error(w, r, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+				return
+			}
+		}
+		resp, err := fn(r, req)
+		if err != nil {
+			Abort(w, r, err)
+			return
+		}
+		Success(w, r, http.StatusOK, resp)
+	}
+	typedHandlers.Store(reflect.ValueOf(handler).Pointer(), struct{}{})
+	return handler
+}
+
+// handlerTypes 是Typed handler的请求和响应类型，供OpenAPI生成schema
+type handlerTypes struct {
+	req, resp reflect.Type
+}
+
+// typedHandlers 记录Typed返回的闭包的代码地址。同一形状的实例化共用代码，
+// 地址只用于判断handler是否来自Typed，具体类型通过typeProbe询问handler本身
+var typedHandlers sync.Map
+
+// typeProbe 是只用于询问Typed handler类型的ResponseWriter，handler收到它时不处理请求
+type typeProbe struct {
+	types *handlerTypes
+}
+
+func (p *typeProbe) Header() http.Header         { return http.Header{} }
+func (p *typeProbe) Write(b []byte) (int, error) { return len(b), nil }
+func (p *typeProbe) WriteHeader(int)             {}
+
+// typesOf 返回Typed handler的请求和响应类型，其他handler返回nil
+func typesOf(handler http.HandlerFunc) *handlerTypes {
+	if handler == nil {
+		return nil
+	}
+	if _, ok := typedHandlers.Load(reflect.ValueOf(handler).Pointer()); !ok {
+		return nil
+	}
+	probe := &typeProbe{}
+	handler(probe, nil)
+	return probe.types
+}
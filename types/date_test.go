@@ -0,0 +1,134 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Date
+		wantErr bool
+	}{
+		{in: "2024-05-01", want: Date{2024, time.May, 1}},
+		{in: "2024-02-29", want: Date{2024, time.February, 29}},
+		{in: "2023-02-29", wantErr: true},
+		{in: "2024-13-01", wantErr: true},
+		{in: "2024-5-1", wantErr: true},
+		{in: "2024-05-01T00:00:00Z", wantErr: true},
+		{in: "01/05/2024", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		d, err := ParseDate(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDate(%q) = %s, want error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", tt.in, err)
+			continue
+		}
+		if d != tt.want {
+			t.Errorf("ParseDate(%q) = %s, want %s", tt.in, d, tt.want)
+		}
+	}
+}
+
+func TestDateArithmetic(t *testing.T) {
+	d := Date{2024, time.January, 31}
+	if got := d.AddDays(1).String(); got != "2024-02-01" {
+		t.Errorf("%s + 1 day = %s, want 2024-02-01", d, got)
+	}
+	if got := d.AddDays(-31).String(); got != "2023-12-31" {
+		t.Errorf("%s - 31 days = %s, want 2023-12-31", d, got)
+	}
+	if !d.Before(d.AddDays(1)) || d.After(d.AddDays(1)) || d.Before(d) {
+		t.Errorf("%s compares wrong with the next day", d)
+	}
+	// 日期与时区无关：东八区零点仍是同一天
+	loc := time.FixedZone("UTC+8", 8*3600)
+	if got := DateOf(d.In(loc)); got != d {
+		t.Errorf("DateOf(%s in UTC+8) = %s", d, got)
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Date
+		out     string
+		wantErr bool
+	}{
+		{in: `"2024-05-01"`, want: Date{2024, time.May, 1}, out: `"2024-05-01"`},
+		{in: `null`, out: `null`},
+		{in: `""`, out: `null`},
+		{in: `"2024-05-32"`, wantErr: true},
+		{in: `20240501`, wantErr: true},
+		{in: `"2024-05-01T08:00:00Z"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var d Date
+		err := json.Unmarshal([]byte(tt.in), &d)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if d != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, d, tt.want)
+		}
+		out, err := json.Marshal(d)
+		if err != nil || string(out) != tt.out {
+			t.Errorf("Marshal(%s) = %s, %v, want %s", d, out, err, tt.out)
+		}
+	}
+}
+
+func TestDateScan(t *testing.T) {
+	tests := []struct {
+		src     interface{}
+		want    Date
+		wantErr bool
+	}{
+		{src: "2024-05-01", want: Date{2024, time.May, 1}},
+		{src: []byte("1999-12-31"), want: Date{1999, time.December, 31}},
+		{src: time.Date(2024, time.May, 1, 23, 30, 0, 0, time.UTC), want: Date{2024, time.May, 1}},
+		{src: nil},
+		{src: "2024-05-01 08:00:00", wantErr: true},
+		{src: int64(20240501), wantErr: true},
+	}
+	for _, tt := range tests {
+		var d Date
+		err := d.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %s, want error", tt.src, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if d != tt.want {
+			t.Errorf("Scan(%#v) = %s, want %s", tt.src, d, tt.want)
+		}
+		v, err := d.Value()
+		if err != nil {
+			t.Errorf("Value() of %s: %v", d, err)
+		}
+		if d.IsZero() && v != nil || !d.IsZero() && v != d.String() {
+			t.Errorf("Value() of %s = %#v", d, v)
+		}
+	}
+}
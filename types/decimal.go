@@ -0,0 +1,229 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimal is an exact decimal number for money and other amounts that
+// floats would round, e.g. 0.1 + 0.2. It is a coefficient and a scale,
+// the number of digits after the point, which is kept: "12.50" stays
+// "12.50". JSON accepts both strings and numbers and writes strings, so
+// clients parsing JSON numbers as floats don't lose precision. The zero
+// value is 0. Decimals are immutable; compare them with Cmp, not ==.
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+// 最多允许的小数位数，防止恶意输入造成巨大的计算
+const maxScale = 64
+
+// NewDecimal returns coef × 10^-scale, e.g. NewDecimal(1250, 2) is 12.50.
+// A negative scale multiplies: NewDecimal(5, -2) is 500.
+func NewDecimal(coef int64, scale int32) Decimal {
+	return newDecimal(big.NewInt(coef), scale)
+}
+
+// newDecimal 把负的scale转换为系数乘以10的幂，保证scale不小于0
+func newDecimal(coef *big.Int, scale int32) Decimal {
+	if scale < 0 {
+		coef.Mul(coef, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{coef, scale}
+}
+
+// ParseDecimal parses a decimal such as "12.50", "-3" or "+0.001".
+// Exponents are not accepted.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("types: invalid decimal %q", s)
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if (whole == "" && frac == "") || !isDigits(whole) || !isDigits(frac) || len(frac) > maxScale || len(digits) > 1000 {
+		return Decimal{}, fmt.Errorf("types: invalid decimal %q", s)
+	}
+	coef, _ := new(big.Int).SetString(whole+frac, 10)
+	if strings.HasPrefix(s, "-") {
+		coef.Neg(coef)
+	}
+	return Decimal{coef, int32(len(frac))}, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MustDecimal is ParseDecimal panicking on error, for constants.
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign returns -1, 0 or 1.
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// rescale 返回放大到scale位小数的系数，scale不能小于d.scale
+func (d Decimal) rescale(scale int32) *big.Int {
+	coef := new(big.Int).Set(d.int())
+	if scale > d.scale {
+		coef.Mul(coef, pow10(scale-d.scale))
+	}
+	return coef
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Add returns d + other with the larger scale of the two.
+func (d Decimal) Add(other Decimal) Decimal {
+	scale := max(d.scale, other.scale)
+	return Decimal{new(big.Int).Add(d.rescale(scale), other.rescale(scale)), scale}
+}
+
+// Sub returns d - other with the larger scale of the two.
+func (d Decimal) Sub(other Decimal) Decimal {
+	return d.Add(other.Neg())
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{new(big.Int).Neg(d.int()), d.scale}
+}
+
+// Mul returns d × other exactly; the scale is the sum of both scales, so
+// round the result, e.g. price.Mul(rate).Round(2).
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{new(big.Int).Mul(d.int(), other.int()), d.scale + other.scale}
+}
+
+// Cmp returns -1, 0 or 1 as d is less than, equal to or greater than
+// other. Scale doesn't matter: 1.5 equals 1.50.
+func (d Decimal) Cmp(other Decimal) int {
+	scale := max(d.scale, other.scale)
+	return d.rescale(scale).Cmp(other.rescale(scale))
+}
+
+// Round returns d with scale digits after the point, rounding half away
+// from zero as is usual for money: 2.345 becomes 2.35 and -2.345 becomes
+// -2.35. A larger scale pads with zeros; a negative one rounds to tens,
+// hundreds and so on: 1250 rounded to -2 is 1300.
+func (d Decimal) Round(scale int32) Decimal {
+	if scale >= d.scale {
+		return Decimal{d.rescale(scale), scale}
+	}
+	divisor := pow10(d.scale - scale)
+	quo, rem := new(big.Int).QuoRem(d.int(), divisor, new(big.Int))
+	// |rem|*2 >= divisor 时远离零进位
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(divisor) >= 0 {
+		if d.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return newDecimal(quo, scale)
+}
+
+// Float64 returns the nearest float64, for display or statistics only.
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(d.int(), pow10(d.scale)).Float64()
+	return f
+}
+
+func (d Decimal) String() string {
+	coef := d.int()
+	digits := new(big.Int).Abs(coef).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if coef.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts "12.50" and 12.50; a number is read from its
+// literal text, never through a float.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	text := strings.Trim(string(data), `"`)
+	if len(text) != len(data) && len(text)+2 != len(data) {
+		return fmt.Errorf("types: invalid decimal %s", data)
+	}
+	return d.UnmarshalText([]byte(text))
+}
+
+// Value stores the decimal as a string, which DECIMAL/NUMERIC columns
+// accept without rounding.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		return d.UnmarshalText([]byte(v))
+	case []byte:
+		return d.UnmarshalText(v)
+	case int64:
+		*d = NewDecimal(v, 0)
+		return nil
+	default:
+		return fmt.Errorf("types: cannot scan %T into Decimal", src)
+	}
+}
@@ -0,0 +1,199 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "12.50", want: "12.50"},
+		{in: "-3", want: "-3"},
+		{in: "+0.001", want: "0.001"},
+		{in: ".5", want: "0.5"},
+		{in: "5.", want: "5"},
+		{in: "-0.10", want: "-0.10"},
+		{in: "-0", want: "0"},
+		{in: "007", want: "7"},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "+-1", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: " 1", wantErr: true},
+		{in: "0x10", wantErr: true},
+		{in: "1." + strings.Repeat("1", maxScale), want: "1." + strings.Repeat("1", maxScale)},
+		{in: "1." + strings.Repeat("1", maxScale+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %s, want error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", tt.in, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewDecimal(t *testing.T) {
+	tests := []struct {
+		coef  int64
+		scale int32
+		want  string
+	}{
+		{1250, 2, "12.50"},
+		{-5, 3, "-0.005"},
+		{0, 2, "0.00"},
+		{5, -2, "500"},
+		{-12, -1, "-120"},
+	}
+	for _, tt := range tests {
+		d := NewDecimal(tt.coef, tt.scale)
+		if got := d.String(); got != tt.want {
+			t.Errorf("NewDecimal(%d, %d) = %s, want %s", tt.coef, tt.scale, got, tt.want)
+		}
+		if d.Scale() < 0 {
+			t.Errorf("NewDecimal(%d, %d) has negative scale %d", tt.coef, tt.scale, d.Scale())
+		}
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int32
+		want  string
+	}{
+		{"2.345", 2, "2.35"},
+		{"-2.345", 2, "-2.35"},
+		{"2.344", 2, "2.34"},
+		{"-2.344", 2, "-2.34"},
+		{"0.5", 0, "1"},
+		{"-0.5", 0, "-1"},
+		{"0.49", 0, "0"},
+		{"1.5", 3, "1.500"},
+		{"1250", -2, "1300"},
+		{"1249.99", -2, "1200"},
+		{"-1250", -2, "-1300"},
+	}
+	for _, tt := range tests {
+		if got := MustDecimal(tt.in).Round(tt.scale).String(); got != tt.want {
+			t.Errorf("%s.Round(%d) = %s, want %s", tt.in, tt.scale, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, b := MustDecimal("0.1"), MustDecimal("0.2")
+	if got := a.Add(b).String(); got != "0.3" {
+		t.Errorf("0.1 + 0.2 = %s, want 0.3", got)
+	}
+	if got := a.Sub(b).String(); got != "-0.1" {
+		t.Errorf("0.1 - 0.2 = %s, want -0.1", got)
+	}
+	if got := MustDecimal("19.99").Mul(MustDecimal("0.075")).Round(2).String(); got != "1.50" {
+		t.Errorf("19.99 × 0.075 rounded = %s, want 1.50", got)
+	}
+	if MustDecimal("1.5").Cmp(MustDecimal("1.50")) != 0 {
+		t.Error("1.5 and 1.50 compare unequal")
+	}
+	var zero Decimal
+	if !zero.IsZero() || zero.String() != "0" {
+		t.Errorf("zero value = %s", zero)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: `"12.50"`, want: "12.50"},
+		{in: `12.50`, want: "12.50"},
+		{in: `0.30000000000000000001`, want: "0.30000000000000000001"},
+		{in: `-7`, want: "-7"},
+		{in: `"12.5`, wantErr: true},
+		{in: `""`, wantErr: true},
+		{in: `1e2`, wantErr: true},
+		{in: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		err := json.Unmarshal([]byte(tt.in), &d)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+		out, err := json.Marshal(d)
+		if err != nil || string(out) != `"`+tt.want+`"` {
+			t.Errorf("Marshal(%s) = %s, %v", tt.want, out, err)
+		}
+	}
+
+	var v struct {
+		Amount Decimal `json:"amount"`
+	}
+	v.Amount = MustDecimal("1")
+	if err := json.Unmarshal([]byte(`{"amount":null}`), &v); err != nil || v.Amount.String() != "1" {
+		t.Errorf("null changed the decimal to %s, %v", v.Amount, err)
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	tests := []struct {
+		src     interface{}
+		want    string
+		wantErr bool
+	}{
+		{src: "12.50", want: "12.50"},
+		{src: []byte("-0.001"), want: "-0.001"},
+		{src: int64(42), want: "42"},
+		{src: "abc", wantErr: true},
+		{src: 1.5, wantErr: true},
+		{src: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		err := d.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %s, want error", tt.src, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("Scan(%#v) = %s, want %s", tt.src, got, tt.want)
+		}
+		if v, err := d.Value(); err != nil || v != tt.want {
+			t.Errorf("Value() = %v, %v, want %s", v, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package types provides value types with a fixed wire format for request
+// and response bodies: Date (2006-01-02), Time (RFC 3339 in a chosen time
+// zone), Duration ("1m30s") and Decimal (exact decimal numbers for money).
+// They implement encoding.TextMarshaler and TextUnmarshaler, so they work
+// with encoding/json and with cyber.BindURI, BindHeader and GetHeader, and
+// sql.Scanner and driver.Valuer where a column type fits.
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Location is the default time zone Time values are written in, for
+// values without their own (see NewTimeIn). Set it before the server
+// starts, e.g. to time.Local.
+var Location = time.UTC
+
+// Time is a time.Time written as RFC 3339 with second precision, e.g.
+// "2024-05-01T08:00:00Z", in the value's own time zone or else Location.
+// Parsing requires an explicit offset and accepts fractional seconds, and
+// keeps the zone the value was given. The zero Time is written as "".
+type Time struct {
+	time.Time
+	// loc 是写出时使用的时区，为nil时使用Location
+	loc *time.Location
+}
+
+// NewTime wraps t.
+func NewTime(t time.Time) Time {
+	return Time{Time: t}
+}
+
+// NewTimeIn wraps t to be written in loc, e.g. a tenant's or user's zone,
+// regardless of Location.
+func NewTimeIn(t time.Time, loc *time.Location) Time {
+	return Time{Time: t, loc: loc}
+}
+
+// Now returns the current time.
+func Now() Time {
+	return Time{Time: time.Now()}
+}
+
+// WithLocation returns t to be written in loc; a nil loc goes back to
+// Location. Set it on a field before decoding to keep the zone for the
+// response.
+func (t Time) WithLocation(loc *time.Location) Time {
+	t.loc = loc
+	return t
+}
+
+// location 返回写出时使用的时区
+func (t Time) location() *time.Location {
+	if t.loc != nil {
+		return t.loc
+	}
+	return Location
+}
+
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(t.location()).Format(time.RFC3339)
+}
+
+func (t Time) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *Time) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*t = Time{loc: t.loc}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, string(text))
+	if err != nil {
+		return fmt.Errorf("types: invalid time %q, want RFC 3339 such as 2006-01-02T15:04:05Z", text)
+	}
+	t.Time = parsed
+	return nil
+}
+
+// MarshalJSON writes the zero Time as null.
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = Time{loc: t.loc}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("types: time must be a string: %w", err)
+	}
+	return t.UnmarshalText([]byte(s))
+}
+
+func (t Time) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+func (t *Time) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = Time{loc: t.loc}
+	case time.Time:
+		t.Time = v
+	case string:
+		return t.UnmarshalText([]byte(v))
+	case []byte:
+		return t.UnmarshalText(v)
+	default:
+		return fmt.Errorf("types: cannot scan %T into Time", src)
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as a Go duration string, e.g.
+// "1m30s", instead of a number of nanoseconds.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Std returns d as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("types: invalid duration %q, want a value such as 1m30s", text)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalJSON accepts a duration string; a bare number is rejected
+// because its unit would be ambiguous.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("types: duration must be a string such as \"1m30s\"")
+	}
+	return d.UnmarshalText([]byte(s))
+}
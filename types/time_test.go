@@ -0,0 +1,153 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		out     string
+		wantErr bool
+	}{
+		{in: `"2024-05-01T08:00:00Z"`, out: `"2024-05-01T08:00:00Z"`},
+		{in: `"2024-05-01T10:00:00+02:00"`, out: `"2024-05-01T08:00:00Z"`},
+		{in: `"2024-05-01T08:00:00.123456Z"`, out: `"2024-05-01T08:00:00Z"`},
+		{in: `null`, out: `null`},
+		{in: `"2024-05-01T08:00:00"`, wantErr: true},
+		{in: `"2024-05-01"`, wantErr: true},
+		{in: `1714550400`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var v Time
+		err := json.Unmarshal([]byte(tt.in), &v)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want error", tt.in, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		out, err := json.Marshal(v)
+		if err != nil || string(out) != tt.out {
+			t.Errorf("Marshal(Unmarshal(%s)) = %s, %v, want %s", tt.in, out, err, tt.out)
+		}
+	}
+}
+
+func TestTimeLocation(t *testing.T) {
+	at := time.Date(2024, time.May, 1, 8, 0, 0, 0, time.UTC)
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	if got := NewTimeIn(at, shanghai).String(); got != "2024-05-01T16:00:00+08:00" {
+		t.Errorf("NewTimeIn(UTC+8) = %s", got)
+	}
+	if got := NewTime(at).String(); got != "2024-05-01T08:00:00Z" {
+		t.Errorf("NewTime() = %s, want Location UTC", got)
+	}
+
+	// 解码保留字段上预先设置的时区
+	v := struct {
+		At Time `json:"at"`
+	}{At: Time{}.WithLocation(shanghai)}
+	if err := json.Unmarshal([]byte(`{"at":"2024-05-01T08:00:00Z"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.At.String(); got != "2024-05-01T16:00:00+08:00" {
+		t.Errorf("decoded into UTC+8 field = %s", got)
+	}
+	if !v.At.Equal(at) {
+		t.Errorf("decoded instant = %v, want %v", v.At.Time, at)
+	}
+	if got := v.At.WithLocation(nil).String(); got != "2024-05-01T08:00:00Z" {
+		t.Errorf("WithLocation(nil) = %s", got)
+	}
+}
+
+func TestTimeScan(t *testing.T) {
+	at := time.Date(2024, time.May, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		src     interface{}
+		want    time.Time
+		wantErr bool
+	}{
+		{src: at, want: at},
+		{src: "2024-05-01T08:00:00Z", want: at},
+		{src: []byte("2024-05-01T16:00:00+08:00"), want: at},
+		{src: nil},
+		{src: "2024-05-01 08:00:00", wantErr: true},
+		{src: int64(1714550400), wantErr: true},
+	}
+	for _, tt := range tests {
+		var v Time
+		err := v.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %s, want error", tt.src, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if !v.Equal(tt.want) {
+			t.Errorf("Scan(%#v) = %v, want %v", tt.src, v.Time, tt.want)
+		}
+		value, err := v.Value()
+		if err != nil {
+			t.Errorf("Value() of %s: %v", v, err)
+		}
+		if got, _ := value.(time.Time); v.IsZero() && value != nil || !v.IsZero() && !got.Equal(tt.want) {
+			t.Errorf("Value() of %s = %#v", v, value)
+		}
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		out     string
+		wantErr bool
+	}{
+		{in: `"1m30s"`, want: 90 * time.Second, out: `"1m30s"`},
+		{in: `"1.5h"`, want: 90 * time.Minute, out: `"1h30m0s"`},
+		{in: `"-250ms"`, want: -250 * time.Millisecond, out: `"-250ms"`},
+		{in: `"0s"`, out: `"0s"`},
+		{in: `90`, wantErr: true},
+		{in: `"90"`, wantErr: true},
+		{in: `"1 minute"`, wantErr: true},
+		{in: `""`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var d Duration
+		err := json.Unmarshal([]byte(tt.in), &d)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if d.Std() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, d, tt.want)
+		}
+		out, err := json.Marshal(d)
+		if err != nil || string(out) != tt.out {
+			t.Errorf("Marshal(%s) = %s, %v, want %s", d, out, err, tt.out)
+		}
+	}
+
+	d := Duration(time.Second)
+	if err := json.Unmarshal([]byte(`null`), &d); err != nil || d.Std() != time.Second {
+		t.Errorf("null changed the duration to %s, %v", d, err)
+	}
+}
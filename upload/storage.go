@@ -0,0 +1,30 @@
+package upload
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/suonanjiexi/cyber/storage"
+)
+
+// MoveToStorage returns an OnComplete hook that streams finished uploads from
+// the staging store into dst under keyFunc(info) and removes the staged copy.
+// Chain it inside your own OnComplete if you also need other processing.
+func MoveToStorage(staging Store, dst storage.Storage, keyFunc func(info Info) string) func(r *http.Request, info Info) {
+	return func(r *http.Request, info Info) {
+		reader, err := staging.Open(info.ID)
+		if err != nil {
+			log.Printf("Error opening upload %s: %v", info.ID, err)
+			return
+		}
+		defer reader.Close()
+		key := keyFunc(info)
+		if err := dst.Put(r.Context(), key, reader, info.Size, info.Metadata["filetype"]); err != nil {
+			log.Printf("Error moving upload %s to storage: %v", info.ID, err)
+			return
+		}
+		if err := staging.Delete(info.ID); err != nil {
+			log.Printf("Error deleting staged upload %s: %v", info.ID, err)
+		}
+	}
+}
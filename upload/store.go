@@ -0,0 +1,134 @@
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("upload: not found")
+
+// Info describes an upload in progress or completed.
+type Info struct {
+	ID        string            `json:"id"`
+	Size      int64             `json:"size"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (i Info) Complete() bool {
+	return i.Offset >= i.Size
+}
+
+// Store persists upload data and offsets. Implementations must be safe for
+// concurrent use across different IDs; Handler serializes access per ID.
+type Store interface {
+	Create(info Info) error
+	Info(id string) (Info, error)
+	// Append writes r at offset and returns the number of bytes written.
+	Append(id string, offset int64, r io.Reader) (int64, error)
+	Open(id string) (io.ReadCloser, error)
+	Delete(id string) error
+}
+
+// FileStore keeps each upload as <id>.bin plus an <id>.info JSON file.
+type FileStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (s *FileStore) binPath(id string) string {
+	return filepath.Join(s.Dir, id+".bin")
+}
+
+func (s *FileStore) infoPath(id string) string {
+	return filepath.Join(s.Dir, id+".info")
+}
+
+func (s *FileStore) Create(info Info) error {
+	f, err := os.OpenFile(s.binPath(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return s.writeInfo(info)
+}
+
+func (s *FileStore) writeInfo(info Info) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := s.infoPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(info.ID))
+}
+
+func (s *FileStore) Info(id string) (Info, error) {
+	var info Info
+	b, err := os.ReadFile(s.infoPath(id))
+	if os.IsNotExist(err) {
+		return info, ErrNotFound
+	}
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(b, &info)
+	return info, err
+}
+
+func (s *FileStore) Append(id string, offset int64, r io.Reader) (int64, error) {
+	info, err := s.Info(id)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(s.binPath(id), os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r, info.Size-offset))
+	// 即使连接中断也记录已写入的数据，客户端可以从新的偏移量继续上传
+	info.Offset = offset + n
+	if err := s.writeInfo(info); err != nil {
+		return n, err
+	}
+	return n, copyErr
+}
+
+func (s *FileStore) Open(id string) (io.ReadCloser, error) {
+	f, err := os.Open(s.binPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *FileStore) Delete(id string) error {
+	if err := os.Remove(s.binPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package upload
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TusVersion    = "1.0.0"
+	tusExtensions = "creation,creation-with-upload,termination"
+)
+
+type Config struct {
+	Store Store
+	// BasePath 是挂载路径，例如 "/files/"，用于生成Location
+	BasePath string
+	// 单个上传的最大字节数，0表示不限制
+	MaxSize int64
+	// OnComplete 在上传全部完成后调用
+	OnComplete func(r *http.Request, info Info)
+}
+
+// Handler implements the tus.io v1.0.0 resumable upload protocol. Mount it
+// with http.Handle(config.BasePath, handler).
+type Handler struct {
+	config Config
+	mu     sync.Mutex
+	locks  map[string]*uploadLock
+}
+
+// uploadLock 保证同一上传的请求串行执行，refs是持有或等待它的请求数，为0时删除
+type uploadLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func New(config Config) *Handler {
+	if !strings.HasSuffix(config.BasePath, "/") {
+		config.BasePath += "/"
+	}
+	return &Handler{config: config, locks: make(map[string]*uploadLock)}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Tus-Resumable", TusVersion)
+	if r.Method == http.MethodOptions {
+		header.Set("Tus-Version", TusVersion)
+		header.Set("Tus-Extension", tusExtensions)
+		if h.config.MaxSize > 0 {
+			header.Set("Tus-Max-Size", strconv.FormatInt(h.config.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		header.Set("Tus-Version", TusVersion)
+		http.Error(w, "Unsupported Tus Version", http.StatusPreconditionFailed)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, h.config.BasePath), "/")
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		h.create(w, r)
+	case !validID(id):
+		http.NotFound(w, r)
+	case r.Method == http.MethodHead:
+		h.head(w, r, id)
+	case r.Method == http.MethodPatch:
+		h.patch(w, r, id)
+	case r.Method == http.MethodDelete:
+		h.delete(w, r, id)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "Invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if h.config.MaxSize > 0 && size > h.config.MaxSize {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	info := Info{
+		ID:        newID(),
+		Size:      size,
+		Metadata:  parseMetadata(r.Header.Get("Upload-Metadata")),
+		CreatedAt: time.Now(),
+	}
+	if err := h.config.Store.Create(info); err != nil {
+		log.Printf("Error creating upload: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", h.config.BasePath+info.ID)
+	// creation-with-upload: 创建请求可以直接携带第一段数据
+	if r.Header.Get("Content-Type") == "application/offset+octet-stream" && r.ContentLength != 0 {
+		unlock := h.lock(info.ID)
+		defer unlock()
+		n, err := h.config.Store.Append(info.ID, 0, r.Body)
+		info.Offset = n
+		if err != nil {
+			log.Printf("Error appending upload %s: %v", info.ID, err)
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	}
+	// 长度为0的上传创建后即完成
+	h.completeIfDone(r, false, info)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) head(w http.ResponseWriter, r *http.Request, id string) {
+	info, err := h.config.Store.Info(id)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	header := w.Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	unlock := h.lock(id)
+	defer unlock()
+	info, err := h.config.Store.Info(id)
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	// 客户端重试已完成上传的最后一个PATCH时不能再次通知完成
+	wasComplete := info.Complete()
+	if offset != info.Offset {
+		http.Error(w, "Upload-Offset Mismatch", http.StatusConflict)
+		return
+	}
+	n, err := h.config.Store.Append(id, offset, r.Body)
+	info.Offset = offset + n
+	if err != nil {
+		log.Printf("Error appending upload %s: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	h.completeIfDone(r, wasComplete, info)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, id string) {
+	unlock := h.lock(id)
+	defer unlock()
+	if _, err := h.config.Store.Info(id); err != nil {
+		h.storeError(w, err)
+		return
+	}
+	if err := h.config.Store.Delete(id); err != nil {
+		h.storeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeIfDone 在上传由未完成变为完成时调用OnComplete
+func (h *Handler) completeIfDone(r *http.Request, wasComplete bool, info Info) {
+	if !wasComplete && info.Complete() && h.config.OnComplete != nil {
+		h.config.OnComplete(r, info)
+	}
+}
+
+func (h *Handler) storeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Upload store error: %v", err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// lock 锁定单个上传并返回解锁函数，最后一个请求解锁时删除锁，
+// 已完成或被放弃的上传不会一直占用内存
+func (h *Handler) lock(id string) (unlock func()) {
+	h.mu.Lock()
+	lock, ok := h.locks[id]
+	if !ok {
+		lock = &uploadLock{}
+		h.locks[id] = lock
+	}
+	lock.refs++
+	h.mu.Unlock()
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		h.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(h.locks, id)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// parseMetadata 解析 "key base64value,key2 base64value2" 格式的Upload-Metadata
+func parseMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata
+}
+
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,131 @@
+package cyber
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 固定内容端点的缓存时间
+const staticMaxAge = 24 * time.Hour
+
+// RobotsPolicy is the content of robots.txt.
+type RobotsPolicy struct {
+	// 没有规则时允许所有爬虫访问所有路径
+	Rules    []RobotsRule
+	Sitemaps []string
+}
+
+// RobotsRule is a group of robots.txt directives for one or more user agents.
+type RobotsRule struct {
+	// 为空时使用 "*"
+	UserAgents []string
+	Allow      []string
+	Disallow   []string
+	// 不为0时输出Crawl-delay，部分爬虫支持
+	CrawlDelay time.Duration
+}
+
+// DisallowAll keeps all crawlers out, e.g. for staging environments.
+var DisallowAll = RobotsPolicy{Rules: []RobotsRule{{Disallow: []string{"/"}}}}
+
+func (policy RobotsPolicy) String() string {
+	var b strings.Builder
+	rules := policy.Rules
+	if len(rules) == 0 {
+		rules = []RobotsRule{{}}
+	}
+	for i, rule := range rules {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		agents := rule.UserAgents
+		if len(agents) == 0 {
+			agents = []string{"*"}
+		}
+		for _, agent := range agents {
+			fmt.Fprintf(&b, "User-agent: %s\n", agent)
+		}
+		for _, p := range rule.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", p)
+		}
+		for _, p := range rule.Disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", p)
+		}
+		// 空的Disallow表示允许访问所有路径，每组至少要有一条规则
+		if len(rule.Allow) == 0 && len(rule.Disallow) == 0 {
+			b.WriteString("Disallow:\n")
+		}
+		if rule.CrawlDelay > 0 {
+			fmt.Fprintf(&b, "Crawl-delay: %s\n", strconv.FormatFloat(rule.CrawlDelay.Seconds(), 'f', -1, 64))
+		}
+	}
+	if len(policy.Sitemaps) > 0 {
+		b.WriteString("\n")
+		for _, sitemap := range policy.Sitemaps {
+			fmt.Fprintf(&b, "Sitemap: %s\n", sitemap)
+		}
+	}
+	return b.String()
+}
+
+// Robots serves policy at /robots.txt.
+func (app *App) Robots(policy RobotsPolicy) {
+	app.Get("/robots.txt", staticHandler("text/plain; charset=utf-8", []byte(policy.String())))
+}
+
+// Favicon serves the named file of fsys at /favicon.ico, e.g. an embedded
+// favicon.ico or favicon.png. The file is read once here.
+func (app *App) Favicon(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("cyber: favicon: %w", err)
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" && strings.EqualFold(path.Ext(name), ".ico") {
+		contentType = "image/x-icon"
+	}
+	app.Get("/favicon.ico", staticHandler(contentType, data))
+	return nil
+}
+
+// WellKnown serves content at /.well-known/name (RFC 8615), such as
+// "security.txt" or "apple-app-site-association". The content type
+// follows the name's extension, plain text by default.
+func (app *App) WellKnown(name, content string) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	app.WellKnownHandler(name, staticHandler(contentType, []byte(content)))
+}
+
+// WellKnownHandler registers a GET handler at /.well-known/name for
+// documents that change at runtime, e.g. "jwks.json" served by a
+// jwt.Keyring.
+func (app *App) WellKnownHandler(name string, handler http.HandlerFunc) {
+	app.Get("/.well-known/"+strings.Trim(name, "/"), handler)
+}
+
+// staticHandler 返回固定内容，带ETag以便客户端条件请求；contentType为空时按内容识别
+func staticHandler(contentType string, data []byte) http.HandlerFunc {
+	h := fnv.New64a()
+	h.Write(data)
+	etag := fmt.Sprintf(`"%016x"`, h.Sum64())
+	cacheControl := "public, max-age=" + strconv.Itoa(int(staticMaxAge.Seconds()))
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl)
+		if IfNoneMatch(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		Blob(w, r, http.StatusOK, contentType, data)
+	}
+}